@@ -104,7 +104,7 @@ func initSendBench(accountCount int) (StopFunc, error) {
 		}
 		addr := crypto.PubkeyToAddress(priv.PublicKey)
 
-		client, err := eth.NewClient("http://localhost:8545", "0xF12b5dd4EAD5F743C6BaA640B0216200e89B60Da", priv)
+		client, err := eth.NewClient("http://localhost:8545", "0xF12b5dd4EAD5F743C6BaA640B0216200e89B60Da", eth.NewPrivateKeySigner(priv))
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/pkg/keystore"
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type accountCmdOutput struct {
+	Address string `json:"address"`
+	Path    string `json:"path"`
+}
+
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Manages the accounts in your keystore",
+}
+
+var accountNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "Creates a new account protected by a passphrase",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := KeystoreDir(cmd)
+		if err != nil {
+			return err
+		}
+		passphrase, err := keystore.PromptPassphrase("New passphrase: ", true)()
+		if err != nil {
+			return err
+		}
+
+		account, err := keystore.Open(dir).NewAccount(passphrase)
+		if err != nil {
+			return errors.Wrap(err, "failed to create account")
+		}
+
+		return PrintJSON(&accountCmdOutput{
+			Address: account.Address.Hex(),
+			Path:    account.URL.Path,
+		})
+	},
+}
+
+var accountListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the accounts in your keystore",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := KeystoreDir(cmd)
+		if err != nil {
+			return err
+		}
+
+		out := make([]accountCmdOutput, 0)
+		for _, account := range keystore.Open(dir).Accounts() {
+			out = append(out, accountCmdOutput{
+				Address: account.Address.Hex(),
+				Path:    account.URL.Path,
+			})
+		}
+		return PrintJSON(out)
+	},
+}
+
+var accountImportCmd = &cobra.Command{
+	Use:   "import keyfile",
+	Short: "Encrypts a raw hex private key file into your keystore",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := KeystoreDir(cmd)
+		if err != nil {
+			return err
+		}
+		path, err := homedir.Expand(args[0])
+		if err != nil {
+			return errors.Wrap(err, "couldn't expand homedir")
+		}
+		key, err := keystore.LoadKey(path, func() (string, error) {
+			return "", errors.New("key file is already encrypted")
+		})
+		if err != nil {
+			return err
+		}
+
+		passphrase, err := keystore.PromptPassphrase("New passphrase: ", true)()
+		if err != nil {
+			return err
+		}
+		account, err := keystore.Open(dir).ImportECDSA(key, passphrase)
+		if err != nil {
+			return errors.Wrap(err, "failed to import account")
+		}
+
+		return PrintJSON(&accountCmdOutput{
+			Address: crypto.PubkeyToAddress(key.PublicKey).Hex(),
+			Path:    account.URL.Path,
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountNewCmd)
+	accountCmd.AddCommand(accountListCmd)
+	accountCmd.AddCommand(accountImportCmd)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"strconv"
+	"time"
+)
+
+type confirmCmdOutput struct {
+	BlockNumber      uint64   `json:"blockNumber"`
+	TransactionIndex uint32   `json:"transactionIndex"`
+	MerkleRoot       string   `json:"merkleRoot,omitempty"`
+	ConfirmSigs      []string `json:"confirmSigs"`
+}
+
+var confirmCmd = &cobra.Command{
+	Use:   "confirm blockNumber txIdx",
+	Short: "Confirms that a transaction you sent was included in a block",
+	Long: `Confirms that a transaction you sent was included in a block by signing
+sha256(txHash || merkleRoot). The recipient needs these confirm sigs to spend
+the transaction's outputs. send does this automatically unless
+--auto-confirm=false is set. Confirmations are never bound to the node's
+signing domain, since the contract checks them when exits are started and
+challenged.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		blockNumber, txIdx, err := parseTxPosition(args)
+		if err != nil {
+			return err
+		}
+		privKey, err := ParsePrivateKey(cmd)
+		if err != nil {
+			return err
+		}
+
+		url := cmd.Flag(FlagNodeURL).Value.String()
+		if url == "" {
+			return errors.New("no node url set")
+		}
+		client, conn, err := CreateRootClient(url)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+		res, err := client.GetBlock(ctx, &pb.GetBlockRequest{
+			Number: blockNumber,
+		})
+		if err != nil {
+			return err
+		}
+		block := chain.BlockFromProto(res.Block)
+
+		var tx *chain.Transaction
+		for _, txProto := range res.ConfirmedTransactions {
+			confirmed, err := chain.ConfirmedTransactionFromProto(txProto)
+			if err != nil {
+				return err
+			}
+			if confirmed.Transaction.Body.TransactionIndex == txIdx {
+				tx = confirmed.Transaction
+				break
+			}
+		}
+		if tx == nil {
+			return fmt.Errorf("transaction %d not found in block %d", txIdx, blockNumber)
+		}
+
+		confirmSigs, err := confirmTransaction(client, eth.NewPrivateKeySigner(privKey), tx, block.Header.MerkleRoot)
+		if err != nil {
+			return err
+		}
+
+		return PrintJSON(&confirmCmdOutput{
+			BlockNumber:      blockNumber,
+			TransactionIndex: txIdx,
+			MerkleRoot:       hexutil.Encode(block.Header.MerkleRoot),
+			ConfirmSigs:      encodeSignatures(confirmSigs),
+		})
+	},
+}
+
+var confirmSigsCmd = &cobra.Command{
+	Use:   "confirm-sigs blockNumber txIdx",
+	Short: "Returns the confirm sigs of a transaction",
+	Long: `Returns the confirm sigs of a transaction, which are needed to spend its
+outputs. They are empty until the sender has confirmed the transaction.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		blockNumber, txIdx, err := parseTxPosition(args)
+		if err != nil {
+			return err
+		}
+
+		url := cmd.Flag(FlagNodeURL).Value.String()
+		if url == "" {
+			return errors.New("no node url set")
+		}
+		client, conn, err := CreateRootClient(url)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+		res, err := client.GetConfirmSigs(ctx, &pb.GetConfirmSigsRequest{
+			BlockNumber:      blockNumber,
+			TransactionIndex: txIdx,
+		})
+		if err != nil {
+			return err
+		}
+
+		return PrintJSON(&confirmCmdOutput{
+			BlockNumber:      blockNumber,
+			TransactionIndex: txIdx,
+			ConfirmSigs:      encodeSignatures(chain.SignaturesFromProto(res.ConfirmSigs)),
+		})
+	},
+}
+
+func parseTxPosition(args []string) (uint64, uint32, error) {
+	blockNumber, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid block number")
+	}
+	txIdx, err := strconv.ParseUint(args[1], 10, 32)
+	if err != nil {
+		return 0, 0, errors.New("invalid transaction index")
+	}
+	return blockNumber, uint32(txIdx), nil
+}
+
+func init() {
+	rootCmd.AddCommand(confirmCmd)
+	rootCmd.AddCommand(confirmSigsCmd)
+}
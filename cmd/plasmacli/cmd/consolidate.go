@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"math/big"
+	"sort"
+)
+
+type consolidateStep struct {
+	BlockNumber      uint64   `json:"blockNumber"`
+	TransactionIndex uint32   `json:"transactionIndex"`
+	InputCount       int      `json:"inputCount"`
+	Amount           string   `json:"amount"`
+	Fee              string   `json:"fee"`
+	MerkleRoot       string   `json:"merkleRoot"`
+	ConfirmSigs      []string `json:"confirmSigs"`
+}
+
+type consolidateCmdOutput struct {
+	Steps []consolidateStep `json:"steps"`
+	UTXOs []utxoCmdOutput   `json:"utxos"`
+}
+
+var consolidateCmdLog = log.ForSubsystem("ConsolidateCmd")
+
+var consolidateCmd = &cobra.Command{
+	Use:   "consolidate",
+	Short: "Merges your UTXOs until at most --target remain",
+	Long: `Merges your UTXOs until at most --target remain. Every merge pays the fee set
+with --fee, or the fee the node recommends when consolidation starts, out of
+the merged output.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		privKey, err := ParsePrivateKey(cmd)
+		if err != nil {
+			return err
+		}
+		addr := crypto.PubkeyToAddress(privKey.PublicKey)
+		target, err := cmd.Flags().GetInt(FlagTarget)
+		if err != nil {
+			return err
+		}
+		if target < 1 {
+			return errors.New("target must be at least 1")
+		}
+
+		url := cmd.Flag(FlagNodeURL).Value.String()
+		if url == "" {
+			return errors.New("no node url set")
+		}
+		client, conn, err := CreateRootClient(url)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		domain, err := fetchSigningDomain(client)
+		if err != nil {
+			return err
+		}
+		fee, err := feeFlag(cmd, client)
+		if err != nil {
+			return err
+		}
+
+		out := &consolidateCmdOutput{
+			Steps: make([]consolidateStep, 0),
+		}
+
+		for {
+			utxos, err := fetchSpendableUTXOs(client, addr)
+			if err != nil {
+				return err
+			}
+
+			merged, outputIndices := planConsolidation(utxos, addr, target)
+			if len(merged) == 0 {
+				out.UTXOs = utxoCmdOutputs(addr, utxos)
+				return PrintJSON(out)
+			}
+
+			tx := &chain.Transaction{
+				Body: chain.ZeroBody(),
+			}
+			total := addInputs(tx.Body, merged, outputIndices)
+			if total.Cmp(fee) <= 0 {
+				return fmt.Errorf("the outputs to merge hold %s wei, which does not cover the %s wei fee", total.Text(10), fee.Text(10))
+			}
+			amount := new(big.Int).Sub(total, fee)
+			tx.Body.Output0 = chain.NewOutput(addr, amount)
+			tx.Body.Fee = new(big.Int).Set(fee)
+
+			consolidateCmdLog.WithFields(logrus.Fields{
+				"inputCount": len(merged),
+				"amount":     amount.Text(10),
+				"fee":        fee.Text(10),
+			}).Info("merging outputs")
+
+			// the merge is confirmed before planning the next one, since
+			// spending its output requires its confirm sigs
+			sendRes, confirmSigs, err := sendAndConfirm(client, privKey, domain, tx)
+			if err != nil {
+				return err
+			}
+
+			step := consolidateStep{
+				BlockNumber:      sendRes.Inclusion.BlockNumber,
+				TransactionIndex: sendRes.Inclusion.TransactionIndex,
+				InputCount:       len(merged),
+				Amount:           amount.Text(10),
+				Fee:              fee.Text(10),
+				MerkleRoot:       hexutil.Encode(sendRes.Inclusion.MerkleRoot),
+			}
+			for _, confirmSig := range confirmSigs {
+				step.ConfirmSigs = append(step.ConfirmSigs, hexutil.Encode(confirmSig[:]))
+			}
+			out.Steps = append(out.Steps, step)
+		}
+	},
+}
+
+// planConsolidation picks the outputs of the next merge transaction, or
+// none if addr holds at most target outputs. The smallest outputs
+// are merged first, and each merge spends no more than needed to reach
+// target, up to chain.MaxInputs.
+func planConsolidation(utxos []chain.ConfirmedTransaction, addr common.Address, target int) ([]chain.ConfirmedTransaction, []uint8) {
+	outpoints := collectOutpoints(utxos, addr)
+	if len(outpoints) <= target {
+		return nil, nil
+	}
+
+	sort.Slice(outpoints, func(i, j int) bool {
+		return outpoints[i].amount().Cmp(outpoints[j].amount()) < 0
+	})
+
+	count := len(outpoints) - target + 1
+	if count > chain.MaxInputs {
+		count = chain.MaxInputs
+	}
+
+	var merged []chain.ConfirmedTransaction
+	var indices []uint8
+	for _, op := range outpoints[:count] {
+		merged = append(merged, op.tx)
+		indices = append(indices, op.idx)
+	}
+	return merged, indices
+}
+
+func init() {
+	rootCmd.AddCommand(consolidateCmd)
+	consolidateCmd.Flags().Int(FlagTarget, 1, "number of UTXOs to consolidate down to.")
+	consolidateCmd.Flags().String(FlagFee, "", "fee to pay the operator on every merge, in wei. Defaults to the fee the node recommends.")
+}
@@ -39,7 +39,7 @@ var depositCmd = &cobra.Command{
 			return err
 		}
 
-		client, err := eth.NewClient(cmd.Flag(FlagEthereumNodeUrl).Value.String(), args[0], privKey)
+		client, err := eth.NewClient(cmd.Flag(FlagEthereumNodeUrl).Value.String(), args[0], eth.NewPrivateKeySigner(privKey))
 		if err != nil {
 			return err
 		}
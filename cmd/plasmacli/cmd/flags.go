@@ -4,4 +4,18 @@ const (
 	FlagPrivateKeyPath = "private-key-path"
 	FlagNodeURL = "node-url"
 	FlagEthereumNodeUrl = "ethereum-node-url"
+	FlagTarget = "target"
+	FlagProgressFile = "progress-file"
+	FlagKeystore = "keystore"
+	FlagAccount = "account"
+	FlagFee = "fee"
+	FlagOut = "out"
+	FlagAutoConfirm = "auto-confirm"
+	FlagTypedData = "typed-data"
+	FlagSignature = "signature"
+	FlagTLS = "tls"
+	FlagCACert = "ca-cert"
+	FlagClientCert = "client-cert"
+	FlagClientKey = "client-key"
+	FlagAuthToken = "auth-token"
 )
\ No newline at end of file
@@ -4,8 +4,13 @@ import (
 	"github.com/spf13/cobra"
 	"fmt"
 	"os"
+	"github.com/kyokan/plasma/pkg/keystore"
+	"github.com/kyokan/plasma/pkg/rpc"
 )
 
+// clientOptions are how CreateRootClient connects to plasmad.
+var clientOptions rpc.ClientOptions
+
 var rootCmd = &cobra.Command{
 	Use: "plasmacli",
 	Short: "Interacts with a running plasmad instance.",
@@ -16,7 +21,14 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.PersistentFlags().StringP(FlagPrivateKeyPath, "p", "~/.plasma/key", "Path to your private key.")
+	rootCmd.PersistentFlags().String(FlagKeystore, keystore.DefaultDir, "Path to your keystore directory.")
+	rootCmd.PersistentFlags().String(FlagAccount, "", "Address of the keystore account to use instead of the private key file.")
 	rootCmd.PersistentFlags().StringP(FlagNodeURL, "u", "localhost:6545", "URL to a running plasmad instance.")
+	rootCmd.PersistentFlags().BoolVar(&clientOptions.TLS, FlagTLS, false, "Connect to plasmad over TLS.")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.CACertFile, FlagCACert, "", "Path to PEM CA certificates to verify plasmad with instead of the system's.")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.ClientCertFile, FlagClientCert, "", "Path to a PEM client certificate to authenticate with.")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.ClientKeyFile, FlagClientKey, "", "Path to the PEM private key of the client certificate.")
+	rootCmd.PersistentFlags().StringVar(&clientOptions.Token, FlagAuthToken, "", "API key or JWT to authenticate with. Requires TLS.")
 }
 
 func Execute() {
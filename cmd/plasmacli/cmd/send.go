@@ -1,19 +1,21 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/kyokan/plasma/pkg/eth"
 	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/pkg/rpc"
 	"github.com/kyokan/plasma/pkg/rpc/pb"
-	"github.com/kyokan/plasma/util"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"math/big"
 	"sort"
 	"time"
@@ -36,6 +38,18 @@ type outpoint struct {
 	idx uint8
 }
 
+func (o outpoint) amount() *big.Int {
+	return o.tx.Transaction.Body.OutputAt(o.idx).Amount
+}
+
+func (o outpoint) String() string {
+	return outpointKey(o.tx.Transaction.Body.BlockNumber, o.tx.Transaction.Body.TransactionIndex, o.idx)
+}
+
+func outpointKey(blockNum uint64, txIdx uint32, outIdx uint8) string {
+	return fmt.Sprintf("%d:%d:%d", blockNum, txIdx, outIdx)
+}
+
 var sendCmd = &cobra.Command{
 	Use:   "send to value [depositNonce] [contractAddr]",
 	Short: "Sends funds",
@@ -51,6 +65,11 @@ var sendCmd = &cobra.Command{
 			return errors.New("invalid send value")
 		}
 
+		autoConfirm, err := cmd.Flags().GetBool(FlagAutoConfirm)
+		if err != nil {
+			return err
+		}
+
 		url := cmd.Flag(FlagNodeURL).Value.String()
 		if url == "" {
 			return errors.New("no node url set")
@@ -61,24 +80,33 @@ var sendCmd = &cobra.Command{
 		}
 		defer conn.Close()
 
+		fee, err := feeFlag(cmd, client)
+		if err != nil {
+			return err
+		}
+
 		if len(args) == 4 {
 			depositNonce, ok := new(big.Int).SetString(args[2], 10)
 			if !ok {
 				return errors.New("invalid deposit nonce")
 			}
 			contractAddr := common.HexToAddress(args[3])
-			contract, err := eth.NewClient(cmd.Flag(FlagEthereumNodeUrl).Value.String(), contractAddr.Hex(), privKey)
+			contract, err := eth.NewClient(cmd.Flag(FlagEthereumNodeUrl).Value.String(), contractAddr.Hex(), eth.NewPrivateKeySigner(privKey))
 			if err != nil {
 				return err
 			}
-			return SpendDeposit(client, contract, privKey, from, to, value, depositNonce)
+			return spendDeposit(client, contract, privKey, from, to, value, fee, depositNonce, autoConfirm)
 		}
 
-		return SpendTx(client, privKey, from, to, value)
+		return spendTx(client, privKey, from, to, value, fee, autoConfirm)
 	},
 }
 
 func SpendDeposit(client pb.RootClient, contract eth.Client, privKey *ecdsa.PrivateKey, from common.Address, to common.Address, value *big.Int, depositNonce *big.Int) error {
+	return spendDeposit(client, contract, privKey, from, to, value, chain.Zero(), depositNonce, true)
+}
+
+func spendDeposit(client pb.RootClient, contract eth.Client, privKey *ecdsa.PrivateKey, from common.Address, to common.Address, value *big.Int, fee *big.Int, depositNonce *big.Int, autoConfirm bool) error {
 	sendCmdLog.Info("spending deposit")
 	total, owner, err := contract.LookupDeposit(depositNonce)
 	if err != nil {
@@ -87,7 +115,8 @@ func SpendDeposit(client pb.RootClient, contract eth.Client, privKey *ecdsa.Priv
 	if owner != from {
 		return errors.New("you don't own this deposit")
 	}
-	if total.Cmp(value) < 0 {
+	required := new(big.Int).Add(value, fee)
+	if total.Cmp(required) < 0 {
 		return errors.New("cannot send more than the deposit amount")
 	}
 
@@ -95,56 +124,63 @@ func SpendDeposit(client pb.RootClient, contract eth.Client, privKey *ecdsa.Priv
 	body.Input0.DepositNonce = depositNonce
 	body.Output0.Amount = value
 	body.Output0.Owner = to
-	if total.Cmp(body.Output0.Amount) > 0 {
-		totalClone := new(big.Int).Set(total)
-		body.Output1.Amount = totalClone.Sub(totalClone, value)
+	body.Fee = new(big.Int).Set(fee)
+	if total.Cmp(required) > 0 {
+		body.Output1.Amount = new(big.Int).Sub(total, required)
 		body.Output1.Owner = from
 	}
 
-	sig, err := eth.Sign(privKey, body.SignatureHash())
-	if err != nil {
-		return err
-	}
-
 	// no confirm sigs on deposits
 	tx := &chain.Transaction{
 		Body: body,
-		Sigs: [2]chain.Signature{
-			sig,
-			sig,
-		},
 	}
 
 	sendCmdLog.Info("sending spend message")
-	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
-	sendRes, err := client.Send(ctx, &pb.SendRequest{
-		Transaction: tx.Proto(),
-	})
+	return sendAndPrint(client, privKey, tx, value, to, autoConfirm)
+}
+
+// SpendTx sends value using up to chain.MaxInputs of from's outputs.
+func SpendTx(client pb.RootClient, privKey *ecdsa.PrivateKey, from common.Address, to common.Address, value *big.Int) error {
+	return spendTx(client, privKey, from, to, value, chain.Zero(), true)
+}
+
+func spendTx(client pb.RootClient, privKey *ecdsa.PrivateKey, from common.Address, to common.Address, value *big.Int, fee *big.Int, autoConfirm bool) error {
+	sendCmdLog.Info("selecting outputs")
+
+	utxos, err := fetchSpendableUTXOs(client, from)
+	if err != nil {
+		return err
+	}
+	if len(utxos) == 0 {
+		return errors.New("no spendable outputs")
+	}
+	tx, err := buildSpend(utxos, from, to, value, fee)
 	if err != nil {
 		return err
 	}
 
-	sendCmdLog.Info("confirming spend")
+	return sendAndPrint(client, privKey, tx, value, to, autoConfirm)
+}
 
-	tx.Body.BlockNumber = sendRes.Inclusion.BlockNumber
-	tx.Body.TransactionIndex = sendRes.Inclusion.TransactionIndex
-	var buf bytes.Buffer
-	buf.Write(tx.RLPHash(util.Sha256))
-	buf.Write(sendRes.Inclusion.MerkleRoot)
-	sigHash := util.Sha256(buf.Bytes())
-	confirmSig, err := eth.Sign(privKey, sigHash)
+// sendAndPrint signs and sends tx, confirming it if autoConfirm is set, and
+// prints the result. Without confirmation, its outputs cannot be spent until
+// it is confirmed with the confirm command.
+func sendAndPrint(client pb.RootClient, privKey *ecdsa.PrivateKey, tx *chain.Transaction, value *big.Int, to common.Address, autoConfirm bool) error {
+	domain, err := fetchSigningDomain(client)
 	if err != nil {
 		return err
 	}
 
-	var emptySig chain.Signature
-	ctx, _ = context.WithTimeout(context.Background(), time.Second*5)
-	_, err = client.Confirm(ctx, &pb.ConfirmRequest{
-		BlockNumber:      sendRes.Inclusion.BlockNumber,
-		TransactionIndex: sendRes.Inclusion.TransactionIndex,
-		ConfirmSig0:      confirmSig[:],
-		ConfirmSig1:      emptySig[:],
-	})
+	var sendRes *pb.SendResponse
+	var confirmSigs []chain.Signature
+	if autoConfirm {
+		sendRes, confirmSigs, err = sendAndConfirm(client, privKey, domain, tx)
+	} else {
+		if err := signTransaction(eth.NewPrivateKeySigner(privKey), chain.DefaultSignatureVersion, domain, tx); err != nil {
+			return err
+		}
+		sendRes, err = submitTransaction(client, tx)
+	}
 	if err != nil {
 		return err
 	}
@@ -155,146 +191,248 @@ func SpendDeposit(client pb.RootClient, contract eth.Client, privKey *ecdsa.Priv
 		BlockNumber:      sendRes.Inclusion.BlockNumber,
 		TransactionIndex: sendRes.Inclusion.TransactionIndex,
 		MerkleRoot:       hexutil.Encode(sendRes.Inclusion.MerkleRoot),
-		ConfirmSigs: []string{
-			hexutil.Encode(confirmSig[:]),
-			hexutil.Encode(emptySig[:]),
-		},
+		ConfirmSigs:      encodeSignatures(confirmSigs),
 	}
 
 	return PrintJSON(out)
 }
 
-func SpendTx(client pb.RootClient, privKey *ecdsa.PrivateKey, from common.Address, to common.Address, value *big.Int) error {
-	sendCmdLog.Info("selecting outputs")
+func encodeSignatures(sigs []chain.Signature) []string {
+	strs := make([]string, len(sigs))
+	for i, sig := range sigs {
+		strs[i] = hexutil.Encode(sig[:])
+	}
+	return strs
+}
+
+// buildSpend returns an unsigned transaction sending value to to, funded by
+// from's outputs in utxos.
+func buildSpend(utxos []chain.ConfirmedTransaction, from common.Address, to common.Address, value *big.Int, fee *big.Int) (*chain.Transaction, error) {
+	required := new(big.Int).Add(value, fee)
+	selectedTransactions, outputIndices, err := selectUTXOs(utxos, from, required)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &chain.Transaction{
+		Body: chain.ZeroBody(),
+	}
+	tx.Body.Fee = new(big.Int).Set(fee)
+	total := addInputs(tx.Body, selectedTransactions, outputIndices)
+
+	tx.Body.Output0.Amount = value
+	tx.Body.Output0.Owner = to
+
+	if total.Cmp(required) > 0 {
+		tx.Body.Output1.Amount = new(big.Int).Sub(total, required)
+		tx.Body.Output1.Owner = from
+	}
+	return tx, nil
+}
 
+// fetchSpendableUTXOs returns the transactions holding addr's spendable
+// outputs.
+func fetchSpendableUTXOs(client pb.RootClient, addr common.Address) ([]chain.ConfirmedTransaction, error) {
 	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
 	res, err := client.GetOutputs(ctx, &pb.GetOutputsRequest{
-		Address:   from.Bytes(),
+		Address:   addr.Bytes(),
 		Spendable: true,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var utxos []chain.ConfirmedTransaction
 	for _, utxoProto := range res.ConfirmedTransactions {
 		confirmedTx, err := chain.ConfirmedTransactionFromProto(utxoProto)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		utxos = append(utxos, *confirmedTx)
 	}
-	if len(utxos) == 0 {
-		return errors.New("no spendable outputs")
-	}
-	selectedTransactions, outputIndices, err := selectUTXOs(utxos, from, value)
-	if err != nil {
-		return err
-	}
+	return utxos, nil
+}
 
+// addInputs spends the outputs at outputIndices of utxos in body, and
+// returns their total value.
+func addInputs(body *chain.TransactionBody, utxos []chain.ConfirmedTransaction, outputIndices []uint8) *big.Int {
 	total := big.NewInt(0)
-	tx := &chain.Transaction{
-		Body: chain.ZeroBody(),
-	}
-	for i, utxo := range selectedTransactions {
+	for i, utxo := range utxos {
 		txBody := utxo.Transaction.Body
-		var input *chain.Input
+		input := chain.NewInput(txBody.BlockNumber, txBody.TransactionIndex, outputIndices[i], chain.Zero())
 		if i == 0 {
-			input = tx.Body.Input0
-		} else if i == 1 {
-			input = tx.Body.Input1
+			body.Input0 = input
+			body.Input0ConfirmSigs = utxo.ConfirmSigs
 		} else {
-			panic("too many inputs!")
+			body.Input1 = input
+			body.Input1ConfirmSigs = utxo.ConfirmSigs
 		}
 
-		input.BlockNumber = txBody.BlockNumber
-		input.TransactionIndex = txBody.TransactionIndex
-		input.OutputIndex = outputIndices[i]
+		total = total.Add(total, txBody.OutputAt(outputIndices[i]).Amount)
+	}
+	return total
+}
 
-		if i == 0 {
-			tx.Body.Input0ConfirmSigs = [2]chain.Signature{
-				utxo.ConfirmSigs[0],
-				utxo.ConfirmSigs[1],
-			}
-		} else {
-			tx.Body.Input1ConfirmSigs = [2]chain.Signature{
-				utxo.ConfirmSigs[0],
-				utxo.ConfirmSigs[1],
-			}
+// fetchSigningDomain returns the domain the node expects signatures to be
+// bound to, or nil if it only accepts legacy signatures.
+func fetchSigningDomain(client pb.RootClient) (*chain.Domain, error) {
+	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+	res, err := client.GetSigningDomain(ctx, &pb.EmptyRequest{})
+	if err != nil {
+		// nodes that predate domain separation only accept legacy signatures
+		if status.Code(err) == codes.Unimplemented {
+			return nil, nil
 		}
+		return nil, err
+	}
 
-		total = total.Add(total, txBody.OutputAt(outputIndices[i]).Amount)
+	for _, version := range res.SignatureVersions {
+		if chain.SignatureVersion(version) == chain.SignatureVersionDomain {
+			return chain.NewDomain(rpc.DeserializeBig(res.ChainId), common.BytesToAddress(res.Contract)), nil
+		}
 	}
+	return nil, nil
+}
 
-	tx.Body.Output0.Amount = value
-	tx.Body.Output0.Owner = to
+// feeFlag returns the fee set with --fee, or the fee the node recommends
+// if it is not set.
+func feeFlag(cmd *cobra.Command, client pb.RootClient) (*big.Int, error) {
+	if cmd.Flags().Changed(FlagFee) {
+		fee, ok := new(big.Int).SetString(cmd.Flag(FlagFee).Value.String(), 10)
+		if !ok || fee.Sign() < 0 {
+			return nil, errors.New("invalid fee")
+		}
+		return fee, nil
+	}
+	return fetchFeeEstimate(client)
+}
 
-	if total.Cmp(value) > 0 {
-		totalClone := new(big.Int).Set(total)
-		tx.Body.Output1.Amount = totalClone.Sub(totalClone, value)
-		tx.Body.Output1.Owner = from
+// fetchFeeEstimate returns the fee the node recommends for a new
+// transaction.
+func fetchFeeEstimate(client pb.RootClient) (*big.Int, error) {
+	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+	res, err := client.EstimateFee(ctx, &pb.EmptyRequest{})
+	if err != nil {
+		// nodes that predate fee estimation accept transactions without fees
+		if status.Code(err) == codes.Unimplemented {
+			return chain.Zero(), nil
+		}
+		return nil, err
 	}
+	return rpc.DeserializeBig(res.Fee), nil
+}
 
-	sig, err := eth.Sign(privKey, tx.Body.SignatureHash())
+// signatureVersionFlag returns the signature version chosen with
+// --typed-data, or the default.
+func signatureVersionFlag(cmd *cobra.Command, domain *chain.Domain) (chain.SignatureVersion, error) {
+	typedData, err := cmd.Flags().GetBool(FlagTypedData)
+	if err != nil {
+		return 0, err
+	}
+	if !typedData {
+		return chain.DefaultSignatureVersion, nil
+	}
+	if domain == nil {
+		return 0, errors.New("the node does not accept typed data signatures")
+	}
+	return chain.SignatureVersionTypedData, nil
+}
+
+// signTransaction signs every input of tx with signer under version.
+// Signatures are bound to domain unless version is legacy.
+func signTransaction(signer eth.Signer, version chain.SignatureVersion, domain *chain.Domain, tx *chain.Transaction) error {
+	hash, err := tx.Body.VersionedSignatureHash(version, domain)
 	if err != nil {
 		return err
 	}
-	tx.Sigs[0] = sig
-	tx.Sigs[1] = sig
+	sig, err := eth.SignVersioned(signer, version, hash)
+	if err != nil {
+		return err
+	}
+	setSignatures(tx, sig)
+	return nil
+}
+
+// setSignatures uses sig for every input of tx, since all of them are owned
+// by the sender. Zero inputs must not be signed.
+func setSignatures(tx *chain.Transaction, sig chain.Signature) {
+	tx.Sigs = [2]chain.Signature{}
+	for i := range tx.Sigs {
+		if i == 0 || !tx.Body.InputAt(uint8(i)).IsZero() {
+			tx.Sigs[i] = sig
+		}
+	}
+}
 
-	ctx, _ = context.WithTimeout(context.Background(), time.Second*5)
+// submitTransaction sends a signed transaction and records where it was
+// included in its body.
+func submitTransaction(client pb.RootClient, tx *chain.Transaction) (*pb.SendResponse, error) {
+	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
 	sendRes, err := client.Send(ctx, &pb.SendRequest{
 		Transaction: tx.Proto(),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
 	tx.Body.BlockNumber = sendRes.Inclusion.BlockNumber
 	tx.Body.TransactionIndex = sendRes.Inclusion.TransactionIndex
-	var buf bytes.Buffer
-	buf.Write(tx.RLPHash(util.Sha256))
-	buf.Write(sendRes.Inclusion.MerkleRoot)
-	sigHash := util.Sha256(buf.Bytes())
-	confirmSig0, err := eth.Sign(privKey, sigHash)
-	if err != nil {
-		return err
-	}
+	return sendRes, nil
+}
 
-	var confirmSig1 chain.Signature
-	if !tx.Body.Input1.IsZero() {
-		confirmSig1 = confirmSig0
+// sendAndConfirm signs every input of tx with privKey, sends it and then
+// confirms its inclusion. It returns the confirm sigs, which must be given
+// when spending the transaction's outputs.
+func sendAndConfirm(client pb.RootClient, privKey *ecdsa.PrivateKey, domain *chain.Domain, tx *chain.Transaction) (*pb.SendResponse, []chain.Signature, error) {
+	signer := eth.NewPrivateKeySigner(privKey)
+	if err := signTransaction(signer, chain.DefaultSignatureVersion, domain, tx); err != nil {
+		return nil, nil, err
+	}
+	sendRes, err := submitTransaction(client, tx)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	sendCmdLog.Info("confirming transaction")
+	confirmSigs, err := confirmTransaction(client, signer, tx, sendRes.Inclusion.MerkleRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sendRes, confirmSigs, nil
+}
 
-	ctx, _ = context.WithTimeout(context.Background(), time.Second*5)
-	_, err = client.Confirm(ctx, &pb.ConfirmRequest{
-		BlockNumber:      sendRes.Inclusion.BlockNumber,
-		TransactionIndex: sendRes.Inclusion.TransactionIndex,
-		ConfirmSig0:      confirmSig0[:],
-		ConfirmSig1:      confirmSig1[:],
-	})
+// confirmTransaction signs the confirmation of tx's inclusion in the block
+// with merkleRoot for each of its inputs, and sends it to the node.
+func confirmTransaction(client pb.RootClient, signer eth.Signer, tx *chain.Transaction, merkleRoot []byte) ([]chain.Signature, error) {
+	confirmSig, err := eth.SignConfirmation(signer, tx, merkleRoot)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	out := &sendCmdOutput{
-		Value:            value.Text(10),
-		To:               to.Hex(),
-		BlockNumber:      sendRes.Inclusion.BlockNumber,
-		TransactionIndex: sendRes.Inclusion.TransactionIndex,
-		MerkleRoot:       hexutil.Encode(sendRes.Inclusion.MerkleRoot),
-		ConfirmSigs: []string{
-			hexutil.Encode(confirmSig0[:]),
-			hexutil.Encode(confirmSig1[:]),
-		},
+	confirmSigs := make([]chain.Signature, chain.MaxInputs)
+	for i := range confirmSigs {
+		if i == 0 || !tx.Body.InputAt(uint8(i)).IsZero() {
+			confirmSigs[i] = confirmSig
+		}
 	}
 
-	return PrintJSON(out)
+	ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
+	_, err = client.Confirm(ctx, &pb.ConfirmRequest{
+		BlockNumber:      tx.Body.BlockNumber,
+		TransactionIndex: tx.Body.TransactionIndex,
+		ConfirmSigs:      chain.SignaturesProto(confirmSigs),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return confirmSigs, nil
 }
 
 func selectUTXOs(confirmedTxs []chain.ConfirmedTransaction, addr common.Address, total *big.Int) ([]chain.ConfirmedTransaction, []uint8, error) {
+	return selectOutpoints(collectOutpoints(confirmedTxs, addr), total)
+}
+
+// collectOutpoints returns addr's outputs in confirmedTxs.
+func collectOutpoints(confirmedTxs []chain.ConfirmedTransaction, addr common.Address) []outpoint {
 	var outpoints []outpoint
 	for _, tx := range confirmedTxs {
 		indices := tx.Transaction.Body.OutputIndicesFor(&addr)
@@ -305,12 +443,20 @@ func selectUTXOs(confirmedTxs []chain.ConfirmedTransaction, addr common.Address,
 			})
 		}
 	}
+	return outpoints
+}
 
+// selectOutpoints picks outpoints worth at least total, preferring one or
+// two large outputs. outpoints is sorted in place.
+func selectOutpoints(outpoints []outpoint, total *big.Int) ([]chain.ConfirmedTransaction, []uint8, error) {
 	sort.Slice(outpoints, func(i, j int) bool {
 		a := outpoints[i].tx.Transaction.Body.OutputAt(outpoints[i].idx).Amount
 		b := outpoints[j].tx.Transaction.Body.OutputAt(outpoints[j].idx).Amount
 		return a.Cmp(b) > 0
 	})
+	if len(outpoints) == 0 {
+		return nil, nil, errors.New("no spendable outputs")
+	}
 
 	first := outpoints[0]
 	firstBody := first.tx.Transaction.Body
@@ -319,7 +465,7 @@ func selectUTXOs(confirmedTxs []chain.ConfirmedTransaction, addr common.Address,
 		return []chain.ConfirmedTransaction{first.tx}, []uint8{first.idx}, nil
 	}
 
-	for i := len(confirmedTxs) - 1; i >= 0; i-- {
+	for i := len(outpoints) - 1; i > 0; i-- {
 		second := outpoints[i]
 		secondBody := second.tx.Transaction.Body
 		sum := big.NewInt(0)
@@ -333,10 +479,25 @@ func selectUTXOs(confirmedTxs []chain.ConfirmedTransaction, addr common.Address,
 		}
 	}
 
+	// no pair is large enough, so fall back to the largest outputs
+	var selected []chain.ConfirmedTransaction
+	var indices []uint8
+	sum := big.NewInt(0)
+	for i := 0; i < len(outpoints) && i < chain.MaxInputs; i++ {
+		selected = append(selected, outpoints[i].tx)
+		indices = append(indices, outpoints[i].idx)
+		sum = sum.Add(sum, outpoints[i].tx.Transaction.Body.OutputAt(outpoints[i].idx).Amount)
+		if sum.Cmp(total) >= 0 {
+			return selected, indices, nil
+		}
+	}
+
 	return nil, nil, errors.New("no suitable UTXOs found")
 }
 
 func init() {
 	rootCmd.AddCommand(sendCmd)
 	sendCmd.Flags().StringP(FlagEthereumNodeUrl, "e", "http://localhost:8545", "URL to a running Ethereum node.")
+	sendCmd.Flags().String(FlagFee, "", "fee to pay the operator, in wei. Defaults to the fee the node recommends.")
+	sendCmd.Flags().Bool(FlagAutoConfirm, true, "confirm the transaction once it is included. If disabled, confirm it later with the confirm command.")
 }
@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/kyokan/plasma/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+)
+
+type batchPayment struct {
+	Row   int
+	To    common.Address
+	Value *big.Int
+}
+
+type batchReceipt struct {
+	Row     int  `json:"row"`
+	Resumed bool `json:"resumed,omitempty"`
+	*sendCmdOutput
+	Error string `json:"error,omitempty"`
+}
+
+// batchProgress is persisted after every payment so that an interrupted
+// batch can be resumed without paying anyone twice.
+type batchProgress struct {
+	CSVHash  string         `json:"csvHash"`
+	Receipts []batchReceipt `json:"receipts"`
+	// Pending is the payment being sent, if any. If the batch stops while
+	// a payment is pending it may or may not have been included.
+	Pending *pendingPayment `json:"pending,omitempty"`
+}
+
+type pendingPayment struct {
+	Row    int      `json:"row"`
+	Inputs []string `json:"inputs"`
+}
+
+var sendBatchCmdLog = log.ForSubsystem("SendBatchCmd")
+
+var sendBatchCmd = &cobra.Command{
+	Use:   "send-batch payments.csv",
+	Short: "Sends a batch of payments read from a CSV file",
+	Long: `Sends a batch of payments read from a CSV file with one payment per row,
+formatted as to,value. Payments are sent and confirmed in order, with
+the change of each payment funding the next. Every payment pays the fee set
+with --fee, or the fee the node recommends when the batch starts, out of its
+change. Progress is saved after every payment, so running the command again
+after a failure resumes the batch.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		privKey, err := ParsePrivateKey(cmd)
+		if err != nil {
+			return err
+		}
+
+		csvPath := args[0]
+		payments, csvHash, err := readBatchPayments(csvPath)
+		if err != nil {
+			return err
+		}
+		progressPath := cmd.Flag(FlagProgressFile).Value.String()
+		if progressPath == "" {
+			progressPath = csvPath + ".progress"
+		}
+		progress, err := loadBatchProgress(progressPath, csvHash)
+		if err != nil {
+			return err
+		}
+
+		url := cmd.Flag(FlagNodeURL).Value.String()
+		if url == "" {
+			return errors.New("no node url set")
+		}
+		client, conn, err := CreateRootClient(url)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		domain, err := fetchSigningDomain(client)
+		if err != nil {
+			return err
+		}
+		fee, err := feeFlag(cmd, client)
+		if err != nil {
+			return err
+		}
+
+		sendErr := sendBatch(client, privKey, domain, fee, payments, progress, progressPath)
+		var receipts []batchReceipt
+		receipts = append(receipts, progress.Receipts...)
+		if sendErr != nil && progress.Pending != nil {
+			receipts = append(receipts, batchReceipt{
+				Row:   progress.Pending.Row,
+				Error: sendErr.Error(),
+			})
+		}
+		if err := PrintJSON(receipts); err != nil {
+			return err
+		}
+		return sendErr
+	},
+}
+
+func sendBatch(client pb.RootClient, privKey *ecdsa.PrivateKey, domain *chain.Domain, fee *big.Int, payments []batchPayment, progress *batchProgress, progressPath string) error {
+	from := crypto.PubkeyToAddress(privKey.PublicKey)
+	done := make(map[int]bool)
+	for i := range progress.Receipts {
+		progress.Receipts[i].Resumed = true
+		done[progress.Receipts[i].Row] = true
+	}
+
+	utxos, err := fetchSpendableUTXOs(client, from)
+	if err != nil {
+		return err
+	}
+	pool := collectOutpoints(utxos, from)
+
+	if progress.Pending != nil {
+		// the pending payment was not sent if everything it spends is
+		// still spendable
+		unspent := make(map[string]bool)
+		for _, op := range pool {
+			unspent[op.String()] = true
+		}
+		for _, input := range progress.Pending.Inputs {
+			if !unspent[input] {
+				return fmt.Errorf(
+					"row %d may already have been sent since output %s is spent. check the recipient's balance, then remove the pending entry from %s",
+					progress.Pending.Row,
+					input,
+					progressPath,
+				)
+			}
+		}
+		progress.Pending = nil
+	}
+
+	for _, payment := range payments {
+		if done[payment.Row] {
+			continue
+		}
+		lgr := sendBatchCmdLog.WithFields(logrus.Fields{
+			"row":   payment.Row,
+			"to":    payment.To.Hex(),
+			"value": payment.Value.Text(10),
+			"fee":   fee.Text(10),
+		})
+
+		required := new(big.Int).Add(payment.Value, fee)
+		selected, outputIndices, err := selectOutpoints(pool, required)
+		if err != nil {
+			return errors.Wrapf(err, "failed to fund row %d", payment.Row)
+		}
+
+		tx := &chain.Transaction{
+			Body: chain.ZeroBody(),
+		}
+		total := addInputs(tx.Body, selected, outputIndices)
+		tx.Body.Output0 = chain.NewOutput(payment.To, payment.Value)
+		tx.Body.Fee = new(big.Int).Set(fee)
+		if total.Cmp(required) > 0 {
+			change := new(big.Int).Sub(total, required)
+			tx.Body.Output1 = chain.NewOutput(from, change)
+		}
+
+		spent := make(map[string]bool)
+		progress.Pending = &pendingPayment{
+			Row: payment.Row,
+		}
+		for i, utxo := range selected {
+			key := outpointKey(utxo.Transaction.Body.BlockNumber, utxo.Transaction.Body.TransactionIndex, outputIndices[i])
+			spent[key] = true
+			progress.Pending.Inputs = append(progress.Pending.Inputs, key)
+		}
+		if err := saveBatchProgress(progressPath, progress); err != nil {
+			return err
+		}
+
+		lgr.Info("sending payment")
+		sendRes, confirmSigs, err := sendAndConfirm(client, privKey, domain, tx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to send row %d", payment.Row)
+		}
+
+		// chain the change into the next payment without waiting for the
+		// node to report it
+		var remaining []outpoint
+		for _, op := range pool {
+			if !spent[op.String()] {
+				remaining = append(remaining, op)
+			}
+		}
+		if !tx.Body.OutputAt(1).IsZeroOutput() {
+			change := chain.ConfirmedTransaction{
+				Transaction: tx,
+			}
+			copy(change.ConfirmSigs[:], confirmSigs)
+			remaining = append(remaining, outpoint{
+				tx:  change,
+				idx: 1,
+			})
+		}
+		pool = remaining
+
+		out := &sendCmdOutput{
+			Value:            payment.Value.Text(10),
+			To:               payment.To.Hex(),
+			BlockNumber:      sendRes.Inclusion.BlockNumber,
+			TransactionIndex: sendRes.Inclusion.TransactionIndex,
+			MerkleRoot:       hexutil.Encode(sendRes.Inclusion.MerkleRoot),
+		}
+		for _, confirmSig := range confirmSigs {
+			out.ConfirmSigs = append(out.ConfirmSigs, hexutil.Encode(confirmSig[:]))
+		}
+		progress.Receipts = append(progress.Receipts, batchReceipt{
+			Row:           payment.Row,
+			sendCmdOutput: out,
+		})
+		progress.Pending = nil
+		if err := saveBatchProgress(progressPath, progress); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readBatchPayments parses a CSV of to,value rows. A header row
+// starting with "to" is skipped. It also returns the hash of the file, which
+// ties a progress file to it.
+func readBatchPayments(path string) ([]batchPayment, string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to read payments")
+	}
+
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var payments []batchPayment
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if row == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "to") {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, "", fmt.Errorf("row %d: expected to,value", row)
+		}
+
+		if !common.IsHexAddress(record[0]) {
+			return nil, "", fmt.Errorf("row %d: invalid recipient %s", row, record[0])
+		}
+		value, ok := new(big.Int).SetString(strings.TrimSpace(record[1]), 10)
+		if !ok || value.Sign() <= 0 {
+			return nil, "", fmt.Errorf("row %d: invalid value %s", row, record[1])
+		}
+		payments = append(payments, batchPayment{
+			Row:   row,
+			To:    common.HexToAddress(record[0]),
+			Value: value,
+		})
+	}
+
+	if len(payments) == 0 {
+		return nil, "", errors.New("no payments found")
+	}
+	return payments, hexutil.Encode(util.Sha256(data)), nil
+}
+
+func loadBatchProgress(path string, csvHash string) (*batchProgress, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &batchProgress{
+			CSVHash: csvHash,
+		}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read progress file")
+	}
+
+	var progress batchProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, errors.Wrap(err, "failed to parse progress file")
+	}
+	if progress.CSVHash != csvHash {
+		return nil, fmt.Errorf("progress file %s belongs to a different payments file", path)
+	}
+	return &progress, nil
+}
+
+// saveBatchProgress replaces the progress file atomically, so a crash never
+// leaves it half written.
+func saveBatchProgress(path string, progress *batchProgress) error {
+	data, err := json.MarshalIndent(progress, "", "    ")
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return errors.Wrap(err, "failed to write progress file")
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func init() {
+	rootCmd.AddCommand(sendBatchCmd)
+	sendBatchCmd.Flags().String(FlagFee, "", "fee to pay the operator on every payment, in wei. Defaults to the fee the node recommends.")
+	sendBatchCmd.Flags().String(FlagProgressFile, "", "path to the progress file used to resume the batch. Defaults to the payments file with a .progress suffix.")
+}
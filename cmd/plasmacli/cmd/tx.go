@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"math/big"
+	"os"
+)
+
+var txCmd = &cobra.Command{
+	Use:   "tx",
+	Short: "Builds, signs and submits transactions in separate steps",
+	Long: `Builds, signs and submits transactions in separate steps, so that transactions
+can be signed on a machine that holds the key but is never online. Each step
+reads and writes a versioned JSON transaction envelope.`,
+}
+
+var txBuildCmd = &cobra.Command{
+	Use:   "build to value [from]",
+	Short: "Builds an unsigned transaction from the sender's outputs",
+	Long: `Builds an unsigned transaction from the sender's outputs. The sender is read
+from the from argument, --account or the private key, in that order, so no
+key is needed to build a transaction.`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !common.IsHexAddress(args[0]) {
+			return errors.New("invalid recipient")
+		}
+		to := common.HexToAddress(args[0])
+		value, ok := new(big.Int).SetString(args[1], 10)
+		if !ok || value.Sign() <= 0 {
+			return errors.New("invalid send value")
+		}
+		from, err := AddrOrPrivateKeyAddr(cmd, args, 2)
+		if err != nil {
+			return err
+		}
+
+		url := cmd.Flag(FlagNodeURL).Value.String()
+		if url == "" {
+			return errors.New("no node url set")
+		}
+		client, conn, err := CreateRootClient(url)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		utxos, err := fetchSpendableUTXOs(client, from)
+		if err != nil {
+			return err
+		}
+		fee, err := feeFlag(cmd, client)
+		if err != nil {
+			return err
+		}
+		tx, err := buildSpend(utxos, from, to, value, fee)
+		if err != nil {
+			return err
+		}
+		domain, err := fetchSigningDomain(client)
+		if err != nil {
+			return err
+		}
+
+		return writeTxEnvelope(cmd, chain.NewTransactionEnvelope(tx, domain))
+	},
+}
+
+var txSignCmd = &cobra.Command{
+	Use:   "sign envelope.json",
+	Short: "Signs a transaction offline",
+	Long: `Signs every input of a transaction built with tx build. Signing only needs the
+private key, and never contacts a node. The envelope records the signing
+domain of the node it was built against.
+
+Transactions are signed with legacy signatures, which are the only ones the
+contract recovers when exits are started and challenged. With --typed-data,
+the transaction is signed as EIP-712 typed data instead, which the node
+accepts but the contract does not, so its outputs cannot be exited until the
+contract supports typed data. To sign with a wallet, pass the output of tx
+typed-data to eth_signTypedData and attach the result with --signature.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envelope, err := readTxEnvelope(args[0])
+		if err != nil {
+			return err
+		}
+		if len(envelope.MerkleRoot) > 0 {
+			return errors.New("transaction has already been submitted")
+		}
+		version, err := signatureVersionFlag(cmd, envelope.Domain)
+		if err != nil {
+			return err
+		}
+		envelope.SignatureVersion = version
+
+		sigHex := cmd.Flag(FlagSignature).Value.String()
+		if sigHex != "" {
+			sigBytes, err := hexutil.Decode(sigHex)
+			if err != nil {
+				return errors.Wrap(err, "invalid signature")
+			}
+			var sig chain.Signature
+			if len(sigBytes) != len(sig) {
+				return errors.New("invalid signature length")
+			}
+			copy(sig[:], sigBytes)
+			sig.NormalizeV()
+			setSignatures(envelope.Transaction, sig)
+			return writeTxEnvelope(cmd, envelope)
+		}
+
+		privKey, err := ParsePrivateKey(cmd)
+		if err != nil {
+			return err
+		}
+		if err := signTransaction(eth.NewPrivateKeySigner(privKey), version, envelope.Domain, envelope.Transaction); err != nil {
+			return err
+		}
+		return writeTxEnvelope(cmd, envelope)
+	},
+}
+
+var txTypedDataCmd = &cobra.Command{
+	Use:   "typed-data envelope.json",
+	Short: "Prints a transaction as EIP-712 typed data",
+	Long: `Prints a transaction built with tx build as EIP-712 typed data, which wallets
+can display and sign with eth_signTypedData.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envelope, err := readTxEnvelope(args[0])
+		if err != nil {
+			return err
+		}
+		if envelope.Domain == nil {
+			return errors.New("transaction was built for a node that does not accept typed data signatures")
+		}
+		return PrintJSON(envelope.Transaction.Body.TypedData(envelope.Domain))
+	},
+}
+
+var txSubmitCmd = &cobra.Command{
+	Use:   "submit envelope.json",
+	Short: "Submits a signed transaction",
+	Long: `Submits a transaction signed with tx sign. The envelope written out records the
+block the transaction was included in, which is what its inputs' owner signs
+to confirm it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envelope, err := readTxEnvelope(args[0])
+		if err != nil {
+			return err
+		}
+		if len(envelope.MerkleRoot) > 0 {
+			return errors.New("transaction has already been submitted")
+		}
+		if !envelope.IsSigned() {
+			return errors.New("transaction is not signed")
+		}
+
+		url := cmd.Flag(FlagNodeURL).Value.String()
+		if url == "" {
+			return errors.New("no node url set")
+		}
+		client, conn, err := CreateRootClient(url)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		sendRes, err := submitTransaction(client, envelope.Transaction)
+		if err != nil {
+			return err
+		}
+		envelope.MerkleRoot = sendRes.Inclusion.MerkleRoot
+		sendCmdLog.WithField("merkleRoot", hexutil.Encode(envelope.MerkleRoot)).Info("transaction included")
+		return writeTxEnvelope(cmd, envelope)
+	},
+}
+
+// readTxEnvelope reads an envelope from path, or from stdin if path is -.
+func readTxEnvelope(path string) (*chain.TransactionEnvelope, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read transaction")
+	}
+
+	var envelope chain.TransactionEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, errors.Wrap(err, "failed to parse transaction")
+	}
+	return &envelope, nil
+}
+
+// writeTxEnvelope writes envelope to --out, or to stdout if it is not set.
+func writeTxEnvelope(cmd *cobra.Command, envelope *chain.TransactionEnvelope) error {
+	out := cmd.Flag(FlagOut).Value.String()
+	if out == "" {
+		return PrintJSON(envelope)
+	}
+
+	data, err := json.MarshalIndent(envelope, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, data, 0600)
+}
+
+func init() {
+	rootCmd.AddCommand(txCmd)
+	txCmd.PersistentFlags().StringP(FlagOut, "o", "", "file to write the transaction to. Defaults to stdout.")
+	txCmd.AddCommand(txBuildCmd)
+	txBuildCmd.Flags().String(FlagFee, "", "fee to pay the operator, in wei. Defaults to the fee the node recommends.")
+	txCmd.AddCommand(txSignCmd)
+	txSignCmd.Flags().Bool(FlagTypedData, false, "sign the transaction as EIP-712 typed data. The contract cannot verify these, so the outputs cannot be exited.")
+	txSignCmd.Flags().String(FlagSignature, "", "signature made elsewhere, such as by a wallet, to attach instead of signing with the private key")
+	txCmd.AddCommand(txTypedDataCmd)
+	txCmd.AddCommand(txSubmitCmd)
+}
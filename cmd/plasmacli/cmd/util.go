@@ -4,21 +4,24 @@ import (
 	"github.com/spf13/cobra"
 	"crypto/ecdsa"
 	"github.com/pkg/errors"
-	"io/ioutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/kyokan/plasma/pkg/rpc/pb"
 	"google.golang.org/grpc"
 	"encoding/json"
 	"fmt"
 	"github.com/mitchellh/go-homedir"
-	"strings"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/pkg/keystore"
+	"github.com/kyokan/plasma/pkg/rpc"
 )
 
 func AddrOrPrivateKeyAddr(cmd *cobra.Command, args []string, addrArg int) (common.Address, error) {
 	var addr common.Address
 	if len(args) > addrArg {
 		addr = common.HexToAddress(args[addrArg])
+	} else if account := cmd.Flag(FlagAccount).Value.String(); account != "" {
+		// no need to unlock the account just to learn its address
+		addr = common.HexToAddress(account)
 	} else {
 		privKey, err := ParsePrivateKey(cmd)
 		if err != nil {
@@ -31,7 +34,28 @@ func AddrOrPrivateKeyAddr(cmd *cobra.Command, args []string, addrArg int) (commo
 	return addr, nil
 }
 
+// ParsePrivateKey loads the private key of the keystore account set with
+// --account or, if none is set, the key file at --private-key-path. Key files
+// may be raw hex or encrypted JSON.
 func ParsePrivateKey(cmd *cobra.Command) (*ecdsa.PrivateKey, error) {
+	passphrase := keystore.PromptPassphrase("Passphrase: ", false)
+
+	account := cmd.Flag(FlagAccount).Value.String()
+	if account != "" {
+		if !common.IsHexAddress(account) {
+			return nil, errors.New("invalid account address")
+		}
+		dir, err := KeystoreDir(cmd)
+		if err != nil {
+			return nil, err
+		}
+		path, err := keystore.FindAccount(dir, common.HexToAddress(account))
+		if err != nil {
+			return nil, err
+		}
+		return keystore.LoadKey(path, passphrase)
+	}
+
 	path := cmd.Flag(FlagPrivateKeyPath).Value.String()
 	if path == "" {
 		return nil, errors.New("no private key path set")
@@ -42,16 +66,21 @@ func ParsePrivateKey(cmd *cobra.Command) (*ecdsa.PrivateKey, error) {
 		return nil, errors.Wrap(err, "couldn't expand homedir")
 	}
 
-	keyBytes, err := ioutil.ReadFile(expanded)
+	return keystore.LoadKey(expanded, passphrase)
+}
+
+func KeystoreDir(cmd *cobra.Command) (string, error) {
+	dir, err := homedir.Expand(cmd.Flag(FlagKeystore).Value.String())
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read private key")
+		return "", errors.Wrap(err, "couldn't expand homedir")
 	}
-
-	return crypto.HexToECDSA(strings.TrimSpace(string(keyBytes)))
+	return dir, nil
 }
 
+// CreateRootClient connects to plasmad at url with the TLS and
+// authentication options set by the root command's flags.
 func CreateRootClient(url string) (pb.RootClient, *grpc.ClientConn, error) {
-	conn, err := grpc.Dial(url, grpc.WithInsecure())
+	conn, err := rpc.Dial(url, clientOptions)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to dial node")
 	}
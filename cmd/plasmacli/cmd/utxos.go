@@ -1,12 +1,10 @@
 package cmd
 
 import (
-	"context"
 	"errors"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/kyokan/plasma/pkg/chain"
-	"github.com/kyokan/plasma/pkg/rpc/pb"
 	"github.com/spf13/cobra"
-	"time"
 )
 
 type utxoCmdOutput struct {
@@ -36,37 +34,38 @@ var utxosCmd = &cobra.Command{
 		}
 		defer conn.Close()
 
-		ctx, _ := context.WithTimeout(context.Background(), time.Second*5)
-		res, err := client.GetOutputs(ctx, &pb.GetOutputsRequest{
-			Address:   addr.Bytes(),
-			Spendable: true,
-		})
+		utxos, err := fetchSpendableUTXOs(client, addr)
 		if err != nil {
 			return err
 		}
 
-		out := make([]utxoCmdOutput, 0)
+		out := utxoCmdOutputs(addr, utxos)
+		return PrintJSON(out)
+	},
+}
+
+func utxoCmdOutputs(addr common.Address, utxos []chain.ConfirmedTransaction) []utxoCmdOutput {
+	out := make([]utxoCmdOutput, 0)
 
-		for _, conf := range res.ConfirmedTransactions {
-			deser, err := chain.ConfirmedTransactionFromProto(conf)
-			if err != nil {
-				return err
-			}
-			tx := deser.Transaction.Body
+	for _, utxo := range utxos {
+		tx := utxo.Transaction.Body
 
-			indices := tx.OutputIndicesFor(&addr)
-			for _, idx := range indices {
-				out = append(out, utxoCmdOutput{
-					BlockNumber:      tx.BlockNumber,
-					TransactionIndex: tx.TransactionIndex,
-					OutputIndex:      idx,
-					Amount:           tx.OutputAt(idx).Amount.Text(10),
-				})
-			}
+		indices := tx.OutputIndicesFor(&addr)
+		for _, idx := range indices {
+			out = append(out, newUTXOCmdOutput(tx, idx))
 		}
+	}
 
-		return PrintJSON(out)
-	},
+	return out
+}
+
+func newUTXOCmdOutput(tx *chain.TransactionBody, idx uint8) utxoCmdOutput {
+	return utxoCmdOutput{
+		BlockNumber:      tx.BlockNumber,
+		TransactionIndex: tx.TransactionIndex,
+		OutputIndex:      idx,
+		Amount:           tx.OutputAt(idx).Amount.Text(10),
+	}
 }
 
 func init() {
@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"path"
+)
+
+const (
+	FlagRepair = "repair"
+	FlagHeight = "height"
+	FlagDepth  = "depth"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "inspects and maintains a node's database",
+}
+
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "verifies blocks and rebuilds UTXO, spend and deposit indexes",
+	Long: `Walks every block in the database, verifying the block hash chain, Merkle
+roots and block metadata, then replays all transactions and exits to verify
+the UTXO, spend and deposit indexes. With --repair, derived indexes are
+rebuilt from the block data. The node must not be running.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level, _, err := openStorage()
+		if err != nil {
+			return err
+		}
+		defer level.Close()
+
+		repair := viper.GetBool(FlagRepair)
+		var report *db.IntegrityReport
+		if repair {
+			report, err = db.RebuildIndexes(level)
+		} else {
+			report, err = db.CheckIntegrity(level)
+		}
+		if report != nil {
+			printIntegrityReport(report)
+		}
+		if err != nil {
+			return err
+		}
+
+		if repair {
+			if !report.OK() {
+				fmt.Println("rebuilt UTXO, spend and deposit indexes")
+			}
+			return nil
+		}
+		if !report.OK() {
+			return fmt.Errorf("found %d issues", len(report.Issues))
+		}
+		return nil
+	},
+}
+
+var dbExportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "writes a snapshot of the database to a file",
+	Long: `Writes blocks, block metadata, confirmed transactions, exit records and
+poll cursors to a versioned, checksummed snapshot file. Pruned databases
+cannot be exported. The node must not be running.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		height, err := cmd.Flags().GetUint64(FlagHeight)
+		if err != nil {
+			return err
+		}
+		level, storage, err := openStorage()
+		if err != nil {
+			return err
+		}
+		defer level.Close()
+
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		header, err := db.ExportSnapshot(storage, f, height)
+		if err != nil {
+			os.Remove(args[0])
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+
+		fmt.Printf("exported blocks 1 through %d to %s\n", header.Height, args[0])
+		return nil
+	},
+}
+
+var dbImportCmd = &cobra.Command{
+	Use:   "import [file]",
+	Short: "restores a snapshot into an empty database",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		height, err := cmd.Flags().GetUint64(FlagHeight)
+		if err != nil {
+			return err
+		}
+		level, _, err := openStorage()
+		if err != nil {
+			return err
+		}
+		defer level.Close()
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		header, err := db.ImportSnapshot(level, f, height)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("imported blocks 1 through %d from %s\n", header.Height, args[0])
+		return nil
+	},
+}
+
+var dbPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "discards old, fully spent transactions",
+	Long: `Deletes the bodies of transactions at least --depth blocks old whose outputs
+have all exited or been spent at least --depth blocks ago. Block headers and
+transaction hashes are kept, so Merkle proofs can still be generated. Pruned
+databases cannot be exported as snapshots. The node must not be running.
+Root node databases are never pruned.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		depth, err := cmd.Flags().GetUint64(FlagDepth)
+		if err != nil {
+			return err
+		}
+		level, _, err := openStorage()
+		if err != nil {
+			return err
+		}
+		defer level.Close()
+
+		report, err := db.Prune(level, depth)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("pruned %d transactions in blocks 1 through %d\n", report.Pruned, report.PrunedHeight)
+		return nil
+	},
+}
+
+func openStorage() (db.KV, db.Storage, error) {
+	return db.CreateStorage(viper.GetString(FlagDBBackend), path.Join(viper.GetString(FlagDB), "root"))
+}
+
+func printIntegrityReport(report *db.IntegrityReport) {
+	fmt.Printf("checked %d blocks and %d transactions\n", report.Height, report.TransactionCount)
+	if report.PrunedCount > 0 {
+		fmt.Printf("%d transactions have been pruned\n", report.PrunedCount)
+	}
+	for _, issue := range report.Issues {
+		fmt.Println(issue.String())
+	}
+	if report.OK() {
+		fmt.Println("no issues found")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbCheckCmd)
+	dbCheckCmd.Flags().Bool(FlagRepair, false, "rebuild derived indexes from block data")
+	viper.BindPFlag(FlagRepair, dbCheckCmd.Flags().Lookup(FlagRepair))
+
+	dbCmd.AddCommand(dbExportCmd)
+	dbCmd.AddCommand(dbImportCmd)
+	dbExportCmd.Flags().Uint64(FlagHeight, 0, "last block to export, defaults to the latest block")
+	dbImportCmd.Flags().Uint64(FlagHeight, 0, "last block to import, defaults to the last block in the snapshot")
+
+	dbCmd.AddCommand(dbPruneCmd)
+	dbPruneCmd.Flags().Uint64(FlagDepth, 1000, "number of recent blocks to keep in full")
+}
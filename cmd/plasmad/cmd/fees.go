@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/service"
+	"github.com/kyokan/plasma/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const FlagFinalize = "finalize"
+
+var feesCmd = &cobra.Command{
+	Use:   "fees",
+	Short: "reports and withdraws the fees collected by the node operator",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := RequireFlags(FlagNodeURL, FlagContractAddr); err != nil {
+			return err
+		}
+		return RequireSigner()
+	},
+}
+
+var feesReportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "prints accumulated, committed, exited and withdrawable fees",
+	Long: `Totals the fee ledger and looks up the operator's withdrawable balance in the
+Plasma contract. The node must not be running; running nodes serve the same
+report over the GetFeeReport RPC.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level, storage, client, err := openFeeAccounts()
+		if err != nil {
+			return err
+		}
+		defer level.Close()
+
+		report, err := service.NewFeeReport(storage, client)
+		if err != nil {
+			return err
+		}
+		printFeeReport(report)
+		return nil
+	},
+}
+
+var feesWithdrawCmd = &cobra.Command{
+	Use:   "withdraw",
+	Short: "exits committed fees and withdraws them from the contract",
+	Long: `Checks that the fees recorded for every submitted block match the fees the
+Plasma contract committed, then starts a fee exit for each block that has not
+been exited yet. Nothing is exited if any block does not match. Fee exits
+must wait out the challenge period and be finalized before they can be
+withdrawn; pass --finalize to finalize matured exits first. Whatever balance
+the operator has in the contract is then withdrawn. The node must not be
+running.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		level, storage, client, err := openFeeAccounts()
+		if err != nil {
+			return err
+		}
+		defer level.Close()
+
+		entries, err := service.ExitableFees(storage, client)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			receipt, err := client.StartFeeExit(entry.BlockNumber)
+			if err != nil {
+				return err
+			}
+			if err := storage.MarkFeesExited(entry.BlockNumber, receipt.TxHash); err != nil {
+				return err
+			}
+			fmt.Printf("started exit of %s in fees from block %d in transaction %s\n", util.Big2Str(entry.Amount), entry.BlockNumber, receipt.TxHash.Hex())
+		}
+
+		if viper.GetBool(FlagFinalize) {
+			if _, err := client.FinalizeTransactionExits(); err != nil {
+				return err
+			}
+		}
+
+		balance, err := client.WithdrawableBalance(client.UserAddress())
+		if err != nil {
+			return err
+		}
+		if balance.Sign() == 0 {
+			fmt.Println("no finalized fees to withdraw")
+			return nil
+		}
+		receipt, err := client.Withdraw()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("withdrew %s in transaction %s\n", util.Big2Str(balance), receipt.TxHash.Hex())
+		return nil
+	},
+}
+
+func openFeeAccounts() (db.KV, db.Storage, eth.Client, error) {
+	signer, err := ParseSigner()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	client, err := eth.NewClient(viper.GetString(FlagNodeURL), viper.GetString(FlagContractAddr), signer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	level, storage, err := openStorage()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return level, storage, client, nil
+}
+
+func printFeeReport(report *service.FeeReport) {
+	fmt.Printf("accumulated:  %s\n", util.Big2Str(report.Accumulated))
+	fmt.Printf("committed:    %s (through block %d)\n", util.Big2Str(report.Committed), report.LastSubmittedBlock)
+	fmt.Printf("exited:       %s\n", util.Big2Str(report.Exited))
+	fmt.Printf("withdrawable: %s\n", util.Big2Str(report.Withdrawable))
+}
+
+func init() {
+	rootCmd.AddCommand(feesCmd)
+	feesCmd.AddCommand(feesReportCmd)
+	feesCmd.AddCommand(feesWithdrawCmd)
+	feesWithdrawCmd.Flags().Bool(FlagFinalize, false, "finalize matured transaction and fee exits before withdrawing")
+	viper.BindPFlag(FlagFinalize, feesWithdrawCmd.Flags().Lookup(FlagFinalize))
+}
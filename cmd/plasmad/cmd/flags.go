@@ -1,11 +1,24 @@
 package cmd
 
 const (
-	FlagConfig       = "config"
-	FlagDB           = "db"
-	FlagNodeURL      = "node-url"
-	FlagContractAddr = "contract-addr"
-	FlagPrivateKey   = "private-key"
-	FlagRPCPort      = "rpc-port"
-	FlagRESTPort     = "rest-port"
+	FlagConfig          = "config"
+	FlagDB              = "db"
+	FlagDBBackend       = "db-backend"
+	FlagNodeURL         = "node-url"
+	FlagContractAddr    = "contract-addr"
+	FlagPrivateKey      = "private-key"
+	FlagKeystoreFile    = "keystore-file"
+	FlagPassphraseFile  = "passphrase-file"
+	FlagRemoteSigner    = "remote-signer"
+	FlagSignerToken     = "signer-token-file"
+	FlagRPCHost         = "rpc-host"
+	FlagRPCPort         = "rpc-port"
+	FlagRESTHost        = "rest-host"
+	FlagRESTPort        = "rest-port"
+	FlagShutdownTimeout = "shutdown-timeout"
+	FlagTLSCert         = "tls-cert"
+	FlagTLSKey          = "tls-key"
+	FlagTLSClientCA     = "tls-client-ca"
+	FlagAPIKeysFile     = "api-keys-file"
+	FlagJWTSecretFile   = "jwt-secret-file"
 )
@@ -7,13 +7,19 @@ import (
 	"github.com/spf13/cobra"
 	"fmt"
 	"github.com/spf13/viper"
+	"github.com/kyokan/plasma/pkg/service"
 )
 
 var boundFlags = []string{
 	FlagDB,
+	FlagDBBackend,
 	FlagNodeURL,
 	FlagContractAddr,
 	FlagPrivateKey,
+	FlagKeystoreFile,
+	FlagPassphraseFile,
+	FlagRemoteSigner,
+	FlagSignerToken,
 }
 
 var configFile string
@@ -27,11 +33,17 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&configFile, FlagConfig, "", "filepath to Plasma's configuration file")
 	rootCmd.PersistentFlags().String(FlagDB, db.DefaultLevelLocation(), "filepath to Plasma's database")
+	rootCmd.PersistentFlags().String(FlagDBBackend, db.BackendLevelDB, "database backend, one of leveldb, bolt or memory")
 	rootCmd.PersistentFlags().String(FlagNodeURL, "", "full URL to a running Ethereum node")
 	rootCmd.PersistentFlags().String(FlagContractAddr, "", "address of the Plasma contract")
 	rootCmd.PersistentFlags().String(FlagPrivateKey, "", "node operator's private key")
+	rootCmd.PersistentFlags().String(FlagKeystoreFile, "", "encrypted JSON keystore holding the node operator's private key. Used instead of --private-key.")
+	rootCmd.PersistentFlags().String(FlagPassphraseFile, "", "file containing the keystore passphrase. Falls back to $PLASMA_PASSPHRASE, then a prompt.")
+	rootCmd.PersistentFlags().String(FlagRemoteSigner, "", "URL of a remote signer holding the node operator's key. Used instead of --private-key.")
+	rootCmd.PersistentFlags().String(FlagSignerToken, "", "file holding the token shared by the node and its remote signer")
+	rootCmd.PersistentFlags().Duration(FlagShutdownTimeout, service.DefaultShutdownTimeout, "how long to wait for services to stop on shutdown")
+	viper.BindPFlag(FlagShutdownTimeout, rootCmd.PersistentFlags().Lookup(FlagShutdownTimeout))
 	for _, flag := range boundFlags {
-		rootCmd.MarkFlagRequired(flag)
 		viper.BindPFlag(flag, rootCmd.PersistentFlags().Lookup(flag))
 	}
 }
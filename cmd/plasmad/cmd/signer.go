@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"github.com/kyokan/plasma/internal/signer"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	FlagSignerHost = "signer-host"
+	FlagSignerPort = "signer-port"
+)
+
+var signerCmd = &cobra.Command{
+	Use:   "signer",
+	Short: "serves the node operator's key to nodes started with --remote-signer",
+	Long: `Serves the remote signer protocol for the key in --keystore-file, so that the
+node itself never holds the operator key. Every request must carry the token in
+--signer-token-file as a bearer token, since the signer signs any hash it is
+given. Set --tls-cert and --tls-key unless the signer only listens on a trusted
+network.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return RequireFlags(FlagKeystoreFile, FlagSignerToken)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token, err := eth.LoadSignerToken(viper.GetString(FlagSignerToken))
+		if err != nil {
+			return err
+		}
+		certFile, _ := cmd.Flags().GetString(FlagTLSCert)
+		keyFile, _ := cmd.Flags().GetString(FlagTLSKey)
+		tlsConfig, err := rpc.ServerTLSConfig(certFile, keyFile, "")
+		if err != nil {
+			return err
+		}
+		keySigner, err := ParseKeystoreSigner()
+		if err != nil {
+			return err
+		}
+
+		host, _ := cmd.Flags().GetString(FlagSignerHost)
+		port, _ := cmd.Flags().GetUint(FlagSignerPort)
+		return signer.Start(keySigner, token, rpc.ServerConfig{
+			Host: host,
+			Port: int(port),
+			TLS:  tlsConfig,
+		}, viper.GetDuration(FlagShutdownTimeout))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(signerCmd)
+	signerCmd.Flags().String(FlagSignerHost, "127.0.0.1", "address for the signer to bind to")
+	signerCmd.Flags().Uint(FlagSignerPort, 6547, "port for the signer to listen on")
+	signerCmd.Flags().String(FlagTLSCert, "", "PEM certificate for the signer, enables TLS")
+	signerCmd.Flags().String(FlagTLSKey, "", "PEM private key for the TLS certificate")
+}
@@ -4,25 +4,54 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/kyokan/plasma/internal/root"
+	"time"
+)
+
+const (
+	FlagMinFee           = "min-fee"
+	FlagPeerRateLimit    = "peer-rate-limit"
+	FlagAddressRateLimit = "address-rate-limit"
+	FlagBanThreshold     = "ban-threshold"
+	FlagBanDuration      = "ban-duration"
+	FlagRateLimitExempt  = "rate-limit-exempt"
 )
 
 var startRootCmd = &cobra.Command{
 	Use:   "start-root",
 	Short: "starts running a Plasma root node",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		BindServerFlags(cmd)
+		if err := RequireFlags(FlagNodeURL, FlagContractAddr); err != nil {
+			return err
+		}
+		return RequireSigner()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		privateKey, err := ParsePrivateKey()
+		minFee, err := ParseMinFee()
+		if err != nil {
+			return err
+		}
+		signer, err := ParseSigner()
 		if err != nil {
 			return err
 		}
 
-		return root.Start(NewGlobalConfig(), privateKey)
+		config := NewGlobalConfig()
+		config.MinFee = minFee
+		return root.Start(config, signer)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(startRootCmd)
-	startRootCmd.Flags().Uint(FlagRPCPort, 6545, "port for the RPC server to listen on")
-	startRootCmd.Flags().Uint(FlagRESTPort, 6546, "port for the RPC server to listen on")
-	viper.BindPFlag(FlagRPCPort, startRootCmd.Flags().Lookup(FlagRPCPort))
-	viper.BindPFlag(FlagRESTPort, startRootCmd.Flags().Lookup(FlagRESTPort))
+	AddServerFlags(startRootCmd)
+	startRootCmd.Flags().String(FlagMinFee, "0", "smallest fee accepted on transactions, in wei")
+	startRootCmd.Flags().Float64(FlagPeerRateLimit, 20, "sends and confirmations per second allowed from each client IP, 0 disables the limit")
+	startRootCmd.Flags().Float64(FlagAddressRateLimit, 5, "sends per second allowed from each address, 0 disables the limit")
+	startRootCmd.Flags().Int(FlagBanThreshold, 10, "number of invalid transactions after which a client IP is banned, 0 disables banning")
+	startRootCmd.Flags().Duration(FlagBanDuration, time.Hour, "how long client IPs are banned for")
+	startRootCmd.Flags().StringSlice(FlagRateLimitExempt, nil, "client IPs, such as validators', that are never rate limited or banned")
+	for _, flag := range []string{FlagMinFee, FlagPeerRateLimit, FlagAddressRateLimit, FlagBanThreshold, FlagBanDuration, FlagRateLimitExempt} {
+		viper.BindPFlag(flag, startRootCmd.Flags().Lookup(flag))
+	}
 }
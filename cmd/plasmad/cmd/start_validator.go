@@ -4,27 +4,85 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/kyokan/plasma/internal/validator"
 	"github.com/spf13/viper"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"io/ioutil"
+	"strings"
 )
 
-const FlagRootURL = "root-url"
+const (
+	FlagRootURL        = "root-url"
+	FlagSnapshot       = "snapshot"
+	FlagSnapshotHeight = "snapshot-height"
+	FlagPruneDepth     = "prune-depth"
+	FlagStrictSigsFrom = "strict-signatures-from"
+	FlagRootTLS        = "root-tls"
+	FlagRootCACert     = "root-ca-cert"
+	FlagRootClientCert = "root-client-cert"
+	FlagRootClientKey  = "root-client-key"
+	FlagRootTokenFile  = "root-token-file"
+)
 
 var startValidatorCmd = &cobra.Command{
 	Use:   "start-validator",
 	Short: "starts running a Plasma validator node",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		BindServerFlags(cmd)
+		if err := RequireFlags(FlagNodeURL, FlagContractAddr); err != nil {
+			return err
+		}
+		return RequireSigner()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		privateKey, err := ParsePrivateKey()
+		signer, err := ParseSigner()
 		if err != nil {
 			return err
 		}
 
-		return validator.Start(NewGlobalConfig(), viper.GetString(FlagRootURL), privateKey)
+		var snapshot *validator.TrustedSnapshot
+		if snapshotPath := viper.GetString(FlagSnapshot); snapshotPath != "" {
+			snapshot = &validator.TrustedSnapshot{
+				Path:   snapshotPath,
+				Height: viper.GetUint64(FlagSnapshotHeight),
+			}
+		}
+
+		rootOpts := rpc.ClientOptions{
+			TLS:            viper.GetBool(FlagRootTLS),
+			CACertFile:     viper.GetString(FlagRootCACert),
+			ClientCertFile: viper.GetString(FlagRootClientCert),
+			ClientKeyFile:  viper.GetString(FlagRootClientKey),
+		}
+		if tokenFile := viper.GetString(FlagRootTokenFile); tokenFile != "" {
+			token, err := ioutil.ReadFile(tokenFile)
+			if err != nil {
+				return err
+			}
+			rootOpts.Token = strings.TrimSpace(string(token))
+		}
+
+		return validator.Start(NewGlobalConfig(), viper.GetString(FlagRootURL), rootOpts, snapshot, signer)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(startValidatorCmd)
 	startValidatorCmd.Flags().String(FlagRootURL, "localhost:6545", "URL belonging to the root node")
-	startValidatorCmd.Flags().Uint(FlagRPCPort, 6545, "port for the RPC server to listen on")
+	AddServerFlags(startValidatorCmd)
+	startValidatorCmd.Flags().String(FlagSnapshot, "", "snapshot to bootstrap an empty database from before syncing")
+	startValidatorCmd.Flags().Uint64(FlagSnapshotHeight, 0, "height up to which the snapshot is trusted, defaults to the snapshot's height")
+	startValidatorCmd.Flags().Uint64(FlagPruneDepth, 0, "number of blocks after which spent transactions are pruned, 0 disables pruning")
 	viper.BindPFlag(FlagRootURL, startValidatorCmd.Flags().Lookup(FlagRootURL))
-	viper.BindPFlag(FlagRPCPort, startValidatorCmd.Flags().Lookup(FlagRPCPort))
+	viper.BindPFlag(FlagSnapshot, startValidatorCmd.Flags().Lookup(FlagSnapshot))
+	viper.BindPFlag(FlagSnapshotHeight, startValidatorCmd.Flags().Lookup(FlagSnapshotHeight))
+	viper.BindPFlag(FlagPruneDepth, startValidatorCmd.Flags().Lookup(FlagPruneDepth))
+	startValidatorCmd.Flags().Uint64(FlagStrictSigsFrom, 0, "first block whose signatures must be canonical, set to the root's first block after it began requiring them")
+	viper.BindPFlag(FlagStrictSigsFrom, startValidatorCmd.Flags().Lookup(FlagStrictSigsFrom))
+	startValidatorCmd.Flags().Bool(FlagRootTLS, false, "connect to the root node over TLS")
+	startValidatorCmd.Flags().String(FlagRootCACert, "", "PEM CA certificates to verify the root node with, instead of the system's")
+	startValidatorCmd.Flags().String(FlagRootClientCert, "", "PEM client certificate to authenticate to the root node with")
+	startValidatorCmd.Flags().String(FlagRootClientKey, "", "PEM private key for the client certificate")
+	startValidatorCmd.Flags().String(FlagRootTokenFile, "", "file holding an API key or JWT to authenticate to the root node with")
+	for _, flag := range []string{FlagRootTLS, FlagRootCACert, FlagRootClientCert, FlagRootClientKey, FlagRootTokenFile} {
+		viper.BindPFlag(flag, startValidatorCmd.Flags().Lookup(flag))
+	}
 }
@@ -3,25 +3,138 @@ package cmd
 import (
 	"github.com/kyokan/plasma/pkg/config"
 	"github.com/spf13/viper"
-	"crypto/ecdsa"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/pkg/errors"
+	"fmt"
+	"github.com/kyokan/plasma/pkg/keystore"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/util"
+	"math/big"
+	"github.com/spf13/cobra"
 )
 
 func NewGlobalConfig() *config.GlobalConfig {
 	return &config.GlobalConfig{
-		DBPath:       viper.GetString(FlagDB),
-		NodeURL:      viper.GetString(FlagNodeURL),
-		RPCPort:      viper.GetInt(FlagRPCPort),
-		ContractAddr: viper.GetString(FlagContractAddr),
+		DBPath:               viper.GetString(FlagDB),
+		DBBackend:            viper.GetString(FlagDBBackend),
+		NodeURL:              viper.GetString(FlagNodeURL),
+		RPCPort:              viper.GetInt(FlagRPCPort),
+		RESTPort:             viper.GetInt(FlagRESTPort),
+		RPCHost:              viper.GetString(FlagRPCHost),
+		RESTHost:             viper.GetString(FlagRESTHost),
+		ContractAddr:         viper.GetString(FlagContractAddr),
+		ShutdownTimeout:      viper.GetDuration(FlagShutdownTimeout),
+		PruneDepth:           viper.GetUint64(FlagPruneDepth),
+		StrictSignaturesFrom: viper.GetUint64(FlagStrictSigsFrom),
+		PeerRateLimit:        viper.GetFloat64(FlagPeerRateLimit),
+		AddressRateLimit:     viper.GetFloat64(FlagAddressRateLimit),
+		BanThreshold:         viper.GetInt(FlagBanThreshold),
+		BanDuration:          viper.GetDuration(FlagBanDuration),
+		RateLimitExempt:      viper.GetStringSlice(FlagRateLimitExempt),
+		TLSCertFile:          viper.GetString(FlagTLSCert),
+		TLSKeyFile:           viper.GetString(FlagTLSKey),
+		TLSClientCAFile:      viper.GetString(FlagTLSClientCA),
+		APIKeysFile:          viper.GetString(FlagAPIKeysFile),
+		JWTSecretFile:        viper.GetString(FlagJWTSecretFile),
 	}
 }
 
-func ParsePrivateKey() (*ecdsa.PrivateKey, error) {
-	privateKeyStr := viper.GetString(FlagPrivateKey)
-	privateKey, err := crypto.HexToECDSA(privateKeyStr)
+// AddServerFlags adds the flags for the addresses a node's RPC and REST
+// servers listen on, their TLS settings and the credentials they accept
+// for write requests to cmd.
+func AddServerFlags(cmd *cobra.Command) {
+	cmd.Flags().String(FlagRPCHost, "", "address for the RPC server to bind to, all interfaces if empty")
+	cmd.Flags().Uint(FlagRPCPort, 6545, "port for the RPC server to listen on")
+	cmd.Flags().String(FlagRESTHost, "", "address for the REST server to bind to, all interfaces if empty")
+	cmd.Flags().Uint(FlagRESTPort, 6546, "port for the REST server to listen on")
+	cmd.Flags().String(FlagTLSCert, "", "PEM certificate for the RPC and REST servers, enables TLS")
+	cmd.Flags().String(FlagTLSKey, "", "PEM private key for the TLS certificate")
+	cmd.Flags().String(FlagTLSClientCA, "", "PEM CA certificates whose client certificates are authenticated for write requests")
+	cmd.Flags().String(FlagAPIKeysFile, "", "file of API keys, one per line, accepted as bearer tokens for write requests")
+	cmd.Flags().String(FlagJWTSecretFile, "", "file holding the secret of HS256 JWTs accepted as bearer tokens for write requests")
+}
+
+// BindServerFlags binds the flags added by AddServerFlags to viper. Both
+// start commands define them, so they are bound when a command runs rather
+// than in init, where the last command to bind them would win.
+func BindServerFlags(cmd *cobra.Command) {
+	for _, flag := range []string{FlagRPCHost, FlagRPCPort, FlagRESTHost, FlagRESTPort, FlagTLSCert, FlagTLSKey, FlagTLSClientCA, FlagAPIKeysFile, FlagJWTSecretFile} {
+		viper.BindPFlag(flag, cmd.Flags().Lookup(flag))
+	}
+}
+
+// ParseMinFee returns the minimum fee in wei, or nil if none is set.
+func ParseMinFee() (*big.Int, error) {
+	str := viper.GetString(FlagMinFee)
+	if str == "" {
+		return nil, nil
+	}
+	minFee, err := util.Str2Big(str)
+	if err != nil || minFee.Sign() < 0 {
+		return nil, fmt.Errorf("invalid %s \"%s\"", FlagMinFee, str)
+	}
+	return minFee, nil
+}
+
+// RequireFlags returns an error if any of the given flags has not been set
+// either on the command line or in the config file. Flags shared by all
+// commands are checked here rather than marked as required, since commands
+// like db check do not need them.
+func RequireFlags(flags ...string) error {
+	for _, flag := range flags {
+		if viper.GetString(flag) == "" {
+			return fmt.Errorf("required flag \"%s\" not set", flag)
+		}
+	}
+	return nil
+}
+
+// RequireSigner returns an error unless exactly one way of signing as the
+// node operator has been set.
+func RequireSigner() error {
+	var count int
+	for _, flag := range []string{FlagPrivateKey, FlagKeystoreFile, FlagRemoteSigner} {
+		if viper.GetString(flag) != "" {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("exactly one of \"%s\", \"%s\" or \"%s\" must be set", FlagPrivateKey, FlagKeystoreFile, FlagRemoteSigner)
+	}
+	if viper.GetString(FlagRemoteSigner) != "" {
+		return RequireFlags(FlagSignerToken)
+	}
+	return nil
+}
+
+// ParseSigner returns a signer for the node operator's key, which is held
+// in-process, decrypted from a keystore file or kept by a remote signer.
+func ParseSigner() (eth.Signer, error) {
+	if remoteSigner := viper.GetString(FlagRemoteSigner); remoteSigner != "" {
+		token, err := eth.LoadSignerToken(viper.GetString(FlagSignerToken))
+		if err != nil {
+			return nil, err
+		}
+		return eth.NewRemoteSigner(remoteSigner, token)
+	}
+
+	if viper.GetString(FlagKeystoreFile) != "" {
+		return ParseKeystoreSigner()
+	}
+
+	privateKey, err := crypto.HexToECDSA(viper.GetString(FlagPrivateKey))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse private key")
 	}
-	return privateKey, nil
+	return eth.NewPrivateKeySigner(privateKey), nil
+}
+
+// ParseKeystoreSigner decrypts the key in the keystore file, reading the
+// passphrase from the passphrase file if one is set.
+func ParseKeystoreSigner() (eth.Signer, error) {
+	passphrase := keystore.PromptPassphrase("Keystore passphrase: ", false)
+	if passphraseFile := viper.GetString(FlagPassphraseFile); passphraseFile != "" {
+		passphrase = keystore.FilePassphrase(passphraseFile)
+	}
+	return keystore.NewSigner(viper.GetString(FlagKeystoreFile), passphrase)
 }
@@ -0,0 +1,119 @@
+package root
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/kyokan/plasma/pkg/service"
+	"github.com/kyokan/plasma/pkg/validation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+)
+
+var grpcCodes = map[validation.Code]codes.Code{
+	validation.CodeNotFound:                  codes.NotFound,
+	validation.CodeNegativeOutput:            codes.InvalidArgument,
+	validation.CodeConfirmSigMismatch:        codes.InvalidArgument,
+	validation.CodeInvalidSignature:          codes.InvalidArgument,
+	validation.CodeNonCanonicalSignature:     codes.InvalidArgument,
+	validation.CodeIdenticalInputs:           codes.InvalidArgument,
+	validation.CodeInputOutputValueMismatch:  codes.InvalidArgument,
+	validation.CodeDepositDefinedInput1:      codes.InvalidArgument,
+	validation.CodeDepositNonEmptyConfirmSig: codes.InvalidArgument,
+	validation.CodeTxNotFound:                codes.FailedPrecondition,
+	validation.CodeDoubleSpent:               codes.FailedPrecondition,
+	validation.CodeFeeTooLow:                 codes.FailedPrecondition,
+	validation.CodeRateLimited:               codes.ResourceExhausted,
+	validation.CodeMempoolFull:               codes.ResourceExhausted,
+	validation.CodeBanned:                    codes.PermissionDenied,
+	validation.CodeUnauthenticated:           codes.Unauthenticated,
+	validation.CodeUnavailable:               codes.Unavailable,
+}
+
+// httpStatuses maps gRPC codes to REST status codes. Unknown errors are
+// reported as 400, as they always have been.
+var httpStatuses = map[codes.Code]int{
+	codes.Unknown:            http.StatusBadRequest,
+	codes.NotFound:           http.StatusNotFound,
+	codes.InvalidArgument:    http.StatusBadRequest,
+	codes.FailedPrecondition: http.StatusConflict,
+	codes.ResourceExhausted:  http.StatusTooManyRequests,
+	codes.PermissionDenied:   http.StatusForbidden,
+	codes.Unauthenticated:    http.StatusUnauthorized,
+	codes.Unavailable:        http.StatusServiceUnavailable,
+}
+
+// describeError adds the mempool's errors to validation.Describe.
+func describeError(err error) *validation.ErrorDetails {
+	switch err {
+	case service.ErrMempoolFull:
+		return &validation.ErrorDetails{
+			Code:    validation.CodeMempoolFull,
+			Message: err.Error(),
+		}
+	case service.ErrMempoolStopping:
+		return &validation.ErrorDetails{
+			Code:    validation.CodeUnavailable,
+			Message: err.Error(),
+		}
+	default:
+		return validation.Describe(err)
+	}
+}
+
+func grpcCode(details *validation.ErrorDetails) codes.Code {
+	code, ok := grpcCodes[details.Code]
+	if !ok {
+		return codes.Unknown
+	}
+	return code
+}
+
+// statusError converts err into a gRPC status carrying a pb.ErrorDetails.
+// Errors that are already statuses are returned as is, unless they also
+// have a code, as rpc.ErrUnauthenticated does.
+func statusError(err error) error {
+	details := describeError(err)
+	if _, ok := status.FromError(err); ok && details.Code == validation.CodeUnknown {
+		return err
+	}
+
+	st := status.New(grpcCode(details), details.Message)
+	withDetails, detailsErr := st.WithDetails(&pb.ErrorDetails{
+		Code:     string(details.Code),
+		Message:  details.Message,
+		Metadata: details.Metadata,
+	})
+	if detailsErr != nil {
+		log.WithError(logger, detailsErr).Error("failed to attach error details")
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// errorStatusInterceptor converts the errors returned by RPCs into gRPC
+// statuses. It must be outermost, so that other interceptors see the
+// original errors.
+func errorStatusInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	res, err := handler(ctx, req)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return res, nil
+}
+
+// abortWithError writes err to the REST client as
+// {"error": {"code": ..., "message": ..., "metadata": {...}}}.
+func abortWithError(c *gin.Context, err error) {
+	details := describeError(err)
+	httpStatus, ok := httpStatuses[grpcCode(details)]
+	if !ok {
+		httpStatus = http.StatusBadRequest
+	}
+	c.AbortWithStatusJSON(httpStatus, gin.H{
+		"error": details,
+	})
+}
@@ -10,13 +10,12 @@ import (
 	"github.com/kyokan/plasma/pkg/db"
 	"github.com/kyokan/plasma/util"
 	"github.com/sirupsen/logrus"
-	"fmt"
 	"github.com/pkg/errors"
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/kyokan/plasma/pkg/service"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/gin-contrib/cors"
+	"github.com/kyokan/plasma/pkg/rpc"
 )
 
 var restLogger = log.ForSubsystem("RESTServer")
@@ -25,7 +24,11 @@ type RESTServer struct {
 	storage   db.Storage
 	mpool     *service.Mempool
 	confirmer *service.TransactionConfirmer
-	port      int
+	guard     *service.SpamGuard
+	checker   *service.HealthChecker
+	auth      *rpc.Authenticator
+	gateway   http.Handler
+	listen    rpc.ServerConfig
 
 	server *http.Server
 	engine *gin.Engine
@@ -38,39 +41,59 @@ type ConfirmationRequest struct {
 	TransactionIndex uint32 `json:"transactionIndex"`
 	ConfirmSig0      string `json:"confirmSig0"`
 	ConfirmSig1      string `json:"confirmSig1"`
+	// ConfirmSigs may be given instead of ConfirmSig0 and ConfirmSig1.
+	ConfirmSigs []string `json:"confirmSigs"`
 }
 
-func NewRESTServer(storage db.Storage, mpool *service.Mempool, confirmer *service.TransactionConfirmer, port int) *RESTServer {
+func NewRESTServer(storage db.Storage, mpool *service.Mempool, confirmer *service.TransactionConfirmer, guard *service.SpamGuard, checker *service.HealthChecker, auth *rpc.Authenticator, gateway http.Handler, listen rpc.ServerConfig) *RESTServer {
 	return &RESTServer{
 		storage:   storage,
 		mpool:     mpool,
 		confirmer: confirmer,
-		port:      port,
+		guard:     guard,
+		checker:   checker,
+		auth:      auth,
+		gateway:   gateway,
+		listen:    listen,
 	}
 }
 
 func (r *RESTServer) Start() error {
 	r.engine = gin.Default()
 	r.engine.Use(cors.Default())
+	// these routes predate the gateway and are kept for existing clients,
+	// such as kyokan-plasma-client. New endpoints are only served under
+	// rpc.GatewayPrefix.
 	r.engine.GET("/balances/:address", r.wrapHandler(r.GetBalance))
 	r.engine.GET("/utxos/:address", r.wrapHandler(r.GetUTXOs))
 	r.engine.GET("/blocks/:height", r.wrapHandler(r.GetBlock))
-	r.engine.POST("/send", r.wrapHandler(r.Send))
-	r.engine.POST("/confirm", r.wrapHandler(r.Confirm))
+	r.engine.POST("/send", spamGuardMiddleware(r.guard), authMiddleware(r.auth), r.wrapHandler(r.Send))
+	r.engine.POST("/confirm", spamGuardMiddleware(r.guard), authMiddleware(r.auth), r.wrapHandler(r.Confirm))
+	r.engine.Any(rpc.GatewayPrefix+"/*path", gin.WrapH(r.gateway))
+	r.engine.GET("/healthz", gin.WrapH(r.checker.LivenessHandler()))
+	r.engine.GET("/readyz", gin.WrapH(r.checker.ReadinessHandler()))
 	r.server = &http.Server{
-		Addr:    fmt.Sprintf(":%d", r.port),
-		Handler: r.engine,
+		Addr:      r.listen.Addr(),
+		Handler:   r.engine,
+		TLSConfig: r.listen.TLS,
 	}
 
 	go func() {
-		if err := r.server.ListenAndServe(); err != nil {
+		var err error
+		if r.listen.TLS != nil {
+			err = r.server.ListenAndServeTLS("", "")
+		} else {
+			err = r.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.WithError(restLogger, err).Error("encountered error in rest server")
 			return
 		}
 	}()
 
 	restLogger.WithFields(logrus.Fields{
-		"port": r.port,
+		"addr": r.server.Addr,
+		"tls":  r.listen.TLS != nil,
 	}).Info("started REST server")
 
 	return nil
@@ -88,7 +111,7 @@ func (r *RESTServer) GetBalance(c *gin.Context) (interface{}, error) {
 	}
 	addr := common.HexToAddress(addrStr)
 	bal, err := r.storage.Balance(addr)
-	if err == leveldb.ErrNotFound {
+	if err == db.ErrNotFound {
 		return &gin.H{
 			"balance": "0",
 		}, nil
@@ -142,6 +165,9 @@ func (r *RESTServer) Send(c *gin.Context) (interface{}, error) {
 	if err := c.ShouldBindJSON(&tx); err != nil {
 		return nil, err
 	}
+	if err := r.guard.AdmitTransaction(&tx); err != nil {
+		return nil, err
+	}
 
 	inclusion := r.mpool.Append(tx)
 	if inclusion.Error != nil {
@@ -161,24 +187,20 @@ func (r *RESTServer) Confirm(c *gin.Context) (interface{}, error) {
 	if err := c.ShouldBindJSON(&confirmation); err != nil {
 		return nil, err
 	}
-	sig0Bytes, err := hexutil.Decode(confirmation.ConfirmSig0)
-	if err != nil {
-		return nil, err
+	sigStrs := confirmation.ConfirmSigs
+	if len(sigStrs) == 0 {
+		sigStrs = []string{confirmation.ConfirmSig0, confirmation.ConfirmSig1}
 	}
-	sig1Bytes, err := hexutil.Decode(confirmation.ConfirmSig1)
-	if err != nil {
-		return nil, err
+	var sigs [][]byte
+	for _, sigStr := range sigStrs {
+		sig, err := hexutil.Decode(sigStr)
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, sig)
 	}
 
-	var sig0 chain.Signature
-	copy(sig0[:], sig0Bytes)
-	var sig1 chain.Signature
-	copy(sig1[:], sig1Bytes)
-
-	tx, err := r.confirmer.Confirm(confirmation.BlockNumber, confirmation.TransactionIndex, [2]chain.Signature{
-		sig0,
-		sig1,
-	})
+	tx, err := r.confirmer.Confirm(confirmation.BlockNumber, confirmation.TransactionIndex, chain.SignaturesFromProto(sigs))
 	if err != nil {
 		return nil, err
 	}
@@ -191,7 +213,8 @@ func (r *RESTServer) wrapHandler(f WrappableHandlerFunc) gin.HandlerFunc {
 		res, err := f(c)
 		if err != nil {
 			log.WithError(restLogger, err).Error("received error in URL handler")
-			c.Status(http.StatusBadRequest)
+			c.Error(err)
+			abortWithError(c, err)
 		} else {
 			c.JSON(http.StatusOK, res)
 		}
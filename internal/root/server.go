@@ -2,65 +2,112 @@ package root
 
 import (
 	"context"
-	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/kyokan/plasma/pkg/db"
 	"github.com/kyokan/plasma/pkg/rpc"
 	"github.com/kyokan/plasma/pkg/rpc/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"net"
 	"github.com/kyokan/plasma/pkg/service"
 	"github.com/pkg/errors"
 	"github.com/kyokan/plasma/pkg/log"
 	"github.com/sirupsen/logrus"
+	"github.com/kyokan/plasma/pkg/validation"
+	"github.com/kyokan/plasma/pkg/eth"
+	"math/big"
+	"google.golang.org/grpc/credentials"
 )
 
 type Server struct {
 	storage   db.Storage
-	ctx       context.Context
+	ethClient eth.Client
 	mpool     *service.Mempool
 	confirmer *service.TransactionConfirmer
+	policy    *validation.SignaturePolicy
+	guard     *service.SpamGuard
+	auth      *rpc.Authenticator
+	gateway   *rpc.Gateway
+	listen    rpc.ServerConfig
+
+	server *grpc.Server
+	health *health.Server
 }
 
 var logger = log.ForSubsystem("RootServer")
 
-func NewServer(ctx context.Context, storage db.Storage, mPool *service.Mempool, confirmer *service.TransactionConfirmer) (*Server) {
+func NewServer(storage db.Storage, ethClient eth.Client, mPool *service.Mempool, confirmer *service.TransactionConfirmer, policy *validation.SignaturePolicy, guard *service.SpamGuard, auth *rpc.Authenticator, gateway *rpc.Gateway, listen rpc.ServerConfig) (*Server) {
 	return &Server{
 		storage:   storage,
-		ctx:       ctx,
+		ethClient: ethClient,
 		mpool:     mPool,
 		confirmer: confirmer,
+		policy:    policy,
+		guard:     guard,
+		auth:      auth,
+		gateway:   gateway,
+		listen:    listen,
+		health:    health.NewServer(),
 	}
 }
 
-func (r *Server) Start(rpcPort int) error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", rpcPort))
+func (r *Server) Start() error {
+	lis, err := net.Listen("tcp", r.listen.Addr())
 	if err != nil {
 		return err
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterRootServer(s, r)
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(rpc.ChainUnaryServer(
+			errorStatusInterceptor,
+			spamGuardInterceptor(r.guard),
+			rpc.AuthInterceptor(r.auth),
+		)),
+	}
+	if r.listen.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(r.listen.TLS)))
+	}
+	r.server = grpc.NewServer(opts...)
+	pb.RegisterRootServer(r.server, r)
+	healthpb.RegisterHealthServer(r.server, r.health)
 
 	go func() {
-		if err := s.Serve(lis); err != nil {
-			log.WithError(logger, err)
+		if err := r.server.Serve(lis); err != nil {
+			log.WithError(logger, err).Error("encountered error in gRPC server")
 		}
 	}()
-
-	go func() {
-		<-r.ctx.Done()
-		s.Stop()
-	}()
+	if r.gateway != nil {
+		go r.server.Serve(r.gateway.Listener())
+	}
 
 	logger.WithFields(logrus.Fields{
-		"port": rpcPort,
+		"addr": lis.Addr().String(),
+		"tls":  r.listen.TLS != nil,
 	}).Info("started gRPC server")
 
 	return nil
 }
 
+// Stop stops accepting new connections and blocks until in-flight RPCs,
+// such as sends awaiting block inclusion, have completed.
+func (r *Server) Stop() error {
+	r.server.GracefulStop()
+	return nil
+}
+
+// SetHealth updates the status reported by the gRPC health service, both
+// for the server as a whole and for the Root service.
+func (r *Server) SetHealth(report service.HealthReport) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if report.Healthy {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	r.health.SetServingStatus("", status)
+	r.health.SetServingStatus("pb.Root", status)
+}
+
 func (r *Server) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
 	addr := common.BytesToAddress(req.Address)
 	bal, err := r.storage.Balance(addr)
@@ -110,6 +157,9 @@ func (r *Server) Send(ctx context.Context, req *pb.SendRequest) (*pb.SendRespons
 	if err != nil {
 		return nil, err
 	}
+	if err := r.guard.AdmitTransaction(tx); err != nil {
+		return nil, err
+	}
 
 	inclusion := r.mpool.Append(*tx)
 	if inclusion.Error != nil {
@@ -130,15 +180,32 @@ func (r *Server) Send(ctx context.Context, req *pb.SendRequest) (*pb.SendRespons
 }
 
 func (r *Server) Confirm(ctx context.Context, req *pb.ConfirmRequest) (*pb.ConfirmedTransaction, error) {
-	var sig0 chain.Signature
-	copy(sig0[:], req.ConfirmSig0)
-	var sig1 chain.Signature
-	copy(sig1[:], req.ConfirmSig1)
-
-	tx, err := r.confirmer.Confirm(req.BlockNumber, req.TransactionIndex, [2]chain.Signature{
-		sig0,
-		sig1,
-	})
+	sigs := req.ConfirmSigs
+	if len(sigs) == 0 {
+		sigs = [][]byte{req.ConfirmSig0, req.ConfirmSig1}
+	}
+
+	tx, err := r.confirmer.Confirm(req.BlockNumber, req.TransactionIndex, chain.SignaturesFromProto(sigs))
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Proto(), nil
+}
+
+func (r *Server) GetConfirmSigs(ctx context.Context, req *pb.GetConfirmSigsRequest) (*pb.GetConfirmSigsResponse, error) {
+	sigs, err := r.storage.ConfirmSigsFor(req.BlockNumber, req.TransactionIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.GetConfirmSigsResponse{
+		ConfirmSigs: chain.SignaturesProto(sigs[:]),
+	}, nil
+}
+
+func (r *Server) GetTransaction(ctx context.Context, req *pb.GetTransactionRequest) (*pb.ConfirmedTransaction, error) {
+	tx, err := r.storage.FindTransactionByBlockNumTxIdx(req.BlockNumber, req.TransactionIndex)
 	if err != nil {
 		return nil, err
 	}
@@ -146,6 +213,28 @@ func (r *Server) Confirm(ctx context.Context, req *pb.ConfirmRequest) (*pb.Confi
 	return tx.Proto(), nil
 }
 
+func (r *Server) GetDeposit(ctx context.Context, req *pb.GetDepositRequest) (*pb.ConfirmedTransaction, error) {
+	tx, err := findDeposit(r.storage, rpc.DeserializeBig(req.DepositNonce))
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Proto(), nil
+}
+
+func (r *Server) GetSigningDomain(context.Context, *pb.EmptyRequest) (*pb.GetSigningDomainResponse, error) {
+	var versions []uint32
+	for _, version := range r.policy.Versions {
+		versions = append(versions, uint32(version))
+	}
+
+	return &pb.GetSigningDomainResponse{
+		ChainId:           rpc.SerializeBig(r.policy.Domain.ChainID),
+		Contract:          r.policy.Domain.Contract.Bytes(),
+		SignatureVersions: versions,
+	}, nil
+}
+
 func (r *Server) BlockHeight(context.Context, *pb.EmptyRequest) (*pb.BlockHeightResponse, error) {
 	latest, err := r.storage.LatestBlock()
 	if err != nil {
@@ -157,6 +246,24 @@ func (r *Server) BlockHeight(context.Context, *pb.EmptyRequest) (*pb.BlockHeight
 	}, nil
 }
 
+func (r *Server) GetFeeReport(context.Context, *pb.EmptyRequest) (*pb.GetFeeReportResponse, error) {
+	report, err := service.NewFeeReport(r.storage, r.ethClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return report.Proto(), nil
+}
+
+func (r *Server) EstimateFee(context.Context, *pb.EmptyRequest) (*pb.EstimateFeeResponse, error) {
+	estimate, err := service.EstimateFee(r.storage, r.mpool)
+	if err != nil {
+		return nil, err
+	}
+
+	return estimate.Proto(), nil
+}
+
 func (r *Server) Sync(req *pb.SyncRequest, stream pb.Root_SyncServer) error {
 	head, err := r.storage.LatestBlock()
 	if err != nil {
@@ -197,3 +304,16 @@ func (r *Server) getBlock(height uint64) (*pb.GetBlockResponse, error) {
 		Metadata:              meta.Proto(),
 	}, nil
 }
+
+// findDeposit returns the deposit transaction with the given nonce, or
+// db.ErrNotFound if it has not been processed.
+func findDeposit(storage db.Storage, nonce *big.Int) (*chain.ConfirmedTransaction, error) {
+	tx, err := storage.FindDoubleSpendingDeposit(nonce)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, db.ErrNotFound
+	}
+	return tx, nil
+}
@@ -0,0 +1,60 @@
+package root
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"github.com/kyokan/plasma/pkg/service"
+	"google.golang.org/grpc"
+	"net"
+)
+
+// spamGuardInterceptor turns away banned and rate limited peers before
+// write RPCs run, and records the invalid transactions they send.
+func spamGuardInterceptor(guard *service.SpamGuard) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !rpc.WriteMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		peer := rpc.PeerHost(ctx)
+		if err := guard.AdmitPeer(peer); err != nil {
+			return nil, err
+		}
+		res, err := handler(ctx, req)
+		guard.Record(peer, err)
+		return res, err
+	}
+}
+
+// spamGuardMiddleware is spamGuardInterceptor for the REST server. Peers
+// are identified by the connection's remote address rather than
+// X-Forwarded-For, which clients can set to anything.
+func spamGuardMiddleware(guard *service.SpamGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		peer, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			peer = c.Request.RemoteAddr
+		}
+
+		if err := guard.AdmitPeer(peer); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Next()
+		if last := c.Errors.Last(); last != nil {
+			guard.Record(peer, last.Err)
+		}
+	}
+}
+
+// authMiddleware is rpc.AuthInterceptor for the REST server. It runs after
+// the spam guard, so that banned peers cannot probe for credentials.
+func authMiddleware(auth *rpc.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := auth.AuthenticateRequest(c.Request); err != nil {
+			abortWithError(c, err)
+		}
+	}
+}
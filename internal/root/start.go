@@ -1,21 +1,21 @@
 package root
 
 import (
-	"context"
-	"crypto/ecdsa"
-	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/kyokan/plasma/pkg/config"
 	"github.com/kyokan/plasma/pkg/db"
 	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/rpc"
 	"github.com/kyokan/plasma/pkg/service"
+	"github.com/kyokan/plasma/pkg/validation"
 	"os"
-	"os/signal"
 	"path"
 	"runtime/trace"
 	"time"
 )
 
-func Start(config *config.GlobalConfig, privateKey *ecdsa.PrivateKey) error {
+func Start(config *config.GlobalConfig, signer eth.Signer) error {
 	f, err := os.Create(time.Now().Format("daemon-trace-2006-01-02T150405.pprof"))
 	if err != nil {
 		panic(err)
@@ -27,49 +27,82 @@ func Start(config *config.GlobalConfig, privateKey *ecdsa.PrivateKey) error {
 	}
 	defer trace.Stop()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	ethClient, err := eth.NewClient(config.NodeURL, config.ContractAddr, privateKey)
+	tlsConfig, err := rpc.ServerTLSConfig(config.TLSCertFile, config.TLSKeyFile, config.TLSClientCAFile)
 	if err != nil {
 		return err
 	}
-
-	ldb, storage, err := db.CreateLevelStorage(path.Join(config.DBPath, "root"))
+	auth, err := rpc.LoadAuthenticator(config.APIKeysFile, config.JWTSecretFile, config.TLSClientCAFile != "")
 	if err != nil {
 		return err
 	}
-	defer ldb.Close()
+	if auth != nil && tlsConfig == nil {
+		logger.Warn("write requests require credentials, but TLS is disabled so they are sent in plaintext")
+	}
 
-	mpool := service.NewMempool(storage, ethClient)
-	err = mpool.Start()
+	ethClient, err := eth.NewClient(config.NodeURL, config.ContractAddr, signer)
 	if err != nil {
 		return err
 	}
 
-	chainsaw := service.NewChainsaw(ethClient, mpool, storage)
-	if err := chainsaw.Start(); err != nil {
+	ldb, storage, err := db.CreateStorage(config.DBBackend, path.Join(config.DBPath, "root"))
+	if err != nil {
+		return err
+	}
+	if err := db.MarkRootNode(ldb); err != nil {
+		ldb.Close()
 		return err
 	}
 
-	confirmer := service.NewTransactionConfirmer(storage, ethClient)
-	submitter := service.NewBlockSubmitter(ethClient, storage)
-	if err := submitter.Start(); err != nil {
+	chainID, err := ethClient.ChainID()
+	if err != nil {
+		ldb.Close()
 		return err
 	}
+	domain := chain.NewDomain(chainID, common.HexToAddress(config.ContractAddr))
+	policy := validation.NewSignaturePolicy(domain)
 
+	mpool := service.NewMempool(storage, ethClient, policy, config.MinFee)
+	chainsaw := service.NewChainsaw(ethClient, mpool, storage)
+	confirmer := service.NewTransactionConfirmer(storage, ethClient)
+	submitter := service.NewBlockSubmitter(ethClient, storage)
 	p := service.NewPlasmaNode(storage, mpool, ethClient, submitter)
-	go p.Start()
-
-	server := NewServer(ctx, storage, mpool, confirmer)
-	go server.Start(config.RPCPort)
+	guard := service.NewSpamGuard(storage, service.SpamPolicy{
+		PeerRateLimit:    config.PeerRateLimit,
+		AddressRateLimit: config.AddressRateLimit,
+		BanThreshold:     config.BanThreshold,
+		BanDuration:      config.BanDuration,
+		ExemptPeers:      config.RateLimitExempt,
+	})
+	gateway := rpc.NewGateway(tlsConfig != nil)
+	server := NewServer(storage, ethClient, mpool, confirmer, policy, guard, auth, gateway, rpc.ServerConfig{
+		Host: config.RPCHost,
+		Port: config.RPCPort,
+		TLS:  tlsConfig,
+	})
 
-	rest := NewRESTServer(storage, mpool, confirmer, 6546)
-	go rest.Start()
+	checker := service.NewHealthChecker()
+	checker.Register(service.NewStorageHealthCheck(storage))
+	checker.Register(service.NewEthereumHealthCheck(ethClient, storage))
+	checker.Register(service.NewBlockSubmitterHealthCheck(submitter))
+	checker.OnUpdate(server.SetHealth)
+	rest := NewRESTServer(storage, mpool, confirmer, guard, checker, auth, gateway, rpc.ServerConfig{
+		Host: config.RESTHost,
+		Port: config.RESTPort,
+		TLS:  tlsConfig,
+	})
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
-	fmt.Println("Received an interrupt, stopping services...")
-	return nil
+	// services are stopped in reverse order: the servers stop accepting
+	// transactions first, then the node packages whatever is left in the
+	// mempool and the submitter drains before storage is closed.
+	lifecycle := service.NewLifecycle(config.ShutdownTimeout)
+	lifecycle.Register("Storage", service.NewCloserService(ldb))
+	lifecycle.Register("Mempool", mpool)
+	lifecycle.Register("Chainsaw", chainsaw)
+	lifecycle.Register("BlockSubmitter", submitter)
+	lifecycle.Register("PlasmaNode", p)
+	lifecycle.Register("HealthChecker", checker)
+	lifecycle.Register("RPCServer", server)
+	lifecycle.Register("Gateway", gateway)
+	lifecycle.Register("RESTServer", rest)
+	return lifecycle.Run()
 }
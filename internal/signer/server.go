@@ -0,0 +1,64 @@
+package signer
+
+import (
+	"context"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+var logger = log.ForSubsystem("SignerServer")
+
+// Server serves the remote signer protocol for a single key.
+type Server struct {
+	signer eth.Signer
+	token  string
+	listen rpc.ServerConfig
+
+	server *http.Server
+}
+
+func NewServer(signer eth.Signer, token string, listen rpc.ServerConfig) *Server {
+	return &Server{
+		signer: signer,
+		token:  token,
+		listen: listen,
+	}
+}
+
+func (s *Server) Start() error {
+	s.server = &http.Server{
+		Addr:      s.listen.Addr(),
+		Handler:   eth.NewSignerHandler(s.signer, s.token),
+		TLSConfig: s.listen.TLS,
+	}
+
+	go func() {
+		var err error
+		if s.listen.TLS != nil {
+			err = s.server.ListenAndServeTLS("", "")
+		} else {
+			err = s.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.WithError(logger, err).Error("encountered error in signer server")
+			return
+		}
+	}()
+
+	logger.WithFields(logrus.Fields{
+		"addr":    s.server.Addr,
+		"tls":     s.listen.TLS != nil,
+		"address": s.signer.Address().Hex(),
+	}).Info("started signer server")
+
+	return nil
+}
+
+func (s *Server) Stop() error {
+	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	return s.server.Shutdown(ctx)
+}
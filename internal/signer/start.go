@@ -0,0 +1,20 @@
+package signer
+
+import (
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"github.com/kyokan/plasma/pkg/service"
+	"time"
+)
+
+// Start serves the remote signer protocol for signer to clients holding
+// token until the process is told to stop.
+func Start(signer eth.Signer, token string, listen rpc.ServerConfig, shutdownTimeout time.Duration) error {
+	if listen.TLS == nil {
+		logger.Warn("TLS is disabled, so the signer token is sent in plaintext")
+	}
+
+	lifecycle := service.NewLifecycle(shutdownTimeout)
+	lifecycle.Register("SignerServer", NewServer(signer, token, listen))
+	return lifecycle.Run()
+}
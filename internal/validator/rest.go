@@ -0,0 +1,68 @@
+package validator
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"github.com/kyokan/plasma/pkg/service"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+var restLogger = log.ForSubsystem("RESTServer")
+
+type RESTServer struct {
+	checker *service.HealthChecker
+	gateway http.Handler
+	listen  rpc.ServerConfig
+
+	server *http.Server
+	engine *gin.Engine
+}
+
+func NewRESTServer(checker *service.HealthChecker, gateway http.Handler, listen rpc.ServerConfig) *RESTServer {
+	return &RESTServer{
+		checker: checker,
+		gateway: gateway,
+		listen:  listen,
+	}
+}
+
+func (r *RESTServer) Start() error {
+	r.engine = gin.Default()
+	r.engine.Any(rpc.GatewayPrefix+"/*path", gin.WrapH(r.gateway))
+	r.engine.GET("/healthz", gin.WrapH(r.checker.LivenessHandler()))
+	r.engine.GET("/readyz", gin.WrapH(r.checker.ReadinessHandler()))
+	r.server = &http.Server{
+		Addr:      r.listen.Addr(),
+		Handler:   r.engine,
+		TLSConfig: r.listen.TLS,
+	}
+
+	go func() {
+		var err error
+		if r.listen.TLS != nil {
+			err = r.server.ListenAndServeTLS("", "")
+		} else {
+			err = r.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.WithError(restLogger, err).Error("encountered error in rest server")
+			return
+		}
+	}()
+
+	restLogger.WithFields(logrus.Fields{
+		"addr": r.server.Addr,
+		"tls":  r.listen.TLS != nil,
+	}).Info("started REST server")
+
+	return nil
+}
+
+func (r *RESTServer) Stop() error {
+	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	return r.server.Shutdown(ctx)
+}
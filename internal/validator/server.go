@@ -2,64 +2,102 @@ package validator
 
 import (
 	"context"
-	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/kyokan/plasma/pkg/db"
 	"github.com/kyokan/plasma/pkg/rpc"
 	"github.com/kyokan/plasma/pkg/rpc/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/status"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"net"
 	"github.com/pkg/errors"
 	"github.com/kyokan/plasma/pkg/log"
 	"github.com/sirupsen/logrus"
 	"time"
 	"github.com/kyokan/plasma/pkg/service"
+	"google.golang.org/grpc/credentials"
 )
 
 type Server struct {
 	storage     db.Storage
-	ctx         context.Context
 	rootClient  pb.RootClient
 	mainBreaker service.CircuitBreaker
+	auth        *rpc.Authenticator
+	gateway     *rpc.Gateway
+	listen      rpc.ServerConfig
+
+	server *grpc.Server
+	health *health.Server
 }
 
 var logger = log.ForSubsystem("ValidatorServer")
 
-func NewServer(ctx context.Context, storage db.Storage, rootClient pb.RootClient, mainBreaker service.CircuitBreaker) (*Server) {
+func NewServer(storage db.Storage, rootClient pb.RootClient, mainBreaker service.CircuitBreaker, auth *rpc.Authenticator, gateway *rpc.Gateway, listen rpc.ServerConfig) (*Server) {
 	return &Server{
 		storage:     storage,
-		ctx:         ctx,
 		rootClient:  rootClient,
 		mainBreaker: mainBreaker,
+		auth:        auth,
+		gateway:     gateway,
+		listen:      listen,
+		health:      health.NewServer(),
 	}
 }
 
-func (r *Server) Start(rpcPort int) error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", rpcPort))
+func (r *Server) Start() error {
+	lis, err := net.Listen("tcp", r.listen.Addr())
 	if err != nil {
 		return err
 	}
 
-	s := grpc.NewServer()
-	pb.RegisterRootServer(s, r)
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(rpc.AuthInterceptor(r.auth)),
+	}
+	if r.listen.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(r.listen.TLS)))
+	}
+	r.server = grpc.NewServer(opts...)
+	pb.RegisterRootServer(r.server, r)
+	healthpb.RegisterHealthServer(r.server, r.health)
+
 	go func() {
-		if err := s.Serve(lis); err != nil {
-			log.WithError(logger, err)
+		if err := r.server.Serve(lis); err != nil {
+			log.WithError(logger, err).Error("encountered error in gRPC server")
 		}
 	}()
-	go func() {
-		<-r.ctx.Done()
-		s.Stop()
-	}()
+	if r.gateway != nil {
+		go r.server.Serve(r.gateway.Listener())
+	}
 
 	logger.WithFields(logrus.Fields{
-		"port": rpcPort,
+		"addr": lis.Addr().String(),
+		"tls":  r.listen.TLS != nil,
 	}).Info("started gRPC server")
 
 	return nil
 }
 
+// Stop stops accepting new connections and blocks until in-flight RPCs,
+// such as sends awaiting block inclusion, have completed.
+func (r *Server) Stop() error {
+	r.server.GracefulStop()
+	return nil
+}
+
+// SetHealth updates the status reported by the gRPC health service, both
+// for the server as a whole and for the Root service.
+func (r *Server) SetHealth(report service.HealthReport) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if report.Healthy {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	r.health.SetServingStatus("", status)
+	r.health.SetServingStatus("pb.Root", status)
+}
+
 func (r *Server) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.GetBalanceResponse, error) {
 	addr := common.BytesToAddress(req.Address)
 	bal, err := r.storage.Balance(addr)
@@ -102,7 +140,7 @@ func (r *Server) GetBlock(ctx context.Context, req *pb.GetBlockRequest) (*pb.Get
 
 func (r *Server) Send(ctx context.Context, req *pb.SendRequest) (*pb.SendResponse, error) {
 	if r.mainBreaker.Tripped() {
-		return nil, errors.New("transactions are disable due to circuit breaker")
+		return nil, status.Error(codes.Unavailable, "transactions are disable due to circuit breaker")
 	}
 
 	if req == nil {
@@ -115,13 +153,56 @@ func (r *Server) Send(ctx context.Context, req *pb.SendRequest) (*pb.SendRespons
 
 func (r *Server) Confirm(ctx context.Context, req *pb.ConfirmRequest) (*pb.ConfirmedTransaction, error) {
 	if r.mainBreaker.Tripped() {
-		return nil, errors.New("confirmations are disable due to circuit breaker")
+		return nil, status.Error(codes.Unavailable, "confirmations are disable due to circuit breaker")
 	}
 
 	childCtx, _ := context.WithTimeout(ctx, 5*time.Second)
 	return r.rootClient.Confirm(childCtx, req)
 }
 
+// GetConfirmSigs asks the root node, since confirmations are not synced to
+// validators.
+func (r *Server) GetConfirmSigs(ctx context.Context, req *pb.GetConfirmSigsRequest) (*pb.GetConfirmSigsResponse, error) {
+	childCtx, _ := context.WithTimeout(ctx, 5*time.Second)
+	return r.rootClient.GetConfirmSigs(childCtx, req)
+}
+
+func (r *Server) GetTransaction(ctx context.Context, req *pb.GetTransactionRequest) (*pb.ConfirmedTransaction, error) {
+	tx, err := r.storage.FindTransactionByBlockNumTxIdx(req.BlockNumber, req.TransactionIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.Proto(), nil
+}
+
+func (r *Server) GetDeposit(ctx context.Context, req *pb.GetDepositRequest) (*pb.ConfirmedTransaction, error) {
+	tx, err := r.storage.FindDoubleSpendingDeposit(rpc.DeserializeBig(req.DepositNonce))
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, status.Error(codes.NotFound, "deposit not found")
+	}
+
+	return tx.Proto(), nil
+}
+
+func (r *Server) GetSigningDomain(ctx context.Context, req *pb.EmptyRequest) (*pb.GetSigningDomainResponse, error) {
+	childCtx, _ := context.WithTimeout(ctx, 5*time.Second)
+	return r.rootClient.GetSigningDomain(childCtx, req)
+}
+
+func (r *Server) GetFeeReport(ctx context.Context, req *pb.EmptyRequest) (*pb.GetFeeReportResponse, error) {
+	childCtx, _ := context.WithTimeout(ctx, 5*time.Second)
+	return r.rootClient.GetFeeReport(childCtx, req)
+}
+
+func (r *Server) EstimateFee(ctx context.Context, req *pb.EmptyRequest) (*pb.EstimateFeeResponse, error) {
+	childCtx, _ := context.WithTimeout(ctx, 5*time.Second)
+	return r.rootClient.EstimateFee(childCtx, req)
+}
+
 func (r *Server) BlockHeight(context.Context, *pb.EmptyRequest) (*pb.BlockHeightResponse, error) {
 	latest, err := r.storage.LatestBlock()
 	if err != nil {
@@ -0,0 +1,77 @@
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+)
+
+// TrustedSnapshot is a snapshot the operator trusts up to Height. Blocks
+// after Height are ignored and synced from the root node instead.
+type TrustedSnapshot struct {
+	Path   string
+	Height uint64
+}
+
+// importTrustedSnapshot checks the Merkle root of the trusted block against
+// the one submitted to the Plasma contract, then imports the snapshot into
+// an empty database. Databases that already contain blocks are left as is.
+func importTrustedSnapshot(kv db.KV, client eth.Client, snapshot *TrustedSnapshot) error {
+	latest, err := db.NewKVStorage(kv).LatestBlock()
+	if err != nil {
+		return err
+	}
+	if latest != nil {
+		logger.WithFields(logrus.Fields{
+			"height": latest.Header.Number,
+		}).Info("database is not empty, skipping snapshot import")
+		return nil
+	}
+
+	f, err := os.Open(snapshot.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header, err := db.VerifySnapshot(f)
+	if err != nil {
+		return err
+	}
+	height := snapshot.Height
+	if height == 0 {
+		height = header.Height
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	trusted, err := db.FindSnapshotBlock(f, height)
+	if err != nil {
+		return err
+	}
+	submitted, err := client.LookupBlock(height)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(trusted.Header.MerkleRoot, submitted.Root) {
+		return fmt.Errorf("snapshot block %d does not match the block submitted to the Plasma contract", height)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := db.ImportSnapshot(kv, f, height); err != nil {
+		return err
+	}
+
+	logger.WithFields(logrus.Fields{
+		"path":   snapshot.Path,
+		"height": height,
+	}).Info("imported trusted snapshot")
+	return nil
+}
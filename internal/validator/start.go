@@ -2,67 +2,97 @@ package validator
 
 import (
 	"github.com/kyokan/plasma/pkg/config"
-	"crypto/ecdsa"
 	"github.com/kyokan/plasma/pkg/eth"
 	"github.com/kyokan/plasma/pkg/db"
 	"github.com/kyokan/plasma/pkg/service"
-	"os"
-	"os/signal"
 	"path"
+	"github.com/kyokan/plasma/pkg/rpc"
 	"github.com/kyokan/plasma/pkg/rpc/pb"
-	"google.golang.org/grpc"
-	"github.com/kyokan/plasma/pkg/log"
-	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/validation"
 )
 
-var valStartLogger = log.ForSubsystem("ValidatorStart")
-
-func Start(config *config.GlobalConfig, rootUrl string, privateKey *ecdsa.PrivateKey) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
+func Start(config *config.GlobalConfig, rootUrl string, rootOpts rpc.ClientOptions, snapshot *TrustedSnapshot, signer eth.Signer) error {
 	mainBreaker := service.NewCircuitBreaker("MainBreaker")
 
-	ethClient, err := eth.NewClient(config.NodeURL, config.ContractAddr, privateKey)
+	tlsConfig, err := rpc.ServerTLSConfig(config.TLSCertFile, config.TLSKeyFile, config.TLSClientCAFile)
+	if err != nil {
+		return err
+	}
+	auth, err := rpc.LoadAuthenticator(config.APIKeysFile, config.JWTSecretFile, config.TLSClientCAFile != "")
 	if err != nil {
 		return err
 	}
+	if auth != nil && tlsConfig == nil {
+		logger.Warn("write requests require credentials, but TLS is disabled so they are sent in plaintext")
+	}
 
-	ldb, storage, err := db.CreateLevelStorage(path.Join(config.DBPath, "root"))
+	ethClient, err := eth.NewClient(config.NodeURL, config.ContractAddr, signer)
 	if err != nil {
 		return err
 	}
-	defer ldb.Close()
 
-	conn, err := grpc.Dial(rootUrl, grpc.WithInsecure())
+	ldb, storage, err := db.CreateStorage(config.DBBackend, path.Join(config.DBPath, "root"))
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
-	rootClient := pb.NewRootClient(conn)
 
-	exitStrategizer := service.NewExitStrategizer(ethClient, storage, mainBreaker)
-	if err := exitStrategizer.Start(); err != nil {
+	if snapshot != nil {
+		if err := importTrustedSnapshot(ldb, ethClient, snapshot); err != nil {
+			ldb.Close()
+			return err
+		}
+	}
+
+	conn, err := rpc.Dial(rootUrl, rootOpts)
+	if err != nil {
+		ldb.Close()
 		return err
 	}
-	syncer := service.NewSyncer(storage, rootClient, ethClient, exitStrategizer, mainBreaker)
-	if err := syncer.Start(); err != nil {
+	rootClient := pb.NewRootClient(conn)
+
+	exitStrategizer := service.NewExitStrategizer(ethClient, storage, mainBreaker)
+	chainID, err := ethClient.ChainID()
+	if err != nil {
+		conn.Close()
+		ldb.Close()
 		return err
 	}
+	domain := chain.NewDomain(chainID, common.HexToAddress(config.ContractAddr))
+	policy := validation.NewSignaturePolicy(domain)
+	policy.StrictFrom = config.StrictSignaturesFrom
+	syncer := service.NewSyncer(storage, rootClient, ethClient, policy, exitStrategizer, mainBreaker)
+	gateway := rpc.NewGateway(tlsConfig != nil)
+	server := NewServer(storage, rootClient, mainBreaker, auth, gateway, rpc.ServerConfig{
+		Host: config.RPCHost,
+		Port: config.RPCPort,
+		TLS:  tlsConfig,
+	})
 
-	server := NewServer(ctx, storage, rootClient, mainBreaker)
-	go func() {
-		if err := server.Start(config.RPCPort); err != nil {
-			log.WithError(valStartLogger, err).Error("failed to start server")
-			os.Exit(1)
-		}
-	}()
+	checker := service.NewHealthChecker()
+	checker.Register(service.NewStorageHealthCheck(storage))
+	checker.Register(service.NewEthereumConnectivityCheck(ethClient))
+	checker.Register(service.NewSyncHealthCheck(storage, rootClient))
+	checker.Register(service.NewCircuitBreakerHealthCheck("mainBreaker", mainBreaker))
+	checker.OnUpdate(server.SetHealth)
+	rest := NewRESTServer(checker, gateway, rpc.ServerConfig{
+		Host: config.RESTHost,
+		Port: config.RESTPort,
+		TLS:  tlsConfig,
+	})
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
-	if err := syncer.Stop(); err != nil {
-		log.WithError(valStartLogger, err).Error("failed to stop syncer")
+	lifecycle := service.NewLifecycle(config.ShutdownTimeout)
+	lifecycle.Register("Storage", service.NewCloserService(ldb))
+	lifecycle.Register("RootConnection", service.NewCloserService(conn))
+	lifecycle.Register("ExitStrategizer", exitStrategizer)
+	lifecycle.Register("Syncer", syncer)
+	if config.PruneDepth > 0 {
+		lifecycle.Register("Pruner", service.NewPruner(ldb, config.PruneDepth))
 	}
-	return nil
+	lifecycle.Register("HealthChecker", checker)
+	lifecycle.Register("RPCServer", server)
+	lifecycle.Register("Gateway", gateway)
+	lifecycle.Register("RESTServer", rest)
+	return lifecycle.Run()
 }
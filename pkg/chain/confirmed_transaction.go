@@ -14,20 +14,38 @@ type ConfirmedTransaction struct {
 var zeroSig Signature
 
 func (c *ConfirmedTransaction) IsConfirmed() bool {
-	return c.ConfirmSigs[0] != zeroSig &&
-			c.ConfirmSigs[1] != zeroSig
+	count := c.Transaction.Body.InputCount()
+	for i := 0; i < count; i++ {
+		if c.ConfirmSigs[i] == zeroSig {
+			return false
+		}
+	}
+	return true
 }
 
-func (c *ConfirmedTransaction) Proto() (*pb.ConfirmedTransaction) {
-	sig0 := make([]byte, len(c.ConfirmSigs[0]), len(c.ConfirmSigs[0]))
-	copy(sig0, c.ConfirmSigs[0][:])
-	sig1 := make([]byte, len(c.ConfirmSigs[1]), len(c.ConfirmSigs[1]))
-	copy(sig1, c.ConfirmSigs[1][:])
+// ConfirmSigAt returns the confirm sig for the input at idx.
+func (c *ConfirmedTransaction) ConfirmSigAt(idx uint8) Signature {
+	return c.ConfirmSigs[idx]
+}
 
+// ConfirmSigBytes returns the confirm sigs of the transaction's inputs
+// concatenated in input order. The contract checks them when any of the
+// transaction's outputs is exited.
+func (c *ConfirmedTransaction) ConfirmSigBytes() []byte {
+	count := c.Transaction.Body.InputCount()
+	ret := make([]byte, 0, count*len(zeroSig))
+	for i := 0; i < count; i++ {
+		sig := c.ConfirmSigAt(uint8(i))
+		ret = append(ret, sig[:]...)
+	}
+	return ret
+}
+
+func (c *ConfirmedTransaction) Proto() (*pb.ConfirmedTransaction) {
 	return &pb.ConfirmedTransaction{
 		Transaction: c.Transaction.Proto(),
-		ConfirmSig0: sig0,
-		ConfirmSig1: sig1,
+		ConfirmSig0: sigBytesAt(c.ConfirmSigs[:], 0),
+		ConfirmSig1: sigBytesAt(c.ConfirmSigs[:], 1),
 	}
 }
 
@@ -47,8 +65,7 @@ func ConfirmedTransactionFromProto(protoTx *pb.ConfirmedTransaction) (*Confirmed
 
 	var c ConfirmedTransaction
 	c.Transaction = tx
-	copy(c.ConfirmSigs[0][:], protoTx.ConfirmSig0)
-	copy(c.ConfirmSigs[1][:], protoTx.ConfirmSig1)
+	copy(c.ConfirmSigs[:], SignaturesFromProto([][]byte{protoTx.ConfirmSig0, protoTx.ConfirmSig1}))
 	return &c, nil
 }
 
@@ -0,0 +1,147 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/kyokan/plasma/util"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+// SignatureVersion identifies what a transaction or confirmation signature
+// commits to. Signatures carry no version themselves, so validators check
+// each version they accept.
+type SignatureVersion uint8
+
+const (
+	// SignatureVersionLegacy signs the transaction alone. It is the only
+	// version the contract understands, but a signature is valid on every
+	// deployment with matching UTXO positions.
+	SignatureVersionLegacy SignatureVersion = iota
+	// SignatureVersionDomain also commits to the chain ID and the Plasma
+	// contract, so signatures cannot be replayed on other deployments.
+	SignatureVersionDomain
+	// SignatureVersionTypedData signs an EIP-712 digest of the same domain
+	// and message, which wallets can show to the user. Unlike the other
+	// versions, it is not signed as an Ethereum signed message.
+	SignatureVersionTypedData
+)
+
+// domainName is included in the domain separator so that it cannot collide
+// with hashes signed for other protocols.
+const domainName = "Plasma"
+
+func (v SignatureVersion) String() string {
+	switch v {
+	case SignatureVersionLegacy:
+		return "legacy"
+	case SignatureVersionDomain:
+		return "domain"
+	case SignatureVersionTypedData:
+		return "typedData"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(v))
+	}
+}
+
+// Domain identifies a Plasma deployment.
+type Domain struct {
+	ChainID  *big.Int
+	Contract common.Address
+}
+
+type rlpDomain struct {
+	Name     string
+	Version  uint
+	ChainID  *UInt256
+	Contract common.Address
+}
+
+type domainJSON struct {
+	ChainID  string         `json:"chainId"`
+	Contract common.Address `json:"contract"`
+}
+
+func NewDomain(chainID *big.Int, contract common.Address) *Domain {
+	return &Domain{
+		ChainID:  chainID,
+		Contract: contract,
+	}
+}
+
+// Separator returns the hash that domain-separated signatures are prefixed
+// with.
+func (d *Domain) Separator() util.Hash {
+	buf, err := rlp.EncodeToBytes(&rlpDomain{
+		Name:     domainName,
+		Version:  uint(SignatureVersionDomain),
+		ChainID:  NewUint256(d.ChainID),
+		Contract: d.Contract,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return util.Keccak256(buf)
+}
+
+func (d *Domain) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&domainJSON{
+		ChainID:  util.Big2Str(d.ChainID),
+		Contract: d.Contract,
+	})
+}
+
+func (d *Domain) UnmarshalJSON(in []byte) error {
+	jsonRep := &domainJSON{}
+	if err := json.Unmarshal(in, jsonRep); err != nil {
+		return err
+	}
+	chainID, err := util.Str2Big(jsonRep.ChainID)
+	if err != nil {
+		return errors.Wrap(err, "invalid chain ID")
+	}
+	d.ChainID = chainID
+	d.Contract = jsonRep.Contract
+	return nil
+}
+
+// VersionedSignatureHash returns the hash signed by the owners of the
+// transaction's inputs under version. domain is unused for legacy
+// signatures.
+func (b *TransactionBody) VersionedSignatureHash(version SignatureVersion, domain *Domain) (util.Hash, error) {
+	return versionedHash(version, domain, b.SignatureHash, b.TypedDataHash)
+}
+
+// VersionedConfirmationHash returns the hash signed to confirm the
+// transaction's inclusion in the block with merkleRoot under version.
+func (c *Transaction) VersionedConfirmationHash(version SignatureVersion, domain *Domain, merkleRoot util.Hash) (util.Hash, error) {
+	return versionedHash(version, domain, func() util.Hash {
+		return c.ConfirmationHash(merkleRoot)
+	}, func() util.Hash {
+		return c.ConfirmationTypedDataHash(merkleRoot)
+	})
+}
+
+// versionedHash returns legacyHash bound to domain as version requires, or
+// the EIP-712 digest of structHash for typed data signatures.
+func versionedHash(version SignatureVersion, domain *Domain, legacyHash func() util.Hash, structHash func() util.Hash) (util.Hash, error) {
+	if version != SignatureVersionLegacy && domain == nil {
+		return nil, fmt.Errorf("%s signatures require a domain", version)
+	}
+
+	switch version {
+	case SignatureVersionLegacy:
+		return legacyHash(), nil
+	case SignatureVersionDomain:
+		buf := make([]byte, 0, 64)
+		buf = append(buf, domain.Separator()...)
+		buf = append(buf, legacyHash()...)
+		return util.Keccak256(buf), nil
+	case SignatureVersionTypedData:
+		return util.TypedDataHash(domain.TypedDataSeparator(), structHash()), nil
+	default:
+		return nil, fmt.Errorf("unknown signature version %d", version)
+	}
+}
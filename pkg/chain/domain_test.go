@@ -0,0 +1,56 @@
+package chain
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionedSignatureHash(t *testing.T) {
+	body := multiInputTransaction().Body
+	contract := common.HexToAddress("0xf25186b5081ff5ce73482ad761db0eb0d25abfbf")
+	mainnet := NewDomain(big.NewInt(1), contract)
+
+	legacy, err := body.VersionedSignatureHash(SignatureVersionLegacy, mainnet)
+	require.NoError(t, err)
+	require.Equal(t, body.SignatureHash(), legacy)
+
+	domain, err := body.VersionedSignatureHash(SignatureVersionDomain, mainnet)
+	require.NoError(t, err)
+	require.NotEqual(t, legacy, domain)
+
+	// every part of the domain is covered
+	otherChain, err := body.VersionedSignatureHash(SignatureVersionDomain, NewDomain(big.NewInt(3), contract))
+	require.NoError(t, err)
+	require.NotEqual(t, domain, otherChain)
+	otherContract, err := body.VersionedSignatureHash(SignatureVersionDomain, NewDomain(big.NewInt(1), RandomAddress()))
+	require.NoError(t, err)
+	require.NotEqual(t, domain, otherContract)
+
+	typedData, err := body.VersionedSignatureHash(SignatureVersionTypedData, mainnet)
+	require.NoError(t, err)
+	require.Equal(t, util.TypedDataHash(mainnet.TypedDataSeparator(), body.TypedDataHash()), typedData)
+	require.NotEqual(t, domain, typedData)
+
+	_, err = body.VersionedSignatureHash(SignatureVersionDomain, nil)
+	require.Error(t, err)
+	_, err = body.VersionedSignatureHash(SignatureVersionTypedData, nil)
+	require.Error(t, err)
+	_, err = body.VersionedSignatureHash(SignatureVersion(99), mainnet)
+	require.Error(t, err)
+}
+
+func TestDomain_JSON(t *testing.T) {
+	domain := NewDomain(big.NewInt(1337), RandomAddress())
+	data, err := json.Marshal(domain)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"chainId":"1337"`)
+
+	var decoded Domain
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, domain, &decoded)
+}
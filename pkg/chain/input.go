@@ -93,7 +93,7 @@ func InputFromProto(protoIn *pb.Input) (*Input, error) {
 		return nil, errors.New("input cannot be nil")
 	}
 
-	if protoIn.OutIdx > 1 {
+	if protoIn.OutIdx >= MaxOutputs {
 		return nil, errors.New("outIdx too large")
 	}
 
@@ -29,6 +29,42 @@ func (s *Signature) UnmarshalJSON(in []byte) error {
 	return nil
 }
 
+// NormalizeV rewrites a recovery id of 27 or 28, which some wallets
+// produce, as 0 or 1. Signatures are part of a transaction's hash, so each
+// may only have one encoding.
+func (s *Signature) NormalizeV() {
+	if s[64] == 27 || s[64] == 28 {
+		s[64] -= 27
+	}
+}
+
+// SignaturesProto converts sigs into the byte slices used by protobufs.
+func SignaturesProto(sigs []Signature) [][]byte {
+	ret := make([][]byte, len(sigs), len(sigs))
+	for i := range sigs {
+		ret[i] = sigBytesAt(sigs, i)
+	}
+	return ret
+}
+
+func SignaturesFromProto(sigs [][]byte) []Signature {
+	ret := make([]Signature, len(sigs), len(sigs))
+	for i, sig := range sigs {
+		copy(ret[i][:], sig)
+	}
+	return ret
+}
+
+// sigBytesAt returns a copy of the signature at idx, or a zero signature
+// if there is none.
+func sigBytesAt(sigs []Signature, idx int) []byte {
+	ret := make([]byte, 65, 65)
+	if idx < len(sigs) {
+		copy(ret, sigs[idx][:])
+	}
+	return ret
+}
+
 type UInt256 [32]byte
 
 func NewUint256(i *big.Int) *UInt256 {
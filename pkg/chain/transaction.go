@@ -17,16 +17,43 @@ type rlpConfirmedTransaction struct {
 	Body        [2]Signature
 }
 
+// SigAt returns the signature for the input at idx.
+func (c *Transaction) SigAt(idx uint8) Signature {
+	return c.Sigs[idx]
+}
+
+// NormalizeSigs applies Signature.NormalizeV to the transaction's
+// signatures and to the confirm sigs given for its inputs.
+func (c *Transaction) NormalizeSigs() {
+	for i := range c.Sigs {
+		c.Sigs[i].NormalizeV()
+		c.Body.Input0ConfirmSigs[i].NormalizeV()
+		c.Body.Input1ConfirmSigs[i].NormalizeV()
+	}
+}
+
+// ConfirmationHash returns the hash the owners of the transaction's inputs
+// sign to confirm it was included in the block with merkleRoot.
+func (c *Transaction) ConfirmationHash(merkleRoot util.Hash) util.Hash {
+	txHash := c.RLPHash(util.Sha256)
+	buf := make([]byte, 0, len(txHash)+len(merkleRoot))
+	buf = append(buf, txHash...)
+	buf = append(buf, merkleRoot...)
+	return util.Sha256(buf)
+}
+
 func (c *Transaction) RLPHash(hasher util.Hasher) util.Hash {
 	bytes := c.RLP()
 	return hasher(bytes)
 }
 
 func (c *Transaction) RLP() []byte {
-	bytes, err := rlp.EncodeToBytes(rlpConfirmedTransaction{
+	rep := rlpConfirmedTransaction{
 		Transaction: c.Body.rlpRepresentation(),
 		Body:        c.Sigs,
-	})
+	}
+
+	bytes, err := rlp.EncodeToBytes(rep)
 	if err != nil {
 		panic(err)
 	}
@@ -35,15 +62,10 @@ func (c *Transaction) RLP() []byte {
 }
 
 func (c *Transaction) Proto() (*pb.Transaction) {
-	sig0 := make([]byte, len(c.Sigs[0]), len(c.Sigs[0]))
-	copy(sig0, c.Sigs[0][:])
-	sig1 := make([]byte, len(c.Sigs[1]), len(c.Sigs[1]))
-	copy(sig1, c.Sigs[1][:])
-
 	return &pb.Transaction{
 		Body: c.Body.Proto(),
-		Sig0: sig0,
-		Sig1: sig1,
+		Sig0: sigBytesAt(c.Sigs[:], 0),
+		Sig1: sigBytesAt(c.Sigs[:], 1),
 	}
 }
 
@@ -68,16 +90,9 @@ func TransactionFromProto(protoTx *pb.Transaction) (*Transaction, error) {
 		return nil, err
 	}
 
-	var sig0 Signature
-	copy(sig0[:], protoTx.Sig0)
-	var sig1 Signature
-	copy(sig1[:], protoTx.Sig0)
-
-	return &Transaction{
+	tx := &Transaction{
 		Body: body,
-		Sigs: [2]Signature{
-			sig0,
-			sig1,
-		},
-	}, nil
+	}
+	copy(tx.Sigs[:], SignaturesFromProto([][]byte{protoTx.Sig0, protoTx.Sig1}))
+	return tx, nil
 }
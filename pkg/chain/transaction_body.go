@@ -13,6 +13,14 @@ import (
 	"github.com/kyokan/plasma/util"
 )
 
+// MaxInputs and MaxOutputs are the number of inputs and outputs a
+// transaction has. The contract only decodes the fixed two input, two
+// output layout.
+const (
+	MaxInputs  = 2
+	MaxOutputs = 2
+)
+
 type TransactionBody struct {
 	Input0            *Input
 	Input0ConfirmSigs [2]Signature
@@ -111,9 +119,17 @@ func (b *TransactionBody) IsZero() bool {
 		return false
 	}
 	return b.Input0.IsZero() &&
-			b.Input1.IsZero() &&
-			b.Output0.IsZeroOutput() &&
-			b.Output1.IsZeroOutput()
+		b.Input1.IsZero() &&
+		b.Output0.IsZeroOutput() &&
+		b.Output1.IsZeroOutput()
+}
+
+// InputCount returns the number of inputs, not counting a zero input 1.
+func (b *TransactionBody) InputCount() int {
+	if b.Input1.IsZero() {
+		return 1
+	}
+	return 2
 }
 
 func (b *TransactionBody) InputAt(idx uint8) *Input {
@@ -128,6 +144,19 @@ func (b *TransactionBody) InputAt(idx uint8) *Input {
 	return b.Input1
 }
 
+// InputConfirmSigsAt returns the confirm sigs given for the input at idx.
+func (b *TransactionBody) InputConfirmSigsAt(idx uint8) [2]Signature {
+	if idx != 0 && idx != 1 {
+		panic(fmt.Sprint("Invalid input index: ", idx))
+	}
+
+	if idx == 0 {
+		return b.Input0ConfirmSigs
+	}
+
+	return b.Input1ConfirmSigs
+}
+
 func (b *TransactionBody) OutputAt(idx uint8) *Output {
 	if idx == 0 {
 		return b.Output0
@@ -0,0 +1,104 @@
+package chain
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+)
+
+func multiInputTransaction() *Transaction {
+	body := ZeroBody()
+	body.Input0 = NewInput(1, 0, 0, Zero())
+	body.Input0ConfirmSigs = [2]Signature{RandomConfirmationSig()}
+	body.Input1 = NewInput(2, 0, 1, Zero())
+	body.Input1ConfirmSigs = [2]Signature{RandomConfirmationSig(), RandomConfirmationSig()}
+	body.Output0 = NewOutput(RandomAddress(), big.NewInt(1))
+	body.Output1 = NewOutput(RandomAddress(), big.NewInt(2))
+	return &Transaction{
+		Body: body,
+		Sigs: [2]Signature{RandomConfirmationSig(), RandomConfirmationSig()},
+	}
+}
+
+func TestTransactionBody_MultiInput(t *testing.T) {
+	tx := multiInputTransaction()
+	require.Equal(t, 2, tx.Body.InputCount())
+
+	decoded, err := TransactionFromProto(tx.Proto())
+	require.NoError(t, err)
+	require.Equal(t, tx.RLPHash(util.Sha256), decoded.RLPHash(util.Sha256))
+	require.Equal(t, tx.Body.Input1ConfirmSigs, decoded.Body.Input1ConfirmSigs)
+	require.Equal(t, tx.Sigs, decoded.Sigs)
+
+	data, err := json.Marshal(tx)
+	require.NoError(t, err)
+	var fromJSON Transaction
+	require.NoError(t, json.Unmarshal(data, &fromJSON))
+	require.Equal(t, tx.RLPHash(util.Sha256), fromJSON.RLPHash(util.Sha256))
+
+	// every input is covered by the signature hash
+	sigHash := tx.Body.SignatureHash()
+	tx.Body.Input1.OutputIndex = 0
+	require.NotEqual(t, sigHash, tx.Body.SignatureHash())
+
+	tx.Body.Input1 = ZeroInput()
+	require.Equal(t, 1, tx.Body.InputCount())
+}
+
+func TestConfirmedTransaction_ConfirmSigs(t *testing.T) {
+	confirmed := &ConfirmedTransaction{
+		Transaction: multiInputTransaction(),
+		ConfirmSigs: [2]Signature{RandomConfirmationSig()},
+	}
+	require.False(t, confirmed.IsConfirmed())
+
+	confirmed.ConfirmSigs[1] = RandomConfirmationSig()
+	require.True(t, confirmed.IsConfirmed())
+
+	decoded, err := ConfirmedTransactionFromProto(confirmed.Proto())
+	require.NoError(t, err)
+	require.Equal(t, confirmed.ConfirmSigs, decoded.ConfirmSigs)
+	require.Equal(t, confirmed.Hash(), decoded.Hash())
+}
+
+func TestConfirmedTransaction_ConfirmSigBytes(t *testing.T) {
+	confirmed := &ConfirmedTransaction{
+		Transaction: multiInputTransaction(),
+		ConfirmSigs: [2]Signature{RandomConfirmationSig(), RandomConfirmationSig()},
+	}
+	expected := append(confirmed.ConfirmSigs[0][:], confirmed.ConfirmSigs[1][:]...)
+	require.Equal(t, expected, confirmed.ConfirmSigBytes())
+
+	// a single input transaction is exited with its one confirm sig,
+	// whichever output is exiting
+	confirmed.Transaction.Body.Input1 = ZeroInput()
+	require.Equal(t, confirmed.ConfirmSigs[0][:], confirmed.ConfirmSigBytes())
+}
+
+func TestTransaction_NormalizeSigs(t *testing.T) {
+	tx := multiInputTransaction()
+	tx.Sigs[0][64] = 0
+	tx.Sigs[1][64] = 1
+	tx.Body.Input1ConfirmSigs[0][64] = 1
+	expected := tx.RLPHash(util.Sha256)
+
+	// the same signatures with 27/28 recovery ids hash differently until
+	// they are normalized
+	tx.Sigs[0][64] = 27
+	tx.Sigs[1][64] = 28
+	tx.Body.Input1ConfirmSigs[0][64] = 28
+	require.NotEqual(t, expected, tx.RLPHash(util.Sha256))
+	tx.NormalizeSigs()
+	require.Equal(t, expected, tx.RLPHash(util.Sha256))
+}
+
+func TestTransaction_ConfirmationHash(t *testing.T) {
+	tx := multiInputTransaction()
+	merkleRoot := util.Sha256([]byte("root"))
+	expected := util.Sha256(append(tx.RLPHash(util.Sha256), merkleRoot...))
+	require.Equal(t, expected, tx.ConfirmationHash(merkleRoot))
+	require.NotEqual(t, expected, tx.ConfirmationHash(util.Sha256([]byte("other root"))))
+}
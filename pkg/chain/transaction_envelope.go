@@ -0,0 +1,172 @@
+package chain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kyokan/plasma/util"
+	"github.com/pkg/errors"
+)
+
+// TransactionEnvelopeVersion is the version of the envelope format written
+// by this package. Envelopes with any other version are rejected, so that
+// an offline signer never signs something it misreads.
+const TransactionEnvelopeVersion = 1
+
+// TransactionEnvelope carries a transaction between building, signing and
+// submitting it, which may happen on different machines.
+type TransactionEnvelope struct {
+	Transaction *Transaction
+	// Domain is the deployment the transaction is built for. Only domain
+	// and typed data signatures are bound to it.
+	Domain           *Domain
+	SignatureVersion SignatureVersion
+	// MerkleRoot is the root of the block the transaction was included in.
+	// It is only set once the transaction has been submitted.
+	MerkleRoot []byte
+}
+
+type transactionEnvelopeJSON struct {
+	Version    int              `json:"version"`
+	Body       *TransactionBody `json:"body"`
+	Sigs       []Signature      `json:"sigs,omitempty"`
+	Domain     *Domain          `json:"domain,omitempty"`
+	MerkleRoot string           `json:"merkleRoot,omitempty"`
+	// SignatureVersion is omitted if it is domain signatures and there is a
+	// domain, or legacy signatures and there is none, so that envelopes
+	// written before typed data signatures still parse.
+	SignatureVersion *SignatureVersion `json:"signatureVersion,omitempty"`
+	// SignatureHash is informational, and lets the holder of the key
+	// check what they are about to sign.
+	SignatureHash string `json:"signatureHash"`
+}
+
+type ErrUnsupportedEnvelopeVersion struct {
+	version int
+}
+
+func NewErrUnsupportedEnvelopeVersion(version int) error {
+	return &ErrUnsupportedEnvelopeVersion{
+		version: version,
+	}
+}
+
+func (e *ErrUnsupportedEnvelopeVersion) Error() string {
+	return fmt.Sprintf("unsupported transaction envelope version %d, expected %d", e.version, TransactionEnvelopeVersion)
+}
+
+// DefaultSignatureVersion is the version transactions and confirmations
+// are signed with when none is chosen. The contract recovers both when
+// exits are started and challenged, and it only understands legacy
+// signatures.
+const DefaultSignatureVersion = SignatureVersionLegacy
+
+// NewTransactionEnvelope returns an envelope for tx, which is signed with
+// legacy signatures.
+func NewTransactionEnvelope(tx *Transaction, domain *Domain) *TransactionEnvelope {
+	return &TransactionEnvelope{
+		Transaction:      tx,
+		Domain:           domain,
+		SignatureVersion: DefaultSignatureVersion,
+	}
+}
+
+// omittedSignatureVersion returns the version of envelopes that do not
+// name one. Envelopes written before typed data signatures were signed
+// with domain signatures if they had a domain.
+func omittedSignatureVersion(domain *Domain) SignatureVersion {
+	if domain == nil {
+		return SignatureVersionLegacy
+	}
+	return SignatureVersionDomain
+}
+
+// SignatureHash returns the hash the transaction's inputs are signed over.
+func (e *TransactionEnvelope) SignatureHash() (util.Hash, error) {
+	return e.Transaction.Body.VersionedSignatureHash(e.SignatureVersion, e.Domain)
+}
+
+// IsSigned returns true if every input of the transaction has a signature.
+func (e *TransactionEnvelope) IsSigned() bool {
+	var emptySig Signature
+	body := e.Transaction.Body
+	for i := 0; i < body.InputCount(); i++ {
+		if i > 0 && body.InputAt(uint8(i)).IsZero() {
+			continue
+		}
+		if e.Transaction.SigAt(uint8(i)) == emptySig {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *TransactionEnvelope) MarshalJSON() ([]byte, error) {
+	sigHash, err := e.SignatureHash()
+	if err != nil {
+		return nil, err
+	}
+	jsonRep := &transactionEnvelopeJSON{
+		Version:       TransactionEnvelopeVersion,
+		Body:          e.Transaction.Body,
+		Domain:        e.Domain,
+		SignatureHash: hexutil.Encode(sigHash),
+	}
+	if e.Transaction.Sigs != ([2]Signature{}) {
+		jsonRep.Sigs = e.Transaction.Sigs[:]
+	}
+	if e.SignatureVersion != omittedSignatureVersion(e.Domain) {
+		version := e.SignatureVersion
+		jsonRep.SignatureVersion = &version
+	}
+	if len(e.MerkleRoot) > 0 {
+		jsonRep.MerkleRoot = hexutil.Encode(e.MerkleRoot)
+	}
+	return json.Marshal(jsonRep)
+}
+
+func (e *TransactionEnvelope) UnmarshalJSON(in []byte) error {
+	jsonRep := &transactionEnvelopeJSON{}
+	if err := json.Unmarshal(in, jsonRep); err != nil {
+		return err
+	}
+	if jsonRep.Version != TransactionEnvelopeVersion {
+		return NewErrUnsupportedEnvelopeVersion(jsonRep.Version)
+	}
+	if jsonRep.Body == nil {
+		return errors.New("transaction envelope has no body")
+	}
+	body := jsonRep.Body
+	if body.Input0 == nil || body.Input1 == nil || body.Output0 == nil || body.Output1 == nil {
+		return errors.New("transaction envelope body is missing inputs or outputs")
+	}
+	if len(jsonRep.Sigs) > MaxInputs {
+		return errors.New("transaction envelope has more signatures than inputs")
+	}
+
+	e.Transaction = &Transaction{
+		Body: body,
+	}
+	copy(e.Transaction.Sigs[:], jsonRep.Sigs)
+	e.Domain = jsonRep.Domain
+	e.SignatureVersion = omittedSignatureVersion(e.Domain)
+	if jsonRep.SignatureVersion != nil {
+		e.SignatureVersion = *jsonRep.SignatureVersion
+	}
+	sigHash, err := e.SignatureHash()
+	if err != nil {
+		return err
+	}
+	if hexutil.Encode(sigHash) != jsonRep.SignatureHash {
+		return errors.New("transaction envelope signature hash does not match its body")
+	}
+	e.MerkleRoot = nil
+	if jsonRep.MerkleRoot != "" {
+		merkleRoot, err := hexutil.Decode(jsonRep.MerkleRoot)
+		if err != nil {
+			return errors.Wrap(err, "invalid merkle root")
+		}
+		e.MerkleRoot = merkleRoot
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package chain
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionEnvelope_JSON(t *testing.T) {
+	tx := multiInputTransaction()
+	tx.Sigs = [2]Signature{}
+	envelope := NewTransactionEnvelope(tx, nil)
+	require.False(t, envelope.IsSigned())
+
+	data, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	var unsigned TransactionEnvelope
+	require.NoError(t, json.Unmarshal(data, &unsigned))
+	require.Equal(t, tx.Body.SignatureHash(), unsigned.Transaction.Body.SignatureHash())
+	require.Nil(t, unsigned.MerkleRoot)
+
+	tx.Sigs = [2]Signature{RandomConfirmationSig(), RandomConfirmationSig()}
+	tx.Body.BlockNumber = 10
+	envelope.MerkleRoot = util.Sha256([]byte("root"))
+	require.True(t, envelope.IsSigned())
+	data, err = json.Marshal(envelope)
+	require.NoError(t, err)
+	var submitted TransactionEnvelope
+	require.NoError(t, json.Unmarshal(data, &submitted))
+	require.Equal(t, tx.RLPHash(util.Sha256), submitted.Transaction.RLPHash(util.Sha256))
+	require.Equal(t, uint64(10), submitted.Transaction.Body.BlockNumber)
+	require.Equal(t, []byte(envelope.MerkleRoot), submitted.MerkleRoot)
+}
+
+func TestTransactionEnvelope_Version(t *testing.T) {
+	data, err := json.Marshal(NewTransactionEnvelope(multiInputTransaction(), nil))
+	require.NoError(t, err)
+
+	var envelope TransactionEnvelope
+	future := strings.Replace(string(data), `"version":1`, `"version":2`, 1)
+	err = json.Unmarshal([]byte(future), &envelope)
+	require.IsType(t, &ErrUnsupportedEnvelopeVersion{}, err)
+}
+
+func TestTransactionEnvelope_TamperedBody(t *testing.T) {
+	tx := multiInputTransaction()
+	data, err := json.Marshal(NewTransactionEnvelope(tx, nil))
+	require.NoError(t, err)
+
+	// an envelope whose body no longer matches its signature hash is
+	// rejected rather than signed
+	var decoded map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	tx.Body.Output0 = NewOutput(RandomAddress(), tx.Body.Output0.Amount)
+	body, err := json.Marshal(tx.Body)
+	require.NoError(t, err)
+	decoded["body"] = body
+	data, err = json.Marshal(decoded)
+	require.NoError(t, err)
+
+	var envelope TransactionEnvelope
+	require.Error(t, json.Unmarshal(data, &envelope))
+}
+
+func TestTransactionEnvelope_Domain(t *testing.T) {
+	tx := multiInputTransaction()
+	domain := NewDomain(big.NewInt(1), RandomAddress())
+	envelope := NewTransactionEnvelope(tx, domain)
+	require.Equal(t, SignatureVersionLegacy, envelope.SignatureVersion)
+	envelope.SignatureVersion = SignatureVersionDomain
+
+	data, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	var decoded TransactionEnvelope
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, domain, decoded.Domain)
+	expected, err := envelope.SignatureHash()
+	require.NoError(t, err)
+	actual, err := decoded.SignatureHash()
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+	require.NotEqual(t, tx.Body.SignatureHash(), actual)
+}
+
+func TestTransactionEnvelope_LegacyWithDomain(t *testing.T) {
+	tx := multiInputTransaction()
+	envelope := NewTransactionEnvelope(tx, NewDomain(big.NewInt(1), RandomAddress()))
+
+	data, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	var decoded TransactionEnvelope
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, SignatureVersionLegacy, decoded.SignatureVersion)
+	actual, err := decoded.SignatureHash()
+	require.NoError(t, err)
+	require.Equal(t, tx.Body.SignatureHash(), actual)
+}
+
+func TestTransactionEnvelope_TypedData(t *testing.T) {
+	envelope := NewTransactionEnvelope(multiInputTransaction(), NewDomain(big.NewInt(1), RandomAddress()))
+	envelope.SignatureVersion = SignatureVersionTypedData
+
+	data, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	var decoded TransactionEnvelope
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, SignatureVersionTypedData, decoded.SignatureVersion)
+	expected, err := envelope.SignatureHash()
+	require.NoError(t, err)
+	actual, err := decoded.SignatureHash()
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
@@ -0,0 +1,238 @@
+package chain
+
+import (
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kyokan/plasma/util"
+	"math/big"
+)
+
+// typedDataVersion is the version field of the EIP-712 domain.
+const typedDataVersion = "1"
+
+const (
+	typedDomainType       = "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"
+	typedInputType        = "Input(uint64 blockNumber,uint32 transactionIndex,uint8 outputIndex,uint256 depositNonce,bytes confirmSigs)"
+	typedOutputType       = "Output(address owner,uint256 amount)"
+	typedTransactionType  = "Transaction(Input[] inputs,Output[] outputs,uint256 fee)" + typedInputType + typedOutputType
+	typedConfirmationType = "Confirmation(bytes32 transactionHash,bytes32 merkleRoot)"
+)
+
+var typedDataTypes = map[string][]TypedDataField{
+	"EIP712Domain": {
+		{"name", "string"},
+		{"version", "string"},
+		{"chainId", "uint256"},
+		{"verifyingContract", "address"},
+	},
+	"Input": {
+		{"blockNumber", "uint64"},
+		{"transactionIndex", "uint32"},
+		{"outputIndex", "uint8"},
+		{"depositNonce", "uint256"},
+		{"confirmSigs", "bytes"},
+	},
+	"Output": {
+		{"owner", "address"},
+		{"amount", "uint256"},
+	},
+	"Transaction": {
+		{"inputs", "Input[]"},
+		{"outputs", "Output[]"},
+		{"fee", "uint256"},
+	},
+	"Confirmation": {
+		{"transactionHash", "bytes32"},
+		{"merkleRoot", "bytes32"},
+	},
+}
+
+// TypedDataField is a member of an EIP-712 struct type.
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedData is an EIP-712 message in the format accepted by
+// eth_signTypedData, so that wallets can show what is being signed.
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+// TypedDataSeparator returns the EIP-712 domain separator.
+func (d *Domain) TypedDataSeparator() util.Hash {
+	return hashStruct(
+		typedDomainType,
+		util.Keccak256([]byte(domainName)),
+		util.Keccak256([]byte(typedDataVersion)),
+		encodeUint(d.ChainID),
+		encodeAddress(d.Contract),
+	)
+}
+
+func (d *Domain) typedData() map[string]interface{} {
+	return map[string]interface{}{
+		"name":              domainName,
+		"version":           typedDataVersion,
+		"chainId":           json.Number(util.Big2Str(d.ChainID)),
+		"verifyingContract": d.Contract.Hex(),
+	}
+}
+
+// TypedDataHash returns the EIP-712 struct hash of the body. Inputs and
+// outputs after the first are skipped if they are zero, so a transaction
+// hashes the same whatever layout it is encoded in.
+func (b *TransactionBody) TypedDataHash() util.Hash {
+	var inputs []byte
+	for i, input := range []*Input{b.Input0, b.Input1} {
+		if i > 0 && input.IsZero() {
+			continue
+		}
+		inputs = append(inputs, hashStruct(
+			typedInputType,
+			encodeUint(new(big.Int).SetUint64(input.BlockNumber)),
+			encodeUint(new(big.Int).SetUint64(uint64(input.TransactionIndex))),
+			encodeUint(new(big.Int).SetUint64(uint64(input.OutputIndex))),
+			encodeUint(input.DepositNonce),
+			util.Keccak256(typedConfirmSigs(b.InputConfirmSigsAt(uint8(i)))),
+		)...)
+	}
+
+	var outputs []byte
+	for i, output := range []*Output{b.Output0, b.Output1} {
+		if i > 0 && output.IsZeroOutput() {
+			continue
+		}
+		outputs = append(outputs, hashStruct(
+			typedOutputType,
+			encodeAddress(output.Owner),
+			encodeUint(output.Amount),
+		)...)
+	}
+
+	return hashStruct(
+		typedTransactionType,
+		util.Keccak256(inputs),
+		util.Keccak256(outputs),
+		encodeUint(b.Fee),
+	)
+}
+
+// TypedData returns the body as an EIP-712 message bound to domain.
+func (b *TransactionBody) TypedData(domain *Domain) *TypedData {
+	inputs := make([]interface{}, 0)
+	for i, input := range []*Input{b.Input0, b.Input1} {
+		if i > 0 && input.IsZero() {
+			continue
+		}
+		inputs = append(inputs, map[string]interface{}{
+			"blockNumber":      json.Number(util.Uint642Str(input.BlockNumber)),
+			"transactionIndex": json.Number(util.Uint642Str(uint64(input.TransactionIndex))),
+			"outputIndex":      json.Number(util.Uint642Str(uint64(input.OutputIndex))),
+			"depositNonce":     typedBig(input.DepositNonce),
+			"confirmSigs":      hexutil.Encode(typedConfirmSigs(b.InputConfirmSigsAt(uint8(i)))),
+		})
+	}
+
+	outputs := make([]interface{}, 0)
+	for i, output := range []*Output{b.Output0, b.Output1} {
+		if i > 0 && output.IsZeroOutput() {
+			continue
+		}
+		outputs = append(outputs, map[string]interface{}{
+			"owner":  output.Owner.Hex(),
+			"amount": typedBig(output.Amount),
+		})
+	}
+
+	return newTypedData("Transaction", domain, map[string]interface{}{
+		"inputs":  inputs,
+		"outputs": outputs,
+		"fee":     typedBig(b.Fee),
+	})
+}
+
+// ConfirmationTypedDataHash returns the EIP-712 struct hash of the
+// confirmation of the transaction's inclusion in the block with merkleRoot.
+func (c *Transaction) ConfirmationTypedDataHash(merkleRoot util.Hash) util.Hash {
+	return hashStruct(
+		typedConfirmationType,
+		c.RLPHash(util.Sha256),
+		common.LeftPadBytes(merkleRoot, 32),
+	)
+}
+
+// ConfirmationTypedData returns the confirmation of the transaction's
+// inclusion in the block with merkleRoot as an EIP-712 message bound to
+// domain.
+func (c *Transaction) ConfirmationTypedData(domain *Domain, merkleRoot util.Hash) *TypedData {
+	return newTypedData("Confirmation", domain, map[string]interface{}{
+		"transactionHash": hexutil.Encode(c.RLPHash(util.Sha256)),
+		"merkleRoot":      hexutil.Encode(common.LeftPadBytes(merkleRoot, 32)),
+	})
+}
+
+func newTypedData(primaryType string, domain *Domain, message map[string]interface{}) *TypedData {
+	types := map[string][]TypedDataField{
+		"EIP712Domain": typedDataTypes["EIP712Domain"],
+		primaryType:    typedDataTypes[primaryType],
+	}
+	if primaryType == "Transaction" {
+		types["Input"] = typedDataTypes["Input"]
+		types["Output"] = typedDataTypes["Output"]
+	}
+
+	return &TypedData{
+		Types:       types,
+		PrimaryType: primaryType,
+		Domain:      domain.typedData(),
+		Message:     message,
+	}
+}
+
+// typedConfirmSigs concatenates sigs without the unset signatures that pad
+// them out to the fixed layout.
+func typedConfirmSigs(sigs [2]Signature) []byte {
+	var zero Signature
+	end := len(sigs)
+	for end > 0 && sigs[end-1] == zero {
+		end--
+	}
+
+	buf := make([]byte, 0, end*len(zero))
+	for _, sig := range sigs[:end] {
+		buf = append(buf, sig[:]...)
+	}
+	return buf
+}
+
+func typedBig(n *big.Int) string {
+	if n == nil {
+		return "0"
+	}
+	return util.Big2Str(n)
+}
+
+func hashStruct(typ string, fields ...[]byte) util.Hash {
+	buf := make([]byte, 0, 32*(len(fields)+1))
+	buf = append(buf, util.Keccak256([]byte(typ))...)
+	for _, field := range fields {
+		buf = append(buf, field...)
+	}
+	return util.Keccak256(buf)
+}
+
+func encodeUint(n *big.Int) []byte {
+	if n == nil {
+		return make([]byte, 32)
+	}
+	return common.LeftPadBytes(n.Bytes(), 32)
+}
+
+func encodeAddress(addr common.Address) []byte {
+	return common.LeftPadBytes(addr.Bytes(), 32)
+}
@@ -0,0 +1,47 @@
+package chain
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedDataHash(t *testing.T) {
+	body := ZeroBody()
+	body.Input0 = NewInput(1, 2, 3, Zero())
+	body.Output0 = NewOutput(common.HexToAddress("0x627306090abab3a6e1400e9345bc60c78a8bef57"), big.NewInt(100))
+	body.Fee = big.NewInt(5)
+	domain := NewDomain(big.NewInt(1), common.HexToAddress("0xf25186b5081ff5ce73482ad761db0eb0d25abfbf"))
+
+	require.Equal(t, "0x6aa2cb84ffab472384fcc8af9145c857f56b1fe93d3a93627d1489efd694a289", hexutil.Encode(domain.TypedDataSeparator()))
+	require.Equal(t, "0xb7f296db5aa903b4ae28ff1a9eaa885c6baae5498b6efdbd27dfd5ed4150a97c", hexutil.Encode(body.TypedDataHash()))
+	digest, err := body.VersionedSignatureHash(SignatureVersionTypedData, domain)
+	require.NoError(t, err)
+	require.Equal(t, "0x0724255e56bd6bedd31d447b2a206381ecbe70729fd294abbcbdaf863ce36276", hexutil.Encode(digest))
+}
+
+func TestTypedData_JSON(t *testing.T) {
+	tx := multiInputTransaction()
+	domain := NewDomain(big.NewInt(1), RandomAddress())
+
+	data, err := json.Marshal(tx.Body.TypedData(domain))
+	require.NoError(t, err)
+	var typedData TypedData
+	require.NoError(t, json.Unmarshal(data, &typedData))
+	require.Equal(t, "Transaction", typedData.PrimaryType)
+	require.Len(t, typedData.Types, 4)
+	require.Len(t, typedData.Message["inputs"], 2)
+	require.Len(t, typedData.Message["outputs"], 2)
+	require.Equal(t, domain.Contract.Hex(), typedData.Domain["verifyingContract"])
+
+	root := util.Sha256([]byte("root"))
+	confirmation := tx.ConfirmationTypedData(domain, root)
+	require.Equal(t, "Confirmation", confirmation.PrimaryType)
+	require.Len(t, confirmation.Types, 2)
+	require.Equal(t, hexutil.Encode(root), confirmation.Message["merkleRoot"])
+}
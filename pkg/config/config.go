@@ -1,8 +1,46 @@
 package config
 
+import (
+	"math/big"
+	"time"
+)
+
 type GlobalConfig struct {
-	DBPath       string
-	NodeURL      string
-	RPCPort      int
-	ContractAddr string
+	DBPath          string
+	DBBackend       string
+	NodeURL         string
+	RPCPort         int
+	RESTPort        int
+	RPCHost         string
+	RESTHost        string
+	ContractAddr    string
+	ShutdownTimeout time.Duration
+	PruneDepth      uint64
+	// StrictSignaturesFrom is the first block a validator requires
+	// canonical signatures in. See validation.SignaturePolicy.StrictFrom.
+	StrictSignaturesFrom uint64
+	// MinFee is the smallest fee the root node's mempool accepts. Nil
+	// accepts any fee.
+	MinFee *big.Int
+	// PeerRateLimit and AddressRateLimit are the write requests per second
+	// allowed per client IP and per sending address. Zero disables them.
+	PeerRateLimit    float64
+	AddressRateLimit float64
+	// Peers that send BanThreshold invalid transactions are banned for
+	// BanDuration. Zero disables banning.
+	BanThreshold int
+	BanDuration  time.Duration
+	// RateLimitExempt lists client IPs, such as validators', that are not
+	// rate limited or banned.
+	RateLimitExempt []string
+	// TLSCertFile and TLSKeyFile enable TLS on the gRPC and REST servers.
+	// Clients with certificates signed by TLSClientCAFile are
+	// authenticated for write requests.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	// APIKeysFile and JWTSecretFile require write requests to carry an
+	// API key or a JWT signed with the secret. Empty disables them.
+	APIKeysFile   string
+	JWTSecretFile string
 }
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"github.com/ethereum/go-ethereum/rlp"
+	"math/big"
+)
+
+// FeeEntry records the fees collected in a block and, once the operator
+// has started a fee exit for them, the Ethereum transaction that did so.
+type FeeEntry struct {
+	BlockNumber             uint64
+	Amount                  *big.Int
+	EthereumTransactionHash []byte
+}
+
+// Exited returns true if a fee exit has been started for the entry.
+func (f *FeeEntry) Exited() bool {
+	return len(f.EthereumTransactionHash) > 0
+}
+
+func (f *FeeEntry) MarshalBinary() ([]byte, error) {
+	return rlp.EncodeToBytes(f)
+}
+
+func (f *FeeEntry) UnmarshalBinary(data []byte) error {
+	return rlp.DecodeBytes(data, f)
+}
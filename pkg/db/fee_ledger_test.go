@@ -0,0 +1,86 @@
+package db
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/stretchr/testify/require"
+)
+
+// populateFeeChain packages blocks 1 through 10, with fees of 3 in block 2
+// and 7 in block 10. Block numbers 2 and 10 sort in opposite orders as
+// strings and as numbers.
+func populateFeeChain(t *testing.T, s Storage) {
+	alice := chain.RandomAddress()
+	for i := 1; i <= 10; i++ {
+		tx := depositTx(int64(i), alice, 100)
+		switch i {
+		case 2:
+			tx.Body.Fee = big.NewInt(3)
+		case 10:
+			tx.Body.Fee = big.NewInt(7)
+		}
+		_, err := s.ProcessDeposit(tx)
+		require.NoError(t, err)
+	}
+}
+
+func TestFeeEntry_MarshalUnmarshal(t *testing.T) {
+	entry := &FeeEntry{
+		BlockNumber:             12,
+		Amount:                  big.NewInt(500),
+		EthereumTransactionHash: common.HexToHash("0x01").Bytes(),
+	}
+	enc, err := entry.MarshalBinary()
+	require.NoError(t, err)
+	var decoded FeeEntry
+	require.NoError(t, decoded.UnmarshalBinary(enc))
+	require.Equal(t, entry, &decoded)
+	require.True(t, decoded.Exited())
+}
+
+func TestMigrate_BackfillsFeeLedger(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateFeeChain(t, s)
+
+	hash := common.HexToHash("0x01")
+	require.NoError(t, s.MarkFeesExited(2, hash))
+	require.NoError(t, kv.Delete(feeKey(10)))
+	require.NoError(t, saveSchemaVersion(kv, 1))
+
+	require.NoError(t, Migrate(kv))
+	ledger, err := s.FeeLedger()
+	require.NoError(t, err)
+	require.Len(t, ledger, 2)
+	// existing entries keep their exits
+	require.Equal(t, hash[:], ledger[0].EthereumTransactionHash)
+	require.Equal(t, uint64(10), ledger[1].BlockNumber)
+	require.Equal(t, int64(7), ledger[1].Amount.Int64())
+	require.False(t, ledger[1].Exited())
+}
+
+func TestSnapshot_FeeExits(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateFeeChain(t, s)
+	require.NoError(t, s.MarkFeesExited(2, common.HexToHash("0x01")))
+
+	var buf bytes.Buffer
+	_, err := ExportSnapshot(s, &buf, 0)
+	require.NoError(t, err)
+
+	importedKV, imported := newMemoryLevelStorage(t)
+	defer importedKV.Close()
+	_, err = ImportSnapshot(importedKV, bytes.NewReader(buf.Bytes()), 0)
+	require.NoError(t, err)
+
+	expected, err := s.FeeLedger()
+	require.NoError(t, err)
+	actual, err := imported.FeeLedger()
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+}
@@ -0,0 +1,487 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/merkle"
+	"github.com/kyokan/plasma/util"
+	"github.com/syndtr/goleveldb/leveldb"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+type IntegrityIssueKind string
+
+const (
+	IssueMissingBlock       IntegrityIssueKind = "missing_block"
+	IssueBlockHash          IntegrityIssueKind = "block_hash"
+	IssuePrevHash           IntegrityIssueKind = "prev_hash"
+	IssueMissingMeta        IntegrityIssueKind = "missing_meta"
+	IssueMissingTransaction IntegrityIssueKind = "missing_transaction"
+	IssueTransactionCount   IntegrityIssueKind = "transaction_count"
+	IssueTransactionIndex   IntegrityIssueKind = "transaction_index"
+	IssueMerkleRoot         IntegrityIssueKind = "merkle_root"
+	IssueFees               IntegrityIssueKind = "fees"
+	IssueInvalidInput       IntegrityIssueKind = "invalid_input"
+	IssueDoubleSpend        IntegrityIssueKind = "double_spend"
+	IssueUTXOIndex          IntegrityIssueKind = "utxo_index"
+	IssueSpendIndex         IntegrityIssueKind = "spend_index"
+	IssueDepositIndex       IntegrityIssueKind = "deposit_index"
+)
+
+type IntegrityIssue struct {
+	Kind        IntegrityIssueKind `json:"kind"`
+	BlockNumber uint64             `json:"blockNumber,omitempty"`
+	Message     string             `json:"message"`
+}
+
+func (i IntegrityIssue) String() string {
+	if i.BlockNumber == 0 {
+		return fmt.Sprintf("[%s] %s", i.Kind, i.Message)
+	}
+	return fmt.Sprintf("[%s] block %d: %s", i.Kind, i.BlockNumber, i.Message)
+}
+
+type IntegrityReport struct {
+	Height           uint64           `json:"height"`
+	TransactionCount uint64           `json:"transactionCount"`
+	PrunedCount      uint64           `json:"prunedCount"`
+	Issues           []IntegrityIssue `json:"issues"`
+}
+
+func (r *IntegrityReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+// HasBlockIssues returns true if the block data itself is inconsistent.
+// Block data issues cannot be repaired by rebuilding derived indexes.
+func (r *IntegrityReport) HasBlockIssues() bool {
+	for _, issue := range r.Issues {
+		switch issue.Kind {
+		case IssueUTXOIndex, IssueSpendIndex, IssueDepositIndex:
+			continue
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func (r *IntegrityReport) addIssue(kind IntegrityIssueKind, blockNum uint64, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, IntegrityIssue{
+		Kind:        kind,
+		BlockNumber: blockNum,
+		Message:     fmt.Sprintf(format, args...),
+	})
+}
+
+// derivedIndexes holds the UTXO, spend and deposit indexes as they should
+// be given the block data, keyed by their LevelDB key.
+type derivedIndexes struct {
+	utxos    map[string][]byte
+	spends   map[string][]byte
+	deposits map[string][]byte
+}
+
+type integrityChecker struct {
+	ps      *LevelStorage
+	report  *IntegrityReport
+	indexes *derivedIndexes
+	// txs holds every transaction seen so far, keyed by block number and
+	// transaction index, so that inputs can be resolved without trusting
+	// the indexes being checked.
+	txs map[string]*chain.ConfirmedTransaction
+	// pruned holds the hashes of pruned transactions, keyed by block
+	// number and transaction index. Their outputs are all spent or exited.
+	pruned map[string]string
+}
+
+// CheckIntegrity walks every block in the database, verifying the block
+// hash chain, Merkle roots and block metadata, and replays all transactions
+// and exits to verify the UTXO, spend and deposit indexes.
+func CheckIntegrity(kv KV) (*IntegrityReport, error) {
+	checker, err := newIntegrityChecker(kv)
+	if err != nil {
+		return nil, err
+	}
+	if err := checker.compareIndexes(); err != nil {
+		return nil, err
+	}
+	return checker.report, nil
+}
+
+// RebuildIndexes replaces the UTXO, spend and deposit indexes with ones
+// recomputed from the block data, returning the issues found before the
+// rebuild. It refuses to run if the block data itself is inconsistent.
+func RebuildIndexes(kv KV) (*IntegrityReport, error) {
+	checker, err := newIntegrityChecker(kv)
+	if err != nil {
+		return nil, err
+	}
+	if err := checker.compareIndexes(); err != nil {
+		return nil, err
+	}
+	if checker.report.HasBlockIssues() {
+		return checker.report, fmt.Errorf("cannot rebuild indexes: block data is inconsistent")
+	}
+	if err := checker.writeIndexes(); err != nil {
+		return nil, err
+	}
+
+	return checker.report, nil
+}
+
+func (c *integrityChecker) writeIndexes() error {
+	batch := new(leveldb.Batch)
+	for _, prefix := range []string{utxoPrefix, spendPrefix, depositPrefix} {
+		iter := c.ps.db.NewIterator(joinKey(prefix, ""))
+		for iter.Next() {
+			batch.Delete(copyBytes(iter.Key()))
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+	for _, index := range []map[string][]byte{c.indexes.utxos, c.indexes.spends, c.indexes.deposits} {
+		for k, v := range index {
+			batch.Put([]byte(k), v)
+		}
+	}
+	return c.ps.db.Write(batch)
+}
+
+func newIntegrityChecker(kv KV) (*integrityChecker, error) {
+	c := &integrityChecker{
+		ps:     &LevelStorage{db: kv},
+		report: &IntegrityReport{},
+		indexes: &derivedIndexes{
+			utxos:    make(map[string][]byte),
+			spends:   make(map[string][]byte),
+			deposits: make(map[string][]byte),
+		},
+		txs:    make(map[string]*chain.ConfirmedTransaction),
+		pruned: make(map[string]string),
+	}
+
+	latest, err := c.ps.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return c, nil
+	}
+	c.report.Height = latest.Header.Number
+
+	var prevHash util.Hash
+	for num := uint64(1); num <= latest.Header.Number; num++ {
+		block, err := c.ps.BlockAtHeight(num)
+		if err == ErrNotFound {
+			c.report.addIssue(IssueMissingBlock, num, "block not found")
+			prevHash = nil
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkBlock(num, block, prevHash); err != nil {
+			return nil, err
+		}
+		prevHash = block.BlockHash
+	}
+
+	if err := c.replayExits(); err != nil {
+		return nil, err
+	}
+	if err := c.keepPrunedSpends(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *integrityChecker) checkBlock(num uint64, block *chain.Block, prevHash util.Hash) error {
+	if block.Header.Number != num {
+		c.report.addIssue(IssueBlockHash, num, "header has number %d", block.Header.Number)
+	}
+	if !bytes.Equal(block.Header.Hash(), block.BlockHash) {
+		c.report.addIssue(IssueBlockHash, num, "stored hash %s does not match header hash %s", hexutil.Encode(block.BlockHash), hexutil.Encode(block.Header.Hash()))
+	}
+	if prevHash != nil && !bytes.Equal(prevHash, block.Header.PrevHash) {
+		c.report.addIssue(IssuePrevHash, num, "previous hash %s does not match hash of block %d", hexutil.Encode(block.Header.PrevHash), num-1)
+	}
+
+	meta, err := c.ps.BlockMetaAtHeight(num)
+	if err == ErrNotFound {
+		c.report.addIssue(IssueMissingMeta, num, "block metadata not found")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	indexed, err := c.countIndexedTransactions(num)
+	if err != nil {
+		return err
+	}
+	if indexed != meta.TransactionCount {
+		c.report.addIssue(IssueTransactionCount, num, "metadata records %d transactions, found %d", meta.TransactionCount, indexed)
+	}
+
+	hashables := make([]util.RLPHashable, 0, meta.TransactionCount)
+	fees := big.NewInt(0)
+	hasPruned := false
+	for i := uint32(0); i < meta.TransactionCount; i++ {
+		tx, err := c.ps.findTransactionByBlockNumTxIdx(num, i)
+		if _, ok := err.(*ErrPruned); ok {
+			hash, err := c.ps.db.Get(txByBlockNumTxIdxKey(num, i))
+			if err != nil {
+				return err
+			}
+			leaf, err := hexutil.Decode(string(hash))
+			if err != nil {
+				return err
+			}
+			hashables = append(hashables, prunedLeaf(leaf))
+			c.pruned[positionKey(num, i)] = string(hash)
+			c.report.TransactionCount++
+			c.report.PrunedCount++
+			hasPruned = true
+			continue
+		}
+		if err == ErrNotFound {
+			c.report.addIssue(IssueMissingTransaction, num, "transaction %d not found", i)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if tx.Transaction.Body.BlockNumber != num || tx.Transaction.Body.TransactionIndex != i {
+			c.report.addIssue(IssueTransactionIndex, num, "transaction %d is recorded at %d:%d", i, tx.Transaction.Body.BlockNumber, tx.Transaction.Body.TransactionIndex)
+		}
+
+		hashables = append(hashables, tx.Transaction)
+		c.report.TransactionCount++
+		fees = fees.Add(fees, tx.Transaction.Body.Fee)
+		c.replayTransaction(num, i, tx)
+	}
+
+	if merkleRoot := merkle.Root(hashables); !bytes.Equal(merkleRoot, block.Header.MerkleRoot) {
+		c.report.addIssue(IssueMerkleRoot, num, "header root %s does not match computed root %s", hexutil.Encode(block.Header.MerkleRoot), hexutil.Encode(merkleRoot))
+	}
+	// pruned transactions' fees are unknown
+	if !hasPruned && (meta.Fees == nil || fees.Cmp(meta.Fees) != 0) {
+		c.report.addIssue(IssueFees, num, "metadata records %s in fees, transactions pay %s", meta.Fees, fees)
+	}
+
+	return nil
+}
+
+func (c *integrityChecker) countIndexedTransactions(num uint64) (uint32, error) {
+	iter := c.ps.db.NewIterator(txByBlockNumIterKey(num))
+	defer iter.Release()
+
+	var count uint32
+	for iter.Next() {
+		count++
+	}
+	return count, iter.Error()
+}
+
+func (c *integrityChecker) replayTransaction(blockNum uint64, txIdx uint32, tx *chain.ConfirmedTransaction) {
+	body := tx.Transaction.Body
+	hash := tx.Hash()
+	hexHash := []byte(hexutil.Encode(hash))
+	c.txs[positionKey(blockNum, txIdx)] = tx
+
+	if body.IsDeposit() {
+		nonce := body.InputAt(0).DepositNonce
+		key := string(depositKey(nonce))
+		if _, exists := c.indexes.deposits[key]; exists {
+			c.report.addIssue(IssueDoubleSpend, blockNum, "transaction %d spends deposit %s twice", txIdx, nonce)
+		}
+		c.indexes.deposits[key] = hexHash
+	} else {
+		for i := uint8(0); int(i) < body.InputCount(); i++ {
+			input := body.InputAt(i)
+			if i > 0 && input.IsZero() {
+				continue
+			}
+
+			prev, ok := c.txs[positionKey(input.BlockNumber, input.TransactionIndex)]
+			_, pruned := c.pruned[positionKey(input.BlockNumber, input.TransactionIndex)]
+			if !ok && !pruned {
+				c.report.addIssue(IssueInvalidInput, blockNum, "transaction %d input %d refers to unknown transaction %d:%d", txIdx, i, input.BlockNumber, input.TransactionIndex)
+				continue
+			}
+			spendKey := string(spendByTxIdxKey(input.BlockNumber, input.TransactionIndex, input.OutputIndex))
+			if _, exists := c.indexes.spends[spendKey]; exists {
+				c.report.addIssue(IssueDoubleSpend, blockNum, "transaction %d input %d spends %d:%d:%d twice", txIdx, i, input.BlockNumber, input.TransactionIndex, input.OutputIndex)
+			}
+			c.indexes.spends[spendKey] = hexHash
+			if pruned {
+				// pruned outputs were never added to the UTXO index
+				continue
+			}
+			delete(c.indexes.utxos, string(utxoKey(prev.Transaction.Body.OutputAt(input.OutputIndex).Owner, prev.Hash(), input.OutputIndex)))
+		}
+	}
+
+	for i, output := range []*chain.Output{body.Output0, body.Output1} {
+		if output.IsZeroOutput() {
+			continue
+		}
+		c.indexes.utxos[string(utxoKey(output.Owner, hash, uint8(i)))] = []byte{}
+	}
+}
+
+func (c *integrityChecker) replayExits() error {
+	exits, err := c.ps.Exits()
+	if err != nil {
+		return err
+	}
+
+	for _, loc := range exits {
+		position := positionKey(loc.PlasmaBlockNumber, loc.PlasmaTransactionIndex)
+		if _, pruned := c.pruned[position]; pruned {
+			continue
+		}
+		tx, ok := c.txs[position]
+		if !ok {
+			c.report.addIssue(IssueInvalidInput, loc.PlasmaBlockNumber, "exit refers to unknown transaction %d", loc.PlasmaTransactionIndex)
+			continue
+		}
+		delete(c.indexes.utxos, string(utxoKey(tx.Transaction.Body.OutputAt(loc.PlasmaOutputIndex).Owner, tx.Hash(), loc.PlasmaOutputIndex)))
+	}
+
+	return nil
+}
+
+// keepPrunedSpends adds the spend and deposit entries written by pruned
+// transactions to the expected indexes. They cannot be recomputed without
+// the transaction bodies, but are needed to detect double spends.
+func (c *integrityChecker) keepPrunedSpends() error {
+	if len(c.pruned) == 0 {
+		return nil
+	}
+	prunedHashes := make(map[string]bool)
+	for _, hash := range c.pruned {
+		prunedHashes[hash] = true
+	}
+
+	for _, index := range []struct {
+		prefix   string
+		expected map[string][]byte
+	}{
+		{spendPrefix, c.indexes.spends},
+		{depositPrefix, c.indexes.deposits},
+	} {
+		iter := c.ps.db.NewIterator(joinKey(index.prefix, ""))
+		for iter.Next() {
+			key := string(iter.Key())
+			if _, exists := index.expected[key]; exists || !prunedHashes[string(iter.Value())] {
+				continue
+			}
+			index.expected[key] = copyBytes(iter.Value())
+			if index.prefix == spendPrefix {
+				c.removeSpentUTXO(key)
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeSpentUTXO removes the output referred to by a spend key from the
+// expected UTXO index, if the transaction it belongs to is still present.
+func (c *integrityChecker) removeSpentUTXO(spendKey string) {
+	parts := strings.Split(spendKey, keyPartsSeparator)
+	if len(parts) != 5 {
+		return
+	}
+	blockNum, ok := util.Str2Uint64(parts[2])
+	if !ok {
+		return
+	}
+	txIdx, ok := util.Str2Uint32(parts[3])
+	if !ok {
+		return
+	}
+	outIdx, ok := util.Str2Uint8(parts[4])
+	if !ok {
+		return
+	}
+	tx, ok := c.txs[positionKey(blockNum, txIdx)]
+	if !ok {
+		return
+	}
+	delete(c.indexes.utxos, string(utxoKey(tx.Transaction.Body.OutputAt(outIdx).Owner, tx.Hash(), outIdx)))
+}
+
+func (c *integrityChecker) compareIndexes() error {
+	checks := []struct {
+		kind     IntegrityIssueKind
+		prefix   string
+		expected map[string][]byte
+	}{
+		{IssueUTXOIndex, utxoPrefix, c.indexes.utxos},
+		{IssueSpendIndex, spendPrefix, c.indexes.spends},
+		{IssueDepositIndex, depositPrefix, c.indexes.deposits},
+	}
+
+	for _, check := range checks {
+		actual := make(map[string][]byte)
+		iter := c.ps.db.NewIterator(joinKey(check.prefix, ""))
+		for iter.Next() {
+			actual[string(iter.Key())] = copyBytes(iter.Value())
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+
+		for _, key := range sortedKeys(check.expected) {
+			val, ok := actual[key]
+			if !ok {
+				c.report.addIssue(check.kind, 0, "missing entry %s", key)
+				continue
+			}
+			if !bytes.Equal(val, check.expected[key]) {
+				c.report.addIssue(check.kind, 0, "entry %s is %s, expected %s", key, val, check.expected[key])
+			}
+		}
+		for _, key := range sortedKeys(actual) {
+			if _, ok := check.expected[key]; !ok {
+				c.report.addIssue(check.kind, 0, "unexpected entry %s", key)
+			}
+		}
+	}
+
+	return nil
+}
+
+func positionKey(blockNum uint64, txIdx uint32) string {
+	return fmt.Sprintf("%d:%d", blockNum, txIdx)
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyBytes(b []byte) []byte {
+	ret := make([]byte, len(b))
+	copy(ret, b)
+	return ret
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newMemoryLevelStorage(t *testing.T) (KV, Storage) {
+	kv, err := NewMemoryKV()
+	require.NoError(t, err)
+	return kv, NewKVStorage(kv)
+}
+
+func depositTx(nonce int64, owner common.Address, amount int64) chain.Transaction {
+	body := chain.ZeroBody()
+	body.Input0.DepositNonce = big.NewInt(nonce)
+	body.Output0 = chain.NewOutput(owner, big.NewInt(amount))
+	return chain.Transaction{
+		Body: body,
+	}
+}
+
+func spendTx(inputs []*chain.Input, outputs []*chain.Output) chain.Transaction {
+	body := chain.ZeroBody()
+	body.Input0 = inputs[0]
+	if len(inputs) > 1 {
+		body.Input1 = inputs[1]
+	}
+	body.Output0 = outputs[0]
+	if len(outputs) > 1 {
+		body.Output1 = outputs[1]
+	}
+	return chain.Transaction{
+		Body: body,
+	}
+}
+
+// populateChain creates the following blocks:
+//
+//	1: deposit of 100 to alice
+//	2: alice sends 60 to bob, 40 back to alice
+//	3: deposit of 10 to bob
+//	4: bob spends the deposit and alice's change
+func populateChain(t *testing.T, s Storage, alice, bob common.Address) {
+	_, err := s.ProcessDeposit(depositTx(1, alice, 100))
+	require.NoError(t, err)
+	_, err = s.PackageBlock([]chain.Transaction{
+		spendTx(
+			[]*chain.Input{chain.NewInput(1, 0, 0, chain.Zero())},
+			[]*chain.Output{chain.NewOutput(bob, big.NewInt(60)), chain.NewOutput(alice, big.NewInt(40))},
+		),
+	})
+	require.NoError(t, err)
+	_, err = s.ProcessDeposit(depositTx(2, bob, 10))
+	require.NoError(t, err)
+	_, err = s.PackageBlock([]chain.Transaction{
+		spendTx(
+			[]*chain.Input{chain.NewInput(3, 0, 0, chain.Zero()), chain.NewInput(2, 0, 1, chain.Zero())},
+			[]*chain.Output{chain.NewOutput(bob, big.NewInt(50))},
+		),
+	})
+	require.NoError(t, err)
+}
+
+func TestCheckIntegrity_Consistent(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	populateChain(t, s, alice, bob)
+
+	report, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+	require.Equal(t, uint64(4), report.Height)
+	require.Equal(t, uint64(4), report.TransactionCount)
+
+	// alice's change was spent as input 1 of block 4
+	aliceBal, err := s.Balance(alice)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), aliceBal.Int64())
+	bobBal, err := s.Balance(bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(110), bobBal.Int64())
+}
+
+func TestCheckIntegrity_DetectsAndRebuildsIndexes(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	populateChain(t, s, alice, bob)
+
+	tx, err := s.FindTransactionByBlockNumTxIdx(2, 0)
+	require.NoError(t, err)
+	require.NoError(t, kv.Delete(utxoKey(bob, tx.Hash(), 0)))
+	require.NoError(t, kv.Put(spendByTxIdxKey(3, 0, 1), []byte("0x00")))
+	require.NoError(t, kv.Delete(depositKey(big.NewInt(2))))
+
+	report, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Len(t, report.Issues, 3)
+	require.False(t, report.HasBlockIssues())
+	kinds := make(map[IntegrityIssueKind]bool)
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] = true
+	}
+	require.True(t, kinds[IssueUTXOIndex])
+	require.True(t, kinds[IssueSpendIndex])
+	require.True(t, kinds[IssueDepositIndex])
+
+	report, err = RebuildIndexes(kv)
+	require.NoError(t, err)
+	require.Len(t, report.Issues, 3)
+
+	report, err = CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+}
+
+func TestCheckIntegrity_DetectsBlockIssues(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateChain(t, s, chain.RandomAddress(), chain.RandomAddress())
+
+	block, err := s.BlockAtHeight(2)
+	require.NoError(t, err)
+	block.Header.MerkleRoot = util.Sha256([]byte("corrupted"))
+	enc, err := rlp.EncodeToBytes(block)
+	require.NoError(t, err)
+	require.NoError(t, kv.Put(blockPrefixKey(hexutil.Encode(block.BlockHash)), enc))
+
+	report, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.True(t, report.HasBlockIssues())
+
+	_, err = RebuildIndexes(kv)
+	require.Error(t, err)
+}
+
+func TestFindTransactionsByBlockNum_DoesNotMatchLongerBlockNumbers(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+
+	for i := int64(1); i <= 11; i++ {
+		_, err := s.ProcessDeposit(depositTx(i, chain.RandomAddress(), 1))
+		require.NoError(t, err)
+	}
+
+	txs, err := s.FindTransactionsByBlockNum(1)
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	require.Equal(t, uint64(1), txs[0].Transaction.Body.BlockNumber)
+}
+
+func TestCheckIntegrity_MultiInputTransaction(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	carol := chain.RandomAddress()
+	populateChain(t, s, alice, bob)
+	for nonce := int64(3); nonce <= 5; nonce++ {
+		_, err := s.ProcessDeposit(depositTx(nonce, alice, nonce))
+		require.NoError(t, err)
+	}
+
+	// block 8: alice spends deposits 3 and 4 to bob and carol
+	multi := spendTx(
+		[]*chain.Input{
+			chain.NewInput(5, 0, 0, chain.Zero()),
+			chain.NewInput(6, 0, 0, chain.Zero()),
+		},
+		[]*chain.Output{
+			chain.NewOutput(bob, big.NewInt(5)),
+			chain.NewOutput(carol, big.NewInt(2)),
+		},
+	)
+	_, err := s.PackageBlock([]chain.Transaction{multi})
+	require.NoError(t, err)
+
+	tx, err := s.FindTransactionByBlockNumTxIdx(8, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint8(2), tx.Transaction.Body.InputCount())
+	require.Equal(t, carol, tx.Transaction.Body.Output1.Owner)
+
+	balances := map[common.Address]int64{alice: 5, bob: 115, carol: 2}
+	for addr, expected := range balances {
+		bal, err := s.Balance(addr)
+		require.NoError(t, err)
+		require.Equal(t, expected, bal.Int64())
+	}
+
+	// the second input is recorded as spent
+	doubleSpend := spendTx(
+		[]*chain.Input{chain.NewInput(6, 0, 0, chain.Zero())},
+		[]*chain.Output{chain.NewOutput(bob, big.NewInt(4))},
+	)
+	spent, err := s.IsDoubleSpent(&doubleSpend)
+	require.NoError(t, err)
+	require.True(t, spent)
+
+	report, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+	require.Equal(t, uint64(8), report.TransactionCount)
+}
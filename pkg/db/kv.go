@@ -0,0 +1,88 @@
+package db
+
+import (
+	"fmt"
+	"github.com/syndtr/goleveldb/leveldb"
+	"os"
+	"path"
+)
+
+const (
+	BackendLevelDB = "leveldb"
+	BackendBolt    = "bolt"
+	BackendMemory  = "memory"
+)
+
+// ErrNotFound is returned by KV.Get when a key does not exist. It is the
+// same value as leveldb.ErrNotFound, so every backend can be checked the
+// same way.
+var ErrNotFound = leveldb.ErrNotFound
+
+// KV is the ordered key-value store that LevelStorage's key layout is
+// written to. Batches are built with leveldb.Batch regardless of backend,
+// and must be applied atomically.
+type KV interface {
+	Get(key []byte) ([]byte, error)
+	Has(key []byte) (bool, error)
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	Write(batch *leveldb.Batch) error
+	// NewIterator iterates over all keys starting with prefix in
+	// lexicographical order. A nil prefix iterates over every key.
+	NewIterator(prefix []byte) Iterator
+	Close() error
+}
+
+type Iterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Release()
+	Error() error
+}
+
+type ErrUnknownBackend struct {
+	Backend string
+}
+
+func NewErrUnknownBackend(backend string) error {
+	return &ErrUnknownBackend{
+		Backend: backend,
+	}
+}
+
+func (e *ErrUnknownBackend) Error() string {
+	return fmt.Sprintf("unknown database backend %s", e.Backend)
+}
+
+// OpenKV opens the given backend in location, which is ignored by the
+// memory backend.
+func OpenKV(backend string, location string) (KV, error) {
+	switch backend {
+	case BackendLevelDB, "":
+		return OpenLevelKV(path.Join(location, "db"))
+	case BackendBolt:
+		if err := os.MkdirAll(location, 0700); err != nil {
+			return nil, err
+		}
+		return OpenBoltKV(path.Join(location, "plasma.bolt"))
+	case BackendMemory:
+		return NewMemoryKV()
+	default:
+		return nil, NewErrUnknownBackend(backend)
+	}
+}
+
+// CreateStorage opens the given backend in location and migrates it to
+// the current schema version.
+func CreateStorage(backend string, location string) (KV, Storage, error) {
+	kv, err := OpenKV(backend, location)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := Migrate(kv); err != nil {
+		kv.Close()
+		return nil, nil, err
+	}
+	return kv, NewKVStorage(kv), nil
+}
@@ -0,0 +1,216 @@
+package db
+
+import (
+	"bytes"
+	"github.com/syndtr/goleveldb/leveldb"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+var boltBucket = []byte("plasma")
+
+type boltKV struct {
+	db *bolt.DB
+}
+
+// OpenBoltKV opens a BoltDB file, storing every key in a single bucket.
+func OpenBoltKV(location string) (KV, error) {
+	db, err := bolt.Open(location, 0600, &bolt.Options{
+		Timeout: time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &boltKV{
+		db: db,
+	}, nil
+}
+
+func (b *boltKV) Get(key []byte) ([]byte, error) {
+	var ret []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(boltBucket).Get(key)
+		if val == nil {
+			return ErrNotFound
+		}
+		// values are only valid for the life of the transaction
+		ret = copyBytes(val)
+		return nil
+	})
+	return ret, err
+}
+
+func (b *boltKV) Has(key []byte) (bool, error) {
+	var found bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(boltBucket).Get(key) != nil
+		return nil
+	})
+	return found, err
+}
+
+func (b *boltKV) Put(key []byte, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put(key, nonNil(value))
+	})
+}
+
+func (b *boltKV) Delete(key []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete(key)
+	})
+}
+
+func (b *boltKV) Write(batch *leveldb.Batch) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		replay := &boltReplay{
+			bucket: tx.Bucket(boltBucket),
+		}
+		if err := batch.Replay(replay); err != nil {
+			return err
+		}
+		return replay.err
+	})
+}
+
+// NewIterator reads matching entries a page at a time, so iterating over a
+// large database does not load it into memory.
+func (b *boltKV) NewIterator(prefix []byte) Iterator {
+	return &boltIterator{
+		db:     b.db,
+		prefix: prefix,
+		seek:   prefix,
+		idx:    -1,
+	}
+}
+
+func (b *boltKV) Close() error {
+	return b.db.Close()
+}
+
+// boltReplay applies a leveldb.Batch to a Bolt bucket, remembering the
+// first error since leveldb.BatchReplay cannot return one.
+type boltReplay struct {
+	bucket *bolt.Bucket
+	err    error
+}
+
+func (r *boltReplay) Put(key, value []byte) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.bucket.Put(copyBytes(key), nonNil(copyBytes(value)))
+}
+
+func (r *boltReplay) Delete(key []byte) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.bucket.Delete(key)
+}
+
+// boltIteratorPageSize is the number of entries a boltIterator reads per
+// read transaction.
+const boltIteratorPageSize = 256
+
+// boltIterator reads each page with a cursor in its own read transaction,
+// instead of keeping one open until Release. Callers read from and write to
+// the database while iterating, and Bolt deadlocks if a goroutine holding a
+// read transaction waits on another transaction while the file is being
+// remapped. As a result, writes made during iteration may be seen by later
+// pages.
+type boltIterator struct {
+	db     *bolt.DB
+	prefix []byte
+	// seek is the first key of the next page, and done is set once the
+	// last page has been read.
+	seek   []byte
+	done   bool
+	keys   [][]byte
+	values [][]byte
+	idx    int
+	err    error
+}
+
+func (b *boltIterator) Next() bool {
+	if b.err != nil {
+		return false
+	}
+	if b.idx < len(b.keys)-1 {
+		b.idx++
+		return true
+	}
+	if b.done {
+		b.idx = len(b.keys)
+		return false
+	}
+
+	b.readPage()
+	b.idx = 0
+	if b.err != nil || len(b.keys) == 0 {
+		b.idx = len(b.keys)
+		return false
+	}
+	return true
+}
+
+func (b *boltIterator) readPage() {
+	b.keys = b.keys[:0]
+	b.values = b.values[:0]
+	b.err = b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(boltBucket).Cursor()
+		for k, v := c.Seek(b.seek); k != nil && bytes.HasPrefix(k, b.prefix); k, v = c.Next() {
+			if len(b.keys) == boltIteratorPageSize {
+				b.seek = copyBytes(k)
+				return nil
+			}
+			// keys and values are only valid for the life of the transaction
+			b.keys = append(b.keys, copyBytes(k))
+			b.values = append(b.values, copyBytes(v))
+		}
+		b.done = true
+		return nil
+	})
+}
+
+func (b *boltIterator) Key() []byte {
+	if b.idx < 0 || b.idx >= len(b.keys) {
+		return nil
+	}
+	return b.keys[b.idx]
+}
+
+func (b *boltIterator) Value() []byte {
+	if b.idx < 0 || b.idx >= len(b.values) {
+		return nil
+	}
+	return b.values[b.idx]
+}
+
+func (b *boltIterator) Release() {
+	b.done = true
+	b.keys = nil
+	b.values = nil
+}
+
+func (b *boltIterator) Error() error {
+	return b.err
+}
+
+// nonNil converts nil values to empty ones, since Bolt treats a nil value
+// as a missing key.
+func nonNil(b []byte) []byte {
+	if b == nil {
+		return []byte{}
+	}
+	return b
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	levelutil "github.com/syndtr/goleveldb/leveldb/util"
+)
+
+type levelKV struct {
+	db *leveldb.DB
+}
+
+func NewLevelKV(db *leveldb.DB) KV {
+	return &levelKV{
+		db: db,
+	}
+}
+
+func OpenLevelKV(location string) (KV, error) {
+	db, err := leveldb.OpenFile(location, nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewLevelKV(db), nil
+}
+
+// NewMemoryKV returns a LevelDB instance backed by memory rather than
+// files. Everything is lost once it is closed.
+func NewMemoryKV() (KV, error) {
+	db, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewLevelKV(db), nil
+}
+
+func (l *levelKV) Get(key []byte) ([]byte, error) {
+	return l.db.Get(key, nil)
+}
+
+func (l *levelKV) Has(key []byte) (bool, error) {
+	return l.db.Has(key, nil)
+}
+
+func (l *levelKV) Put(key []byte, value []byte) error {
+	return l.db.Put(key, value, nil)
+}
+
+func (l *levelKV) Delete(key []byte) error {
+	return l.db.Delete(key, nil)
+}
+
+func (l *levelKV) Write(batch *leveldb.Batch) error {
+	return l.db.Write(batch, nil)
+}
+
+func (l *levelKV) NewIterator(prefix []byte) Iterator {
+	if prefix == nil {
+		return l.db.NewIterator(nil, nil)
+	}
+	return l.db.NewIterator(levelutil.BytesPrefix(prefix), nil)
+}
+
+func (l *levelKV) Close() error {
+	return l.db.Close()
+}
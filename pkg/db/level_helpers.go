@@ -34,6 +34,10 @@ func CreateLevelStorage(location string) (*leveldb.DB, Storage, error) {
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := Migrate(NewLevelKV(level)); err != nil {
+		level.Close()
+		return nil, nil, err
+	}
 	return level, NewLevelStorage(level), nil
 }
 
@@ -77,6 +81,7 @@ const (
 	spendPrefix   = "spend"
 	utxoPrefix    = "utxo"
 	depositPrefix = "deposit"
+	feePrefix     = "fee"
 )
 
 func txByHashKey(hash string) []byte {
@@ -87,8 +92,10 @@ func txByBlockNumTxIdxKey(blockNum uint64, txIdx uint32) []byte {
 	return joinKey(txPrefix, "blockNumTxIdx", util.Uint642Str(blockNum), util.Uint322Str(txIdx))
 }
 
+// txByBlockNumIterKey ends with a separator so that iterating over block 1
+// does not also return transactions in blocks 10, 11, etc.
 func txByBlockNumIterKey(blockNum uint64) []byte {
-	return joinKey(txPrefix, "blockNumTxIdx", util.Uint642Str(blockNum))
+	return joinKey(txPrefix, "blockNumTxIdx", util.Uint642Str(blockNum), "")
 }
 
 func utxoKey(addr common.Address, hash util.Hash, outIdx uint8) []byte {
@@ -129,6 +136,10 @@ func spendByTxIdxKey(blockNum uint64, txIdx uint32, outIdx uint8) []byte {
 	)
 }
 
+func feeKey(blockNum uint64) []byte {
+	return joinKey(feePrefix, util.Uint642Str(blockNum))
+}
+
 func exitKey(blockNum uint64, txIdx uint32, outIdx uint8) []byte {
 	return joinKey(
 		exitPrefix,
@@ -14,33 +14,29 @@ import (
 	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"errors"
-	levelutil "github.com/syndtr/goleveldb/leveldb/util"
+	"sort"
 )
 
+// LevelStorage implements Storage using the key layout in level_helpers.go,
+// on top of any KV backend.
 type LevelStorage struct {
-	db *leveldb.DB
+	db KV
 }
 
 func NewLevelStorage(db *leveldb.DB) Storage {
+	return NewKVStorage(NewLevelKV(db))
+}
+
+func NewKVStorage(kv KV) Storage {
 	result := &LevelStorage{
-		db: db,
+		db: kv,
 	}
 
 	return result
 }
 
 func (ps *LevelStorage) findPreviousTx(tx *chain.Transaction, inputIdx uint8) (*chain.ConfirmedTransaction, error) {
-	var input *chain.Input
-	if inputIdx != 0 && inputIdx != 1 {
-		panic("inputIdx must be 0 or 1")
-	}
-
-	if inputIdx == 0 {
-		input = tx.Body.Input0
-	} else {
-		input = tx.Body.Input1
-	}
-
+	input := tx.Body.InputAt(inputIdx)
 	return ps.findTransactionByBlockNumTxIdx(input.BlockNumber, input.TransactionIndex)
 }
 
@@ -75,38 +71,32 @@ func (ps *LevelStorage) batchWriteConfirmedTransaction(confirmed *chain.Confirme
 
 	// Recording spends
 	if tx.Body.IsDeposit() {
-		batch.Put(depositKey(tx.Body.Input0.DepositNonce), []byte(hexHash))
+		batch.Put(depositKey(tx.Body.InputAt(0).DepositNonce), []byte(hexHash))
 	} else {
-		prevConfirmed0, err := ps.findPreviousTx(tx, 0)
-		if err != nil {
-			return err
+		for i := 0; i < tx.Body.InputCount(); i++ {
+			input := tx.Body.InputAt(uint8(i))
+			if i > 0 && input.IsZero() {
+				continue
+			}
+
+			prevConfirmed, err := ps.findPreviousTx(tx, uint8(i))
+			if err != nil {
+				return err
+			}
+
+			prevTx := prevConfirmed.Transaction
+			batch.Put(spendByTxIdxKey(prevTx.Body.BlockNumber, prevTx.Body.TransactionIndex, input.OutputIndex), []byte(hexHash))
+			batch.Delete(utxoKey(prevTx.Body.OutputAt(input.OutputIndex).Owner, prevConfirmed.Hash(), input.OutputIndex))
+			// TODO mark as exited
 		}
-
-		prevTx := prevConfirmed0.Transaction
-		batch.Put(spendByTxIdxKey(prevTx.Body.BlockNumber, prevTx.Body.TransactionIndex, tx.Body.Input0.OutputIndex), []byte(hexHash))
-		batch.Delete(utxoKey(prevTx.Body.OutputAt(tx.Body.Input0.OutputIndex).Owner, prevConfirmed0.Hash(), tx.Body.Input0.OutputIndex))
-		// TODO mark as exited
-	}
-
-	if !tx.Body.Input1.IsZero() {
-		prevConfirmed1, err := ps.findPreviousTx(tx, 1)
-		if err != nil {
-			return err
-		}
-
-		prevTx := prevConfirmed1.Transaction
-		batch.Put(spendByTxIdxKey(prevTx.Body.BlockNumber, prevTx.Body.TransactionIndex, tx.Body.Input1.OutputIndex), []byte(hexHash))
-		batch.Delete(utxoKey(prevTx.Body.OutputAt(tx.Body.Input0.OutputIndex).Owner, prevConfirmed1.Hash(), tx.Body.Input1.OutputIndex))
 	}
 
 	// Recording earns
-	if !tx.Body.Output0.IsZeroOutput() {
-		output := tx.Body.OutputAt(0)
-		batch.Put(utxoKey(output.Owner, hash, 0), empty)
-	}
-	if !tx.Body.Output1.IsZeroOutput() {
-		output := tx.Body.OutputAt(1)
-		batch.Put(utxoKey(output.Owner, hash, 1), empty)
+	for i, output := range []*chain.Output{tx.Body.Output0, tx.Body.Output1} {
+		if output.IsZeroOutput() {
+			continue
+		}
+		batch.Put(utxoKey(output.Owner, hash, uint8(i)), empty)
 	}
 
 	return nil
@@ -132,37 +122,92 @@ func (ps *LevelStorage) MarkTransactionAsExited(plasmaBlockNum uint64, plasmaTxI
 	batch := new(leveldb.Batch)
 	batch.Delete(utxoKey(tx.Transaction.Body.OutputAt(outIdx).Owner, tx.Hash(), outIdx))
 	batch.Put(exitKey(tx.Transaction.Body.BlockNumber, tx.Transaction.Body.TransactionIndex, outIdx), locBytes)
-	return ps.db.Write(batch, nil)
+	return ps.db.Write(batch)
+}
+
+func (ps *LevelStorage) Exits() ([]ExitLocator, error) {
+	iter := ps.db.NewIterator(joinKey(exitPrefix, ""))
+	defer iter.Release()
+
+	var ret []ExitLocator
+	for iter.Next() {
+		var loc ExitLocator
+		if err := loc.UnmarshalBinary(iter.Value()); err != nil {
+			return nil, err
+		}
+		ret = append(ret, loc)
+	}
+
+	return ret, iter.Error()
+}
+
+// FeeLedger returns an entry for every block that collected fees, ordered
+// by block number.
+func (ps *LevelStorage) FeeLedger() ([]FeeEntry, error) {
+	iter := ps.db.NewIterator(joinKey(feePrefix, ""))
+	defer iter.Release()
+
+	var ret []FeeEntry
+	for iter.Next() {
+		var entry FeeEntry
+		if err := entry.UnmarshalBinary(iter.Value()); err != nil {
+			return nil, err
+		}
+		ret = append(ret, entry)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].BlockNumber < ret[j].BlockNumber
+	})
+	return ret, nil
+}
+
+func (ps *LevelStorage) MarkFeesExited(blockNum uint64, ethTransactionHash common.Hash) error {
+	data, err := ps.db.Get(feeKey(blockNum))
+	if err != nil {
+		return err
+	}
+	var entry FeeEntry
+	if err := entry.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	entry.EthereumTransactionHash = ethTransactionHash[:]
+	enc, err := entry.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ps.db.Put(feeKey(blockNum), enc)
 }
 
 func (ps *LevelStorage) IsDoubleSpent(tx *chain.Transaction) (bool, error) {
 	body := tx.Body
 
 	if tx.Body.IsDeposit() {
-		return ps.db.Has(depositKey(tx.Body.Input0.DepositNonce), nil)
+		return ps.db.Has(depositKey(tx.Body.InputAt(0).DepositNonce))
 	}
 
-	prevTx0, err := ps.findPreviousTx(tx, 0)
-	if err != nil {
-		return false, err
-	}
 	searchKeys := make([][]byte, 0)
+	for i := 0; i < body.InputCount(); i++ {
+		input := body.InputAt(uint8(i))
+		if i > 0 && input.IsZero() {
+			continue
+		}
 
-	prevTx0Body := prevTx0.Transaction.Body
-	searchKeys = append(searchKeys, spendByTxIdxKey(prevTx0Body.BlockNumber, prevTx0Body.TransactionIndex, body.Input0.OutputIndex))
-	searchKeys = append(searchKeys, exitKey(prevTx0Body.BlockNumber, prevTx0Body.TransactionIndex, body.Input0.OutputIndex))
-	if !body.Input1.IsZero() {
-		prevTx1, err := ps.findPreviousTx(tx, 1)
+		prevTx, err := ps.findPreviousTx(tx, uint8(i))
 		if err != nil {
 			return false, err
 		}
-		prevTx1Body := prevTx1.Transaction.Body
-		searchKeys = append(searchKeys, spendByTxIdxKey(prevTx1Body.BlockNumber, prevTx1Body.TransactionIndex, body.Input1.OutputIndex))
-		searchKeys = append(searchKeys, exitKey(prevTx1Body.BlockNumber, prevTx1Body.TransactionIndex, body.Input1.OutputIndex))
+		prevTxBody := prevTx.Transaction.Body
+		searchKeys = append(searchKeys, spendByTxIdxKey(prevTxBody.BlockNumber, prevTxBody.TransactionIndex, input.OutputIndex))
+		searchKeys = append(searchKeys, exitKey(prevTxBody.BlockNumber, prevTxBody.TransactionIndex, input.OutputIndex))
 	}
 
 	for _, spendKey := range searchKeys {
-		found, _ := ps.db.Has(spendKey, nil)
+		found, _ := ps.db.Has(spendKey)
 		if found {
 			return true, nil
 		}
@@ -172,7 +217,7 @@ func (ps *LevelStorage) IsDoubleSpent(tx *chain.Transaction) (bool, error) {
 }
 
 func (ps *LevelStorage) FindDoubleSpendingTransaction(blkNum uint64, txIdx uint32, outIndex uint8) (*chain.ConfirmedTransaction, error) {
-	spendingHash, err := ps.db.Get(spendByTxIdxKey(blkNum, txIdx, outIndex), nil)
+	spendingHash, err := ps.db.Get(spendByTxIdxKey(blkNum, txIdx, outIndex))
 	if err == leveldb.ErrNotFound {
 		return nil, nil
 	}
@@ -188,7 +233,7 @@ func (ps *LevelStorage) FindDoubleSpendingTransaction(blkNum uint64, txIdx uint3
 }
 
 func (ps *LevelStorage) FindDoubleSpendingDeposit(nonce *big.Int) (*chain.ConfirmedTransaction, error) {
-	spendingHash, err := ps.db.Get(depositKey(nonce), nil)
+	spendingHash, err := ps.db.Get(depositKey(nonce))
 	if err == leveldb.ErrNotFound {
 		return nil, nil
 	}
@@ -223,8 +268,8 @@ func (ps *LevelStorage) doPackageBlock(txs []chain.Transaction) (*chain.BlockRes
 	numberOfTransactions := len(txs)
 
 	hashables := make([]util.RLPHashable, numberOfTransactions)
-	for i, tx := range txs {
-		hashables[i] = &tx
+	for i := range txs {
+		hashables[i] = &txs[i]
 	}
 	merkleRoot := merkle.Root(hashables)
 
@@ -259,7 +304,7 @@ func (ps *LevelStorage) doPackageBlock(txs []chain.Transaction) (*chain.BlockRes
 		return nil, err
 	}
 
-	err = ps.db.Write(batch, nil)
+	err = ps.db.Write(batch)
 	if err != nil {
 		return nil, err
 	}
@@ -297,6 +342,23 @@ func (ps *LevelStorage) batchWriteBlockMeta(block *chain.Block, meta *chain.Bloc
 		return err
 	}
 	batch.Put(blockMetaPrefixKey(block.Header.Number), metaEnc)
+	return batchWriteFeeEntry(block.Header.Number, meta.Fees, batch)
+}
+
+func batchWriteFeeEntry(blockNum uint64, fees *big.Int, batch *leveldb.Batch) error {
+	if fees == nil || fees.Sign() == 0 {
+		return nil
+	}
+
+	entry := &FeeEntry{
+		BlockNumber: blockNum,
+		Amount:      fees,
+	}
+	enc, err := entry.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	batch.Put(feeKey(blockNum), enc)
 	return nil
 }
 
@@ -327,7 +389,7 @@ func (ps *LevelStorage) InsertBlock(block *chain.Block, meta *chain.BlockMetadat
 		}
 	}
 
-	return ps.db.Write(batch, nil)
+	return ps.db.Write(batch)
 }
 
 func (ps *LevelStorage) ProcessDeposit(confirmed chain.Transaction) (deposit *chain.BlockResult, err error) {
@@ -339,7 +401,7 @@ func (ps *LevelStorage) ProcessDeposit(confirmed chain.Transaction) (deposit *ch
 }
 
 func (ps *LevelStorage) FindTransactionsByBlockNum(blockNum uint64) ([]chain.ConfirmedTransaction, error) {
-	txIter := ps.db.NewIterator(levelutil.BytesPrefix(txByBlockNumIterKey(blockNum)), nil)
+	txIter := ps.db.NewIterator(txByBlockNumIterKey(blockNum))
 	defer txIter.Release()
 
 	var ret []chain.ConfirmedTransaction
@@ -351,17 +413,19 @@ func (ps *LevelStorage) FindTransactionsByBlockNum(blockNum uint64) ([]chain.Con
 		}
 		tx, err := ps.findTransactionByHash(txHash)
 		if err != nil {
-			return nil, err
+			return nil, ps.prunedOr(blockNum, err)
 		}
 		ret = append(ret, *tx)
 	}
 
+	// keys are ordered lexically, so transaction 10 sorts before 2
+	sortTransactions(ret)
 	return ret, nil
 }
 
 func (ps *LevelStorage) findTransactionByDepositNonce(nonce *big.Int) (*chain.Transaction, util.Hash, error) {
 	keyPrefix := depositKey(nonce)
-	iter := ps.db.NewIterator(levelutil.BytesPrefix(keyPrefix), nil)
+	iter := ps.db.NewIterator(keyPrefix)
 	defer iter.Release()
 
 	for iter.Next() {
@@ -391,7 +455,7 @@ func (ps *LevelStorage) findTransactionByDepositNonce(nonce *big.Int) (*chain.Tr
 }
 
 func (ps *LevelStorage) findTransactionByBlockNumTxIdx(blockNum uint64, txIdx uint32) (*chain.ConfirmedTransaction, error) {
-	hash, err := ps.db.Get(txByBlockNumTxIdxKey(blockNum, txIdx), nil)
+	hash, err := ps.db.Get(txByBlockNumTxIdxKey(blockNum, txIdx))
 	if err != nil {
 		return nil, err
 	}
@@ -399,11 +463,15 @@ func (ps *LevelStorage) findTransactionByBlockNumTxIdx(blockNum uint64, txIdx ui
 	if err != nil {
 		return nil, err
 	}
-	return ps.findTransactionByHash(hashBytes)
+	tx, err := ps.findTransactionByHash(hashBytes)
+	if err != nil {
+		return nil, ps.prunedOr(blockNum, err)
+	}
+	return tx, nil
 }
 
 func (ps *LevelStorage) findTransactionByHash(hash util.Hash) (*chain.ConfirmedTransaction, error) {
-	txBytes, err := ps.db.Get(txByHashKey(hexutil.Encode(hash)), nil)
+	txBytes, err := ps.db.Get(txByHashKey(hexutil.Encode(hash)))
 	if err != nil {
 		return nil, err
 	}
@@ -431,7 +499,7 @@ func (ps *LevelStorage) Balance(addr common.Address) (*big.Int, error) {
 }
 
 func (ps *LevelStorage) SpendableTxs(addr common.Address) ([]chain.ConfirmedTransaction, error) {
-	utxoIter := ps.db.NewIterator(levelutil.BytesPrefix(utxoAddrIterKey(addr)), nil)
+	utxoIter := ps.db.NewIterator(utxoAddrIterKey(addr))
 	defer utxoIter.Release()
 
 	seenTxs := make(map[string]bool)
@@ -455,7 +523,7 @@ func (ps *LevelStorage) SpendableTxs(addr common.Address) ([]chain.ConfirmedTran
 }
 
 func (ps *LevelStorage) UTXOs(addr common.Address) ([]chain.ConfirmedTransaction, error) {
-	utxoIter := ps.db.NewIterator(levelutil.BytesPrefix(utxoAddrIterKey(addr)), nil)
+	utxoIter := ps.db.NewIterator(utxoAddrIterKey(addr))
 	defer utxoIter.Release()
 
 	seenTxs := make(map[string]bool)
@@ -490,7 +558,7 @@ func (ps *LevelStorage) ConfirmTransaction(blockNumber uint64, transactionIndex
 	if err != nil {
 		return nil, err
 	}
-	ps.db.Put(txByHashKey(hexHash), txBytes, nil)
+	ps.db.Put(txByHashKey(hexHash), txBytes)
 	return tx, nil
 }
 
@@ -506,11 +574,11 @@ func (ps *LevelStorage) ConfirmSigsFor(blockNumber uint64, transactionIndex uint
 
 // Block
 func (ps *LevelStorage) BlockAtHeight(num uint64) (*chain.Block, error) {
-	key, err := ps.db.Get(blockNumKey(num), nil)
+	key, err := ps.db.Get(blockNumKey(num))
 	if err != nil {
 		return nil, err
 	}
-	data, err := ps.db.Get(key, nil)
+	data, err := ps.db.Get(key)
 	if err != nil {
 		return nil, err
 	}
@@ -526,7 +594,7 @@ func (ps *LevelStorage) BlockAtHeight(num uint64) (*chain.Block, error) {
 }
 
 func (ps *LevelStorage) BlockMetaAtHeight(num uint64) (*chain.BlockMetadata, error) {
-	data, err := ps.db.Get(blockMetaPrefixKey(num), nil)
+	data, err := ps.db.Get(blockMetaPrefixKey(num))
 	if err != nil {
 		return nil, err
 	}
@@ -560,7 +628,7 @@ func (ps *LevelStorage) FullBlockAtHeight(num uint64) (*chain.Block, *chain.Bloc
 func (ps *LevelStorage) LatestBlock() (*chain.Block, error) {
 	key := blockPrefixKey(latestKey)
 
-	exists, err := ps.db.Has(key, nil)
+	exists, err := ps.db.Has(key)
 
 	if err != nil {
 		return nil, err
@@ -570,11 +638,11 @@ func (ps *LevelStorage) LatestBlock() (*chain.Block, error) {
 		return nil, nil
 	}
 
-	topKey, err := ps.db.Get(key, nil)
+	topKey, err := ps.db.Get(key)
 	if err != nil {
 		return nil, err
 	}
-	data, err := ps.db.Get(topKey, nil)
+	data, err := ps.db.Get(topKey)
 	if err != nil {
 		return nil, err
 	}
@@ -639,12 +707,12 @@ func (ps *LevelStorage) LastSubmittedBlock() (uint64, error) {
 func (ps *LevelStorage) saveEventIdx(eventKey string, idx uint64) error {
 	key := prefixKey(eventKey)
 	b := uint64ToBytes(idx)
-	return ps.db.Put(key, b, nil)
+	return ps.db.Put(key, b)
 }
 
 func (ps *LevelStorage) getMostRecentEventIdx(eventKey string) (uint64, error) {
 	key := prefixKey(eventKey)
-	b, err := ps.db.Get(key, nil)
+	b, err := ps.db.Get(key)
 	if err == leveldb.ErrNotFound {
 		return 0, nil
 	}
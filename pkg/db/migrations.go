@@ -0,0 +1,188 @@
+package db
+
+import (
+	"fmt"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/syndtr/goleveldb/leveldb"
+	"strings"
+)
+
+const schemaVersionKey = "SCHEMA_VERSION"
+
+var migrationLogger = log.ForSubsystem("Migrations")
+
+// Migration upgrades a database from Version-1 to Version. The schema
+// version is only recorded after Migrate returns, so migrations must be
+// safe to run again if the node stops halfway through.
+type Migration struct {
+	Version     uint64
+	Description string
+	Migrate     func(kv KV) error
+}
+
+// migrations must be ordered by version, starting at 1. Databases created
+// before schema versioning was introduced are at version 0.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "rebuild UTXO, spend and deposit indexes",
+		Migrate:     migrateRebuildIndexes,
+	},
+	{
+		Version:     2,
+		Description: "backfill fee ledger from block metadata",
+		Migrate:     migrateBackfillFeeLedger,
+	},
+}
+
+// CurrentSchemaVersion is the schema version written by this binary.
+func CurrentSchemaVersion() uint64 {
+	return migrations[len(migrations)-1].Version
+}
+
+type ErrSchemaTooNew struct {
+	Version   uint64
+	Supported uint64
+}
+
+func NewErrSchemaTooNew(version uint64, supported uint64) error {
+	return &ErrSchemaTooNew{
+		Version:   version,
+		Supported: supported,
+	}
+}
+
+func (e *ErrSchemaTooNew) Error() string {
+	return fmt.Sprintf("database schema version %d is newer than the latest supported version %d", e.Version, e.Supported)
+}
+
+// SchemaVersion returns the schema version recorded in the database, or
+// zero if none is recorded.
+func SchemaVersion(kv KV) (uint64, error) {
+	b, err := kv.Get(prefixKey(schemaVersionKey))
+	if err == ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return bytesToUint64(b), nil
+}
+
+func saveSchemaVersion(kv KV, version uint64) error {
+	return kv.Put(prefixKey(schemaVersionKey), uint64ToBytes(version))
+}
+
+// Migrate brings the database up to the current schema version. Empty
+// databases are stamped with the current version without running any
+// migrations, and databases written by a newer binary or left behind by an
+// interrupted snapshot import are refused.
+func Migrate(kv KV) error {
+	if err := checkSnapshotImport(kv); err != nil {
+		return err
+	}
+	return runMigrations(kv, migrations)
+}
+
+func runMigrations(kv KV, migrations []Migration) error {
+	latest := migrations[len(migrations)-1].Version
+	version, err := SchemaVersion(kv)
+	if err != nil {
+		return err
+	}
+	if version > latest {
+		return NewErrSchemaTooNew(version, latest)
+	}
+	if version == latest {
+		return nil
+	}
+
+	empty, err := isEmpty(kv)
+	if err != nil {
+		return err
+	}
+	if empty {
+		return saveSchemaVersion(kv, latest)
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		lgr := migrationLogger.WithFields(logrus.Fields{
+			"version":     migration.Version,
+			"description": migration.Description,
+		})
+		lgr.Info("running migration")
+		if err := migration.Migrate(kv); err != nil {
+			return errors.Wrapf(err, "failed to migrate database to version %d", migration.Version)
+		}
+		if err := saveSchemaVersion(kv, migration.Version); err != nil {
+			return err
+		}
+		lgr.Info("finished migration")
+	}
+
+	return nil
+}
+
+func isEmpty(kv KV) (bool, error) {
+	iter := kv.NewIterator(nil)
+	defer iter.Release()
+	empty := !iter.Next()
+	return empty, iter.Error()
+}
+
+// migrateRebuildIndexes repairs the UTXO index of databases written before
+// input 1's owner was looked up correctly. Unlike RebuildIndexes, it runs
+// even if block data is inconsistent: the node has been running on that
+// data all along, and db check will still report it.
+func migrateRebuildIndexes(kv KV) error {
+	checker, err := newIntegrityChecker(kv)
+	if err != nil {
+		return err
+	}
+	return checker.writeIndexes()
+}
+
+// migrateBackfillFeeLedger adds ledger entries for blocks packaged before
+// the fee ledger existed. Entries that are already present are left alone,
+// so fee exits recorded by a previous run are kept.
+func migrateBackfillFeeLedger(kv KV) error {
+	iter := kv.NewIterator(joinKey(blockMetaKeyPrefix, ""))
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		parts := strings.Split(string(iter.Key()), keyPartsSeparator)
+		blockNum, ok := util.Str2Uint64(parts[len(parts)-1])
+		if !ok {
+			return fmt.Errorf("invalid block metadata key %s", iter.Key())
+		}
+		var meta chain.BlockMetadata
+		if err := meta.FromRLP(iter.Value()); err != nil {
+			return err
+		}
+
+		exists, err := kv.Has(feeKey(blockNum))
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := batchWriteFeeEntry(blockNum, meta.Fees, batch); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return kv.Write(batch)
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyFixture returns a database as written before schema versioning,
+// including the stale UTXO left behind by the old input 1 bookkeeping.
+func legacyFixture(t *testing.T) KV {
+	kv, s := newMemoryLevelStorage(t)
+	alice := chain.RandomAddress()
+	populateChain(t, s, alice, chain.RandomAddress())
+
+	tx, err := s.FindTransactionByBlockNumTxIdx(2, 0)
+	require.NoError(t, err)
+	require.NoError(t, kv.Put(utxoKey(alice, tx.Hash(), 1), []byte{}))
+	require.NoError(t, kv.Delete(prefixKey(schemaVersionKey)))
+	return kv
+}
+
+func TestMigrate_EmptyDatabase(t *testing.T) {
+	kv, _ := newMemoryLevelStorage(t)
+	defer kv.Close()
+
+	require.NoError(t, Migrate(kv))
+	version, err := SchemaVersion(kv)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion(), version)
+}
+
+func TestMigrate_LegacyFixture(t *testing.T) {
+	kv := legacyFixture(t)
+	defer kv.Close()
+
+	report, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Len(t, report.Issues, 1)
+	require.Equal(t, IssueUTXOIndex, report.Issues[0].Kind)
+
+	require.NoError(t, Migrate(kv))
+	version, err := SchemaVersion(kv)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion(), version)
+
+	report, err = CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+
+	// running again is a no-op
+	require.NoError(t, Migrate(kv))
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	kv, _ := newMemoryLevelStorage(t)
+	defer kv.Close()
+	require.NoError(t, saveSchemaVersion(kv, CurrentSchemaVersion()+1))
+
+	err := Migrate(kv)
+	require.Error(t, err)
+	require.IsType(t, &ErrSchemaTooNew{}, err)
+}
+
+func TestRunMigrations_StopsAtFailure(t *testing.T) {
+	kv := legacyFixture(t)
+	defer kv.Close()
+
+	var ran []uint64
+	testMigrations := []Migration{
+		{
+			Version: 1,
+			Migrate: func(kv KV) error {
+				ran = append(ran, 1)
+				return nil
+			},
+		},
+		{
+			Version: 2,
+			Migrate: func(kv KV) error {
+				ran = append(ran, 2)
+				return errors.New("boom")
+			},
+		},
+		{
+			Version: 3,
+			Migrate: func(kv KV) error {
+				ran = append(ran, 3)
+				return nil
+			},
+		},
+	}
+
+	require.Error(t, runMigrations(kv, testMigrations))
+	require.Equal(t, []uint64{1, 2}, ran)
+	version, err := SchemaVersion(kv)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), version)
+
+	// resumes from the last successful migration
+	ran = nil
+	testMigrations[1].Migrate = func(kv KV) error {
+		ran = append(ran, 2)
+		return nil
+	}
+	require.NoError(t, runMigrations(kv, testMigrations))
+	require.Equal(t, []uint64{2, 3}, ran)
+}
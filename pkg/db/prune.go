@@ -0,0 +1,339 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/util"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const prunedHeightKey = "PRUNED_HEIGHT"
+const rootNodeKey = "ROOT_NODE"
+
+type ErrPruned struct {
+	BlockNumber uint64
+}
+
+func NewErrPruned(blockNumber uint64) error {
+	return &ErrPruned{
+		BlockNumber: blockNumber,
+	}
+}
+
+func (e *ErrPruned) Error() string {
+	return fmt.Sprintf("transaction in block %d has been pruned", e.BlockNumber)
+}
+
+type PruneReport struct {
+	Height       uint64 `json:"height"`
+	PrunedHeight uint64 `json:"prunedHeight"`
+	Pruned       uint64 `json:"pruned"`
+}
+
+// ErrPruneRootNode is returned when pruning the database of a root node,
+// which must keep every transaction to serve validators and snapshots.
+var ErrPruneRootNode = errors.New("the root node's database cannot be pruned")
+
+// MarkRootNode records that kv belongs to a root node, so that it is never
+// pruned.
+func MarkRootNode(kv KV) error {
+	return kv.Put(prefixKey(rootNodeKey), []byte{1})
+}
+
+// IsRootNode returns true if kv has been marked by MarkRootNode.
+func IsRootNode(kv KV) (bool, error) {
+	return kv.Has(prefixKey(rootNodeKey))
+}
+
+// Prune deletes the bodies of transactions at least depth blocks old whose
+// outputs have all either exited or been spent by a transaction that is
+// itself at least depth blocks old. Block headers, metadata, transaction
+// hashes and the spend, deposit and exit indexes are kept, so Merkle proofs
+// can still be generated for the remaining transactions and double spends
+// of pruned outputs are still detected.
+//
+// An output whose spend is younger than depth may still be exited and need
+// challenging, so depth must cover the exit challenge period.
+//
+// Each run picks up from the pruned height left by the last one. Older
+// transactions only become prunable when one of their outputs is spent or
+// exits, so they are revisited through the inputs of the newly considered
+// blocks and through the exit index rather than by rescanning every block.
+func Prune(kv KV, depth uint64) (*PruneReport, error) {
+	isRoot, err := IsRootNode(kv)
+	if err != nil {
+		return nil, err
+	}
+	if isRoot {
+		return nil, ErrPruneRootNode
+	}
+
+	ps := &LevelStorage{db: kv}
+	report := &PruneReport{}
+
+	latest, err := ps.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+	prunedHeight, err := ps.PrunedHeight()
+	if err != nil {
+		return nil, err
+	}
+	report.PrunedHeight = prunedHeight
+	if latest == nil {
+		return report, nil
+	}
+	report.Height = latest.Header.Number
+	if latest.Header.Number <= depth {
+		return report, nil
+	}
+
+	cutoff := latest.Header.Number - depth
+	if err := ps.pruneExited(prunedHeight, cutoff, report); err != nil {
+		return nil, err
+	}
+
+	for num := prunedHeight + 1; num <= cutoff; num++ {
+		hashes, err := ps.TransactionHashesByBlockNum(num)
+		if err != nil {
+			return nil, err
+		}
+		candidates := newPruneCandidates()
+		for _, hash := range hashes {
+			candidates.add(hash)
+			if err := ps.addSpentCandidates(hash, candidates); err != nil {
+				return nil, err
+			}
+		}
+
+		batch := new(leveldb.Batch)
+		if err := ps.batchPruneCandidates(candidates, cutoff, batch, report); err != nil {
+			return nil, err
+		}
+		batch.Put(prefixKey(prunedHeightKey), uint64ToBytes(num))
+		if err := ps.db.Write(batch); err != nil {
+			return nil, err
+		}
+		report.PrunedHeight = num
+	}
+
+	return report, nil
+}
+
+// pruneExited considers the transactions with exited outputs in blocks
+// that earlier runs have already passed.
+func (ps *LevelStorage) pruneExited(prunedHeight uint64, cutoff uint64, report *PruneReport) error {
+	exits, err := ps.Exits()
+	if err != nil {
+		return err
+	}
+
+	candidates := newPruneCandidates()
+	for _, exit := range exits {
+		if exit.PlasmaBlockNumber > prunedHeight {
+			continue
+		}
+		hash, err := ps.db.Get(txByBlockNumTxIdxKey(exit.PlasmaBlockNumber, exit.PlasmaTransactionIndex))
+		if err != nil {
+			return err
+		}
+		decoded, err := hexutil.Decode(string(hash))
+		if err != nil {
+			return err
+		}
+		candidates.add(decoded)
+	}
+	if len(candidates.hashes) == 0 {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	if err := ps.batchPruneCandidates(candidates, cutoff, batch, report); err != nil {
+		return err
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return ps.db.Write(batch)
+}
+
+// addSpentCandidates adds the transactions whose outputs the transaction
+// with the given hash spends.
+func (ps *LevelStorage) addSpentCandidates(hash util.Hash, candidates *pruneCandidates) error {
+	tx, err := ps.findTransactionByHash(hash)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tx.Transaction.Body.IsDeposit() {
+		return nil
+	}
+
+	for _, input := range []*chain.Input{tx.Transaction.Body.Input0, tx.Transaction.Body.Input1} {
+		if input.IsZero() || input.IsDeposit() {
+			continue
+		}
+		spent, err := ps.db.Get(txByBlockNumTxIdxKey(input.BlockNumber, input.TransactionIndex))
+		if err != nil {
+			return err
+		}
+		decoded, err := hexutil.Decode(string(spent))
+		if err != nil {
+			return err
+		}
+		candidates.add(decoded)
+	}
+	return nil
+}
+
+func (ps *LevelStorage) batchPruneCandidates(candidates *pruneCandidates, cutoff uint64, batch *leveldb.Batch, report *PruneReport) error {
+	for _, hash := range candidates.hashes {
+		pruned, err := ps.batchPruneTransaction(hash, cutoff, batch)
+		if err != nil {
+			return err
+		}
+		if pruned {
+			report.Pruned++
+		}
+	}
+	return nil
+}
+
+// pruneCandidates are the transactions one batch considers, each once.
+type pruneCandidates struct {
+	hashes []util.Hash
+	seen   map[string]bool
+}
+
+func newPruneCandidates() *pruneCandidates {
+	return &pruneCandidates{
+		seen: make(map[string]bool),
+	}
+}
+
+func (c *pruneCandidates) add(hash util.Hash) {
+	key := string(hash)
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+	c.hashes = append(c.hashes, hash)
+}
+
+func (ps *LevelStorage) batchPruneTransaction(hash util.Hash, cutoff uint64, batch *leveldb.Batch) (bool, error) {
+	tx, err := ps.findTransactionByHash(hash)
+	if err == ErrNotFound {
+		// already pruned
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	body := tx.Transaction.Body
+	for i, output := range []*chain.Output{body.Output0, body.Output1} {
+		if output.IsZeroOutput() {
+			continue
+		}
+		settled, err := ps.isOutputSettled(body.BlockNumber, body.TransactionIndex, uint8(i), cutoff)
+		if err != nil {
+			return false, err
+		}
+		if !settled {
+			return false, nil
+		}
+	}
+
+	batch.Delete(txByHashKey(hexutil.Encode(hash)))
+	return true, nil
+}
+
+// isOutputSettled returns true if the output has exited, or has been spent
+// by a transaction in a block no later than cutoff.
+func (ps *LevelStorage) isOutputSettled(blockNum uint64, txIdx uint32, outIdx uint8, cutoff uint64) (bool, error) {
+	exited, err := ps.db.Has(exitKey(blockNum, txIdx, outIdx))
+	if err != nil {
+		return false, err
+	}
+	if exited {
+		return true, nil
+	}
+
+	spendingHash, err := ps.db.Get(spendByTxIdxKey(blockNum, txIdx, outIdx))
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	hash, err := hexutil.Decode(string(spendingHash))
+	if err != nil {
+		return false, err
+	}
+	spending, err := ps.findTransactionByHash(hash)
+	if err == ErrNotFound {
+		// the spending transaction can only have been pruned if it was
+		// older than a previous, lower cutoff
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return spending.Transaction.Body.BlockNumber <= cutoff, nil
+}
+
+// PrunedHeight returns the highest block that has been considered for
+// pruning, or zero if the database has never been pruned.
+func (ps *LevelStorage) PrunedHeight() (uint64, error) {
+	return ps.getMostRecentEventIdx(prunedHeightKey)
+}
+
+// TransactionHashesByBlockNum returns the hashes of every transaction in a
+// block in order, including pruned ones. These are the leaves of the
+// block's Merkle tree.
+func (ps *LevelStorage) TransactionHashesByBlockNum(blockNum uint64) ([]util.Hash, error) {
+	meta, err := ps.BlockMetaAtHeight(blockNum)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]util.Hash, meta.TransactionCount)
+	for i := uint32(0); i < meta.TransactionCount; i++ {
+		hash, err := ps.db.Get(txByBlockNumTxIdxKey(blockNum, i))
+		if err != nil {
+			return nil, err
+		}
+		ret[i], err = hexutil.Decode(string(hash))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// prunedOr returns ErrPruned if a transaction in blockNum is missing
+// because it has been pruned, and err otherwise.
+func (ps *LevelStorage) prunedOr(blockNum uint64, err error) error {
+	if err != ErrNotFound {
+		return err
+	}
+	prunedHeight, pErr := ps.PrunedHeight()
+	if pErr != nil {
+		return pErr
+	}
+	if blockNum <= prunedHeight {
+		return NewErrPruned(blockNum)
+	}
+	return err
+}
+
+// prunedLeaf stands in for a pruned transaction when computing Merkle roots.
+type prunedLeaf util.Hash
+
+func (p prunedLeaf) RLPHash(hasher util.Hasher) util.Hash {
+	return util.Hash(p)
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/merkle"
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune_KeepsUnspentAndRecentlySpent(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	populateChain(t, s, alice, bob)
+
+	report, err := Prune(kv, 1)
+	require.NoError(t, err)
+	// only alice's deposit is spent at or before block 3
+	require.Equal(t, uint64(1), report.Pruned)
+	require.Equal(t, uint64(3), report.PrunedHeight)
+
+	_, err = s.FindTransactionByBlockNumTxIdx(1, 0)
+	require.IsType(t, &ErrPruned{}, err)
+	_, err = s.FindTransactionsByBlockNum(1)
+	require.IsType(t, &ErrPruned{}, err)
+	_, err = s.FindTransactionByBlockNumTxIdx(3, 0)
+	require.NoError(t, err)
+
+	block, err := s.BlockAtHeight(1)
+	require.NoError(t, err)
+	hashes, err := s.TransactionHashesByBlockNum(1)
+	require.NoError(t, err)
+	root, _ := merkle.RootAndProof(hashes, -1)
+	require.True(t, bytes.Equal(block.Header.MerkleRoot, root))
+
+	bobBal, err := s.Balance(bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(110), bobBal.Int64())
+
+	again, err := Prune(kv, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), again.Pruned)
+
+	integrity, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, integrity.Issues)
+	require.Equal(t, uint64(1), integrity.PrunedCount)
+}
+
+func TestPrune_ExitedAndSpentOutputs(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	populateChain(t, s, alice, bob)
+
+	// block 5: bob sends block 4's output to alice, leaving block 2's
+	// output 1 spent by a transaction that will be pruned
+	_, err := s.PackageBlock([]chain.Transaction{
+		spendTx(
+			[]*chain.Input{chain.NewInput(4, 0, 0, chain.Zero())},
+			[]*chain.Output{chain.NewOutput(alice, big.NewInt(50))},
+		),
+	})
+	require.NoError(t, err)
+
+	report, err := Prune(kv, 0)
+	require.NoError(t, err)
+	// both deposits and block 4
+	require.Equal(t, uint64(3), report.Pruned)
+	_, err = s.FindTransactionByBlockNumTxIdx(2, 0)
+	require.NoError(t, err)
+
+	integrity, err := RebuildIndexes(kv)
+	require.NoError(t, err)
+	require.Empty(t, integrity.Issues)
+
+	require.NoError(t, s.MarkTransactionAsExited(2, 0, 0, 10, [32]byte{}))
+	report, err = Prune(kv, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), report.Pruned)
+
+	bobBal, err := s.Balance(bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), bobBal.Int64())
+	aliceBal, err := s.Balance(alice)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), aliceBal.Int64())
+
+	integrity, err = CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, integrity.Issues)
+	require.Equal(t, uint64(4), integrity.PrunedCount)
+
+	// spends of pruned outputs are still recorded
+	doubleSpend := spendTx(
+		[]*chain.Input{chain.NewInput(1, 0, 0, chain.Zero())},
+		[]*chain.Output{chain.NewOutput(bob, big.NewInt(100))},
+	)
+	_, err = s.IsDoubleSpent(&doubleSpend)
+	require.IsType(t, &ErrPruned{}, err)
+	found, err := kv.Has(spendByTxIdxKey(1, 0, 0))
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func TestPrune_NothingBelowDepth(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateChain(t, s, chain.RandomAddress(), chain.RandomAddress())
+
+	report, err := Prune(kv, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), report.Pruned)
+	require.Equal(t, uint64(0), report.PrunedHeight)
+
+	for num := uint64(1); num <= 4; num++ {
+		txs, err := s.FindTransactionsByBlockNum(num)
+		require.NoError(t, err)
+		hashes, err := s.TransactionHashesByBlockNum(num)
+		require.NoError(t, err)
+		require.Len(t, hashes, len(txs))
+		for i, tx := range txs {
+			require.Equal(t, tx.Transaction.RLPHash(util.Sha256), hashes[i])
+		}
+	}
+}
+
+func TestPrune_ContinuesFromPrunedHeight(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	populateChain(t, s, alice, bob)
+
+	report, err := Prune(kv, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), report.Pruned)
+	require.Equal(t, uint64(3), report.PrunedHeight)
+
+	// block 5: bob spends block 2's output 0, settling block 2 below the
+	// pruned height. block 6: alice spends block 5's output.
+	_, err = s.PackageBlock([]chain.Transaction{
+		spendTx(
+			[]*chain.Input{chain.NewInput(2, 0, 0, chain.Zero())},
+			[]*chain.Output{chain.NewOutput(alice, big.NewInt(60))},
+		),
+	})
+	require.NoError(t, err)
+	_, err = s.PackageBlock([]chain.Transaction{
+		spendTx(
+			[]*chain.Input{chain.NewInput(5, 0, 0, chain.Zero())},
+			[]*chain.Output{chain.NewOutput(bob, big.NewInt(60))},
+		),
+	})
+	require.NoError(t, err)
+
+	report, err = Prune(kv, 1)
+	require.NoError(t, err)
+	// blocks 2 and 3, found through the inputs of blocks 4 and 5
+	require.Equal(t, uint64(2), report.Pruned)
+	require.Equal(t, uint64(5), report.PrunedHeight)
+	_, err = s.FindTransactionByBlockNumTxIdx(2, 0)
+	require.IsType(t, &ErrPruned{}, err)
+	_, err = s.FindTransactionByBlockNumTxIdx(4, 0)
+	require.NoError(t, err)
+	_, err = s.FindTransactionByBlockNumTxIdx(5, 0)
+	require.NoError(t, err)
+
+	integrity, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, integrity.Issues)
+	require.Equal(t, uint64(3), integrity.PrunedCount)
+}
+
+func TestPrune_RefusesRootNode(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateChain(t, s, chain.RandomAddress(), chain.RandomAddress())
+
+	isRoot, err := IsRootNode(kv)
+	require.NoError(t, err)
+	require.False(t, isRoot)
+
+	require.NoError(t, MarkRootNode(kv))
+	isRoot, err = IsRootNode(kv)
+	require.NoError(t, err)
+	require.True(t, isRoot)
+
+	_, err = Prune(kv, 0)
+	require.Equal(t, ErrPruneRootNode, err)
+	_, err = s.FindTransactionByBlockNumTxIdx(1, 0)
+	require.NoError(t, err)
+}
@@ -0,0 +1,614 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/protobuf/proto"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/merkle"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/kyokan/plasma/util"
+	"github.com/syndtr/goleveldb/leveldb"
+	"hash"
+	"io"
+	"time"
+)
+
+// SnapshotVersion is incremented whenever the snapshot format changes.
+const SnapshotVersion = 1
+
+const maxSnapshotRecordSize = 64 * 1024 * 1024
+
+var snapshotMagic = []byte("PLASMASNAP")
+
+// snapshotImportKey is set while a snapshot is being imported, and removed
+// in the same batch that records the schema version once it has finished.
+const snapshotImportKey = "SNAPSHOT_IMPORT"
+
+// snapshotClearBatchSize bounds the number of keys deleted per batch when
+// a failed import is removed.
+const snapshotClearBatchSize = 10000
+
+// ErrPartialImport is returned when opening a database whose snapshot
+// import did not finish, for example because the node was killed.
+var ErrPartialImport = errors.New("database contains a partially imported snapshot, delete it and import the snapshot again")
+
+// A snapshot is the magic bytes followed by a sequence of records, each
+// encoded as a type byte, a uvarint length and the payload, and terminated
+// by an end record and the SHA-256 checksum of everything before it.
+const (
+	snapshotRecordHeader  byte = 1
+	snapshotRecordBlock   byte = 2
+	snapshotRecordExit    byte = 3
+	snapshotRecordCursors byte = 4
+	snapshotRecordEnd     byte = 5
+	snapshotRecordFeeExit byte = 6
+)
+
+type SnapshotHeader struct {
+	Version   uint32
+	Height    uint64
+	CreatedAt uint64
+}
+
+type snapshotCursors struct {
+	LastDepositPoll     uint64
+	LastTxExitPoll      uint64
+	LastDepositExitPoll uint64
+	LastSubmittedBlock  uint64
+}
+
+type ErrInvalidSnapshot struct {
+	Reason string
+}
+
+func NewErrInvalidSnapshot(reason string, args ...interface{}) error {
+	return &ErrInvalidSnapshot{
+		Reason: fmt.Sprintf(reason, args...),
+	}
+}
+
+func (e *ErrInvalidSnapshot) Error() string {
+	return fmt.Sprintf("invalid snapshot: %s", e.Reason)
+}
+
+type ErrSnapshotPruned struct {
+	PrunedHeight uint64
+}
+
+func NewErrSnapshotPruned(prunedHeight uint64) error {
+	return &ErrSnapshotPruned{
+		PrunedHeight: prunedHeight,
+	}
+}
+
+func (e *ErrSnapshotPruned) Error() string {
+	return fmt.Sprintf("cannot export a snapshot of a database pruned up to block %d, export it from an unpruned node such as the root node", e.PrunedHeight)
+}
+
+// ExportSnapshot writes blocks 1 through height along with their metadata,
+// confirmed transactions, exit records, fee exits and poll cursors to w. A
+// height of zero exports up to the latest block. Pruned databases no
+// longer have every transaction, so they cannot be exported.
+func ExportSnapshot(storage Storage, w io.Writer, height uint64) (*SnapshotHeader, error) {
+	prunedHeight, err := storage.PrunedHeight()
+	if err != nil {
+		return nil, err
+	}
+	if prunedHeight > 0 {
+		return nil, NewErrSnapshotPruned(prunedHeight)
+	}
+
+	latest, err := storage.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("database is empty")
+	}
+	if height == 0 {
+		height = latest.Header.Number
+	}
+	if height > latest.Header.Number {
+		return nil, fmt.Errorf("cannot export to height %d, latest block is %d", height, latest.Header.Number)
+	}
+
+	sw := newSnapshotWriter(w)
+	sw.write(snapshotMagic)
+
+	header := &SnapshotHeader{
+		Version:   SnapshotVersion,
+		Height:    height,
+		CreatedAt: uint64(time.Now().Unix()),
+	}
+	if err := sw.writeRLPRecord(snapshotRecordHeader, header); err != nil {
+		return nil, err
+	}
+
+	for num := uint64(1); num <= height; num++ {
+		block, meta, txs, err := storage.FullBlockAtHeight(num)
+		if err != nil {
+			return nil, err
+		}
+		res := &pb.GetBlockResponse{
+			Block:    block.Proto(),
+			Metadata: meta.Proto(),
+		}
+		for _, tx := range txs {
+			res.ConfirmedTransactions = append(res.ConfirmedTransactions, tx.Proto())
+		}
+		enc, err := proto.Marshal(res)
+		if err != nil {
+			return nil, err
+		}
+		sw.writeRecord(snapshotRecordBlock, enc)
+	}
+
+	exits, err := storage.Exits()
+	if err != nil {
+		return nil, err
+	}
+	var firstDroppedExit uint64
+	for _, exit := range exits {
+		if exit.PlasmaBlockNumber > height {
+			firstDroppedExit = earliestExit(firstDroppedExit, exit.EthereumBlockNumber)
+			continue
+		}
+		enc, err := exit.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		sw.writeRecord(snapshotRecordExit, enc)
+	}
+
+	fees, err := storage.FeeLedger()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range fees {
+		if entry.BlockNumber > height || !entry.Exited() {
+			continue
+		}
+		enc, err := entry.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		sw.writeRecord(snapshotRecordFeeExit, enc)
+	}
+
+	cursors, err := readCursors(storage)
+	if err != nil {
+		return nil, err
+	}
+	cursors.truncate(height, firstDroppedExit)
+	if err := sw.writeRLPRecord(snapshotRecordCursors, cursors); err != nil {
+		return nil, err
+	}
+
+	sw.writeRecord(snapshotRecordEnd, nil)
+	if err := sw.finish(); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// VerifySnapshot checks the snapshot's magic bytes, version and checksum
+// without importing anything.
+func VerifySnapshot(r io.Reader) (*SnapshotHeader, error) {
+	var header *SnapshotHeader
+	err := readSnapshot(r, func(recordType byte, payload []byte) error {
+		if recordType == snapshotRecordHeader {
+			header = new(SnapshotHeader)
+			return rlp.DecodeBytes(payload, header)
+		}
+		return nil
+	})
+	return header, err
+}
+
+// FindSnapshotBlock returns block num from the snapshot without importing
+// anything.
+func FindSnapshotBlock(r io.Reader, num uint64) (*chain.Block, error) {
+	var block *chain.Block
+	err := readSnapshot(r, func(recordType byte, payload []byte) error {
+		if recordType != snapshotRecordBlock || block != nil {
+			return nil
+		}
+		var res pb.GetBlockResponse
+		if err := proto.Unmarshal(payload, &res); err != nil {
+			return err
+		}
+		if res.Block.Header.Number == num {
+			block = chain.BlockFromProto(res.Block)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("snapshot does not contain block %d", num)
+	}
+	return block, nil
+}
+
+// ImportSnapshot verifies the snapshot in r and inserts its contents into
+// an empty database. Blocks after maxHeight are skipped; a maxHeight of zero
+// imports every block in the snapshot. The snapshot is trusted: blocks are
+// checked for internal consistency, but transactions are not validated.
+//
+// Blocks are written to kv one batch at a time as they are read, so the
+// import does not hold the chain in memory. The schema version is written
+// last, together with the removal of the import marker, so an import that
+// is interrupted is refused by Migrate with ErrPartialImport. An import
+// that fails is removed from kv, so it can be retried.
+func ImportSnapshot(kv KV, r io.ReadSeeker, maxHeight uint64) (*SnapshotHeader, error) {
+	header, err := VerifySnapshot(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxHeight > header.Height {
+		return nil, fmt.Errorf("snapshot only contains blocks up to %d", header.Height)
+	}
+	if maxHeight == 0 {
+		maxHeight = header.Height
+	}
+
+	if err := checkSnapshotImport(kv); err != nil {
+		return nil, err
+	}
+	latest, err := NewKVStorage(kv).LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		return nil, fmt.Errorf("cannot import snapshot into a non-empty database")
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if err := kv.Put(prefixKey(snapshotImportKey), []byte{1}); err != nil {
+		return nil, err
+	}
+	if err := importSnapshotRecords(kv, r, maxHeight); err != nil {
+		if clearErr := clearKV(kv); clearErr != nil {
+			return nil, fmt.Errorf("%s, and failed to remove the partial import: %s", err, clearErr)
+		}
+		return nil, err
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(prefixKey(schemaVersionKey), uint64ToBytes(CurrentSchemaVersion()))
+	batch.Delete(prefixKey(snapshotImportKey))
+	if err := kv.Write(batch); err != nil {
+		return nil, err
+	}
+
+	header.Height = maxHeight
+	return header, nil
+}
+
+func importSnapshotRecords(kv KV, r io.Reader, maxHeight uint64) error {
+	storage := NewKVStorage(kv)
+	var prevHash util.Hash
+	var firstDroppedExit uint64
+	return readSnapshot(r, func(recordType byte, payload []byte) error {
+		switch recordType {
+		case snapshotRecordBlock:
+			var res pb.GetBlockResponse
+			if err := proto.Unmarshal(payload, &res); err != nil {
+				return err
+			}
+			if res.Block.Header.Number > maxHeight {
+				return nil
+			}
+			if err := importSnapshotBlock(storage, &res, prevHash); err != nil {
+				return err
+			}
+			prevHash = res.Block.Hash
+		case snapshotRecordExit:
+			var loc ExitLocator
+			if err := loc.UnmarshalBinary(payload); err != nil {
+				return err
+			}
+			if loc.PlasmaBlockNumber > maxHeight {
+				firstDroppedExit = earliestExit(firstDroppedExit, loc.EthereumBlockNumber)
+				return nil
+			}
+			return storage.MarkTransactionAsExited(
+				loc.PlasmaBlockNumber,
+				loc.PlasmaTransactionIndex,
+				loc.PlasmaOutputIndex,
+				loc.EthereumBlockNumber,
+				common.BytesToHash(loc.EthereumTransactionHash),
+			)
+		case snapshotRecordFeeExit:
+			var entry FeeEntry
+			if err := entry.UnmarshalBinary(payload); err != nil {
+				return err
+			}
+			if entry.BlockNumber > maxHeight {
+				return nil
+			}
+			return storage.MarkFeesExited(entry.BlockNumber, common.BytesToHash(entry.EthereumTransactionHash))
+		case snapshotRecordCursors:
+			var cursors snapshotCursors
+			if err := rlp.DecodeBytes(payload, &cursors); err != nil {
+				return err
+			}
+			cursors.truncate(maxHeight, firstDroppedExit)
+			return writeCursors(storage, &cursors)
+		}
+		return nil
+	})
+}
+
+// checkSnapshotImport returns ErrPartialImport if a snapshot import into
+// kv did not finish.
+func checkSnapshotImport(kv KV) error {
+	importing, err := kv.Has(prefixKey(snapshotImportKey))
+	if err != nil {
+		return err
+	}
+	if importing {
+		return ErrPartialImport
+	}
+	return nil
+}
+
+// clearKV deletes every key in kv, in batches of at most
+// snapshotClearBatchSize keys.
+func clearKV(kv KV) error {
+	for {
+		batch := new(leveldb.Batch)
+		iter := kv.NewIterator(nil)
+		for batch.Len() < snapshotClearBatchSize && iter.Next() {
+			batch.Delete(iter.Key())
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		if batch.Len() == 0 {
+			return nil
+		}
+		if err := kv.Write(batch); err != nil {
+			return err
+		}
+	}
+}
+
+func importSnapshotBlock(storage Storage, res *pb.GetBlockResponse, prevHash util.Hash) error {
+	block := chain.BlockFromProto(res.Block)
+	meta := chain.BlockMetadataFromProto(res.Metadata)
+	num := block.Header.Number
+	if !bytes.Equal(block.Header.Hash(), block.BlockHash) {
+		return NewErrInvalidSnapshot("block %d has an invalid hash", num)
+	}
+	if !bytes.Equal(block.Header.PrevHash, prevHash) {
+		return NewErrInvalidSnapshot("block %d does not follow the previous block", num)
+	}
+	if uint32(len(res.ConfirmedTransactions)) != meta.TransactionCount {
+		return NewErrInvalidSnapshot("block %d has %d transactions, expected %d", num, len(res.ConfirmedTransactions), meta.TransactionCount)
+	}
+
+	txs := make([]chain.ConfirmedTransaction, len(res.ConfirmedTransactions))
+	hashables := make([]util.RLPHashable, len(res.ConfirmedTransactions))
+	for i, protoTx := range res.ConfirmedTransactions {
+		tx, err := chain.ConfirmedTransactionFromProto(protoTx)
+		if err != nil {
+			return err
+		}
+		txs[i] = *tx
+		hashables[i] = tx.Transaction
+	}
+	if !bytes.Equal(merkle.Root(hashables), block.Header.MerkleRoot) {
+		return NewErrInvalidSnapshot("block %d has an invalid merkle root", num)
+	}
+
+	return storage.InsertBlock(block, meta, txs)
+}
+
+func readCursors(storage Storage) (*snapshotCursors, error) {
+	var cursors snapshotCursors
+	var err error
+	if cursors.LastDepositPoll, err = storage.LastDepositPoll(); err != nil {
+		return nil, err
+	}
+	if cursors.LastTxExitPoll, err = storage.LastTxExitPoll(); err != nil {
+		return nil, err
+	}
+	if cursors.LastDepositExitPoll, err = storage.LastDepositExitPoll(); err != nil {
+		return nil, err
+	}
+	if cursors.LastSubmittedBlock, err = storage.LastSubmittedBlock(); err != nil {
+		return nil, err
+	}
+	return &cursors, nil
+}
+
+// truncate rewinds cursors read from a database whose blocks after height
+// are left out of a snapshot. The exits of those blocks are left out too,
+// so the exit pollers restart before the earliest of them,
+// firstDroppedExit, to find them again. Zero means none were left out.
+func (c *snapshotCursors) truncate(height uint64, firstDroppedExit uint64) {
+	if c.LastSubmittedBlock > height {
+		c.LastSubmittedBlock = height
+	}
+	if firstDroppedExit == 0 {
+		return
+	}
+	if c.LastTxExitPoll >= firstDroppedExit {
+		c.LastTxExitPoll = firstDroppedExit - 1
+	}
+	if c.LastDepositExitPoll >= firstDroppedExit {
+		c.LastDepositExitPoll = firstDroppedExit - 1
+	}
+}
+
+// earliestExit returns the earlier of two Ethereum block numbers, where
+// zero means none.
+func earliestExit(a uint64, b uint64) uint64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+func writeCursors(storage Storage, cursors *snapshotCursors) error {
+	if err := storage.SaveDepositPoll(cursors.LastDepositPoll); err != nil {
+		return err
+	}
+	if err := storage.SaveTxExitPoll(cursors.LastTxExitPoll); err != nil {
+		return err
+	}
+	if err := storage.SaveDepositExitPoll(cursors.LastDepositExitPoll); err != nil {
+		return err
+	}
+	return storage.SaveLastSubmittedBlock(cursors.LastSubmittedBlock)
+}
+
+type snapshotWriter struct {
+	w      *bufio.Writer
+	hasher hash.Hash
+	err    error
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	return &snapshotWriter{
+		w:      bufio.NewWriter(w),
+		hasher: sha256.New(),
+	}
+}
+
+func (s *snapshotWriter) write(b []byte) {
+	if s.err != nil {
+		return
+	}
+	s.hasher.Write(b)
+	_, s.err = s.w.Write(b)
+}
+
+func (s *snapshotWriter) writeRecord(recordType byte, payload []byte) {
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(payload)))
+	s.write([]byte{recordType})
+	s.write(lenBuf[:n])
+	s.write(payload)
+}
+
+func (s *snapshotWriter) writeRLPRecord(recordType byte, val interface{}) error {
+	enc, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return err
+	}
+	s.writeRecord(recordType, enc)
+	return nil
+}
+
+func (s *snapshotWriter) finish() error {
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := s.w.Write(s.hasher.Sum(nil)); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// hashingReader hashes every byte read through it.
+type hashingReader struct {
+	r      *bufio.Reader
+	hasher hash.Hash
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.hasher.Write(p[:n])
+	return n, err
+}
+
+func (h *hashingReader) ReadByte() (byte, error) {
+	b, err := h.r.ReadByte()
+	if err == nil {
+		h.hasher.Write([]byte{b})
+	}
+	return b, err
+}
+
+// readSnapshot calls cb for every record in the snapshot. Since the
+// checksum is only known at the end, callers that write to storage must
+// verify the snapshot first.
+func readSnapshot(r io.Reader, cb func(recordType byte, payload []byte) error) error {
+	hr := &hashingReader{
+		r:      bufio.NewReader(r),
+		hasher: sha256.New(),
+	}
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(hr, magic); err != nil || !bytes.Equal(magic, snapshotMagic) {
+		return NewErrInvalidSnapshot("not a snapshot file")
+	}
+
+	first := true
+	for {
+		recordType, err := hr.ReadByte()
+		if err != nil {
+			return NewErrInvalidSnapshot("unexpected end of file")
+		}
+		size, err := binary.ReadUvarint(hr)
+		if err != nil {
+			return NewErrInvalidSnapshot("unexpected end of file")
+		}
+		if size > maxSnapshotRecordSize {
+			return NewErrInvalidSnapshot("record of %d bytes is too large", size)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(hr, payload); err != nil {
+			return NewErrInvalidSnapshot("unexpected end of file")
+		}
+
+		if first {
+			if recordType != snapshotRecordHeader {
+				return NewErrInvalidSnapshot("missing header")
+			}
+			var header SnapshotHeader
+			if err := rlp.DecodeBytes(payload, &header); err != nil {
+				return NewErrInvalidSnapshot("malformed header")
+			}
+			if header.Version != SnapshotVersion {
+				return NewErrInvalidSnapshot("unsupported version %d", header.Version)
+			}
+			first = false
+		}
+
+		if recordType == snapshotRecordEnd {
+			break
+		}
+		if err := cb(recordType, payload); err != nil {
+			return err
+		}
+	}
+
+	expected := hr.hasher.Sum(nil)
+	checksum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hr.r, checksum); err != nil {
+		return NewErrInvalidSnapshot("missing checksum")
+	}
+	if !bytes.Equal(expected, checksum) {
+		return NewErrInvalidSnapshot("checksum mismatch")
+	}
+	if _, err := hr.r.ReadByte(); err != io.EOF {
+		return NewErrInvalidSnapshot("trailing data after checksum")
+	}
+
+	return nil
+}
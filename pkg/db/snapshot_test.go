@@ -0,0 +1,269 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/golang/protobuf/proto"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+func exportTestSnapshot(t *testing.T, alice, bob common.Address) []byte {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateChain(t, s, alice, bob)
+
+	tx, err := s.FindTransactionByBlockNumTxIdx(2, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.MarkTransactionAsExited(2, 0, 0, 99, common.BytesToHash(tx.Hash())))
+	require.NoError(t, s.SaveTxExitPoll(120))
+	require.NoError(t, s.SaveDepositPoll(110))
+	require.NoError(t, s.SaveLastSubmittedBlock(4))
+
+	var buf bytes.Buffer
+	header, err := ExportSnapshot(s, &buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), header.Height)
+	require.Equal(t, uint32(SnapshotVersion), header.Version)
+	return buf.Bytes()
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	snapshot := exportTestSnapshot(t, alice, bob)
+
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	header, err := ImportSnapshot(kv, bytes.NewReader(snapshot), 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), header.Height)
+
+	latest, err := s.LatestBlock()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), latest.Header.Number)
+
+	exits, err := s.Exits()
+	require.NoError(t, err)
+	require.Len(t, exits, 1)
+	require.Equal(t, uint64(99), exits[0].EthereumBlockNumber)
+
+	txExitPoll, err := s.LastTxExitPoll()
+	require.NoError(t, err)
+	require.Equal(t, uint64(120), txExitPoll)
+	depositPoll, err := s.LastDepositPoll()
+	require.NoError(t, err)
+	require.Equal(t, uint64(110), depositPoll)
+
+	// bob's output from block 2 has exited
+	bobBal, err := s.Balance(bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), bobBal.Int64())
+
+	report, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+}
+
+func TestSnapshot_ImportToHeight(t *testing.T) {
+	snapshot := exportTestSnapshot(t, chain.RandomAddress(), chain.RandomAddress())
+
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	header, err := ImportSnapshot(kv, bytes.NewReader(snapshot), 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), header.Height)
+
+	latest, err := s.LatestBlock()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), latest.Header.Number)
+
+	_, err = ImportSnapshot(kv, bytes.NewReader(snapshot), 0)
+	require.Error(t, err)
+}
+
+func TestSnapshot_TruncationRewindsCursors(t *testing.T) {
+	snapshot := exportTestSnapshot(t, chain.RandomAddress(), chain.RandomAddress())
+
+	// the exit from block 2, seen in Ethereum block 99, is left out, so
+	// the exit poller must see block 99 again
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	_, err := ImportSnapshot(kv, bytes.NewReader(snapshot), 1)
+	require.NoError(t, err)
+	requireCursors(t, s, 98, 1)
+
+	exits, err := s.Exits()
+	require.NoError(t, err)
+	require.Empty(t, exits)
+
+	// blocks after 2 have no exits, so the poller does not need to go back
+	kv, s = newMemoryLevelStorage(t)
+	defer kv.Close()
+	_, err = ImportSnapshot(kv, bytes.NewReader(snapshot), 3)
+	require.NoError(t, err)
+	requireCursors(t, s, 120, 3)
+}
+
+func TestSnapshot_ExportToHeightRewindsCursors(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateChain(t, s, chain.RandomAddress(), chain.RandomAddress())
+	tx, err := s.FindTransactionByBlockNumTxIdx(2, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.MarkTransactionAsExited(2, 0, 0, 99, common.BytesToHash(tx.Hash())))
+	require.NoError(t, s.SaveTxExitPoll(120))
+	require.NoError(t, s.SaveLastSubmittedBlock(4))
+
+	var buf bytes.Buffer
+	_, err = ExportSnapshot(s, &buf, 1)
+	require.NoError(t, err)
+
+	importedKV, imported := newMemoryLevelStorage(t)
+	defer importedKV.Close()
+	_, err = ImportSnapshot(importedKV, bytes.NewReader(buf.Bytes()), 0)
+	require.NoError(t, err)
+	requireCursors(t, imported, 98, 1)
+}
+
+func TestSnapshot_FailedImportCanBeRetried(t *testing.T) {
+	snapshot := exportTestSnapshot(t, chain.RandomAddress(), chain.RandomAddress())
+
+	// a correctly checksummed snapshot that is missing block 3
+	var buf bytes.Buffer
+	sw := newSnapshotWriter(&buf)
+	sw.write(snapshotMagic)
+	err := readSnapshot(bytes.NewReader(snapshot), func(recordType byte, payload []byte) error {
+		if recordType == snapshotRecordBlock {
+			var res pb.GetBlockResponse
+			require.NoError(t, proto.Unmarshal(payload, &res))
+			if res.Block.Header.Number == 3 {
+				return nil
+			}
+		}
+		sw.writeRecord(recordType, payload)
+		return nil
+	})
+	require.NoError(t, err)
+	sw.writeRecord(snapshotRecordEnd, nil)
+	require.NoError(t, sw.finish())
+
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	_, err = ImportSnapshot(kv, bytes.NewReader(buf.Bytes()), 0)
+	require.IsType(t, &ErrInvalidSnapshot{}, err)
+	latest, err := s.LatestBlock()
+	require.NoError(t, err)
+	require.Nil(t, latest)
+
+	_, err = ImportSnapshot(kv, bytes.NewReader(snapshot), 0)
+	require.NoError(t, err)
+	latest, err = s.LatestBlock()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), latest.Header.Number)
+}
+
+// crashingKV fails every write after the first writes, like a node that
+// is killed partway through an import.
+type crashingKV struct {
+	KV
+	writes int
+}
+
+func (c *crashingKV) Put(key []byte, value []byte) error {
+	if c.writes == 0 {
+		return errors.New("crashed")
+	}
+	c.writes--
+	return c.KV.Put(key, value)
+}
+
+func (c *crashingKV) Write(batch *leveldb.Batch) error {
+	if c.writes == 0 {
+		return errors.New("crashed")
+	}
+	c.writes--
+	return c.KV.Write(batch)
+}
+
+func TestSnapshot_InterruptedImportIsDetected(t *testing.T) {
+	snapshot := exportTestSnapshot(t, chain.RandomAddress(), chain.RandomAddress())
+
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	// the import marker and the first two blocks are written
+	_, err := ImportSnapshot(&crashingKV{KV: kv, writes: 3}, bytes.NewReader(snapshot), 0)
+	require.Error(t, err)
+	latest, err := s.LatestBlock()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), latest.Header.Number)
+	version, err := SchemaVersion(kv)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), version)
+
+	require.Equal(t, ErrPartialImport, Migrate(kv))
+	_, err = ImportSnapshot(kv, bytes.NewReader(snapshot), 0)
+	require.Equal(t, ErrPartialImport, err)
+}
+
+func TestSnapshot_ImportRecordsSchemaVersion(t *testing.T) {
+	snapshot := exportTestSnapshot(t, chain.RandomAddress(), chain.RandomAddress())
+
+	kv, _ := newMemoryLevelStorage(t)
+	defer kv.Close()
+	_, err := ImportSnapshot(kv, bytes.NewReader(snapshot), 0)
+	require.NoError(t, err)
+	version, err := SchemaVersion(kv)
+	require.NoError(t, err)
+	require.Equal(t, CurrentSchemaVersion(), version)
+	require.NoError(t, Migrate(kv))
+}
+
+func TestSnapshot_ExportRefusesPrunedDatabase(t *testing.T) {
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	populateChain(t, s, chain.RandomAddress(), chain.RandomAddress())
+	_, err := Prune(kv, 1)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = ExportSnapshot(s, &buf, 0)
+	require.IsType(t, &ErrSnapshotPruned{}, err)
+	require.Equal(t, uint64(3), err.(*ErrSnapshotPruned).PrunedHeight)
+	require.Zero(t, buf.Len())
+}
+
+func requireCursors(t *testing.T, s Storage, txExitPoll uint64, lastSubmitted uint64) {
+	actualTxExitPoll, err := s.LastTxExitPoll()
+	require.NoError(t, err)
+	require.Equal(t, txExitPoll, actualTxExitPoll)
+	actualLastSubmitted, err := s.LastSubmittedBlock()
+	require.NoError(t, err)
+	require.Equal(t, lastSubmitted, actualLastSubmitted)
+}
+
+func TestSnapshot_RejectsCorruption(t *testing.T) {
+	snapshot := exportTestSnapshot(t, chain.RandomAddress(), chain.RandomAddress())
+
+	corrupted := make([]byte, len(snapshot))
+	copy(corrupted, snapshot)
+	corrupted[len(snapshot)/2] ^= 0xff
+	_, err := VerifySnapshot(bytes.NewReader(corrupted))
+	require.Error(t, err)
+
+	_, err = VerifySnapshot(bytes.NewReader(snapshot[:len(snapshot)-1]))
+	require.Error(t, err)
+
+	kv, s := newMemoryLevelStorage(t)
+	defer kv.Close()
+	_, err = ImportSnapshot(kv, bytes.NewReader(corrupted), 0)
+	require.Error(t, err)
+	latest, err := s.LatestBlock()
+	require.NoError(t, err)
+	require.Nil(t, latest)
+}
@@ -4,12 +4,17 @@ import (
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/ethereum/go-ethereum/common"
 	"math/big"
+	"github.com/kyokan/plasma/util"
 )
 
 type Storage interface {
 	ProcessDeposit(tx chain.Transaction) (deposit *chain.BlockResult, err error)
 	FindTransactionsByBlockNum(blkNum uint64) ([]chain.ConfirmedTransaction, error)
 	FindTransactionByBlockNumTxIdx(blkNum uint64, txIdx uint32) (*chain.ConfirmedTransaction, error)
+	TransactionHashesByBlockNum(blkNum uint64) ([]util.Hash, error)
+	// PrunedHeight returns the highest block considered for pruning, or
+	// zero if the database has never been pruned.
+	PrunedHeight() (uint64, error)
 
 	Balance(addr common.Address) (*big.Int, error)
 	SpendableTxs(addr common.Address) ([]chain.ConfirmedTransaction, error)
@@ -34,6 +39,12 @@ type Storage interface {
 	SaveDepositExitPoll(idx uint64) error
 
 	MarkTransactionAsExited(plasmaBlockNum uint64, plasmaTxIdx uint32, outIdx uint8, ethBlockNumber uint64, ethTransactionHash common.Hash) error
+	Exits() ([]ExitLocator, error)
+
+	// FeeLedger returns the fees collected in each block, ordered by block
+	// number. Blocks without fees have no entry.
+	FeeLedger() ([]FeeEntry, error)
+	MarkFeesExited(blockNum uint64, ethTransactionHash common.Hash) error
 
 	IsDoubleSpent(tx *chain.Transaction) (bool, error)
 
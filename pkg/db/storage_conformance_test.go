@@ -0,0 +1,281 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// conformanceSuite is run against every backend. New backends must be
+// added to TestStorageConformance.
+type conformanceSuite struct {
+	suite.Suite
+	backend string
+	dir     string
+	kv      KV
+	storage Storage
+}
+
+func TestStorageConformance(t *testing.T) {
+	for _, backend := range []string{BackendLevelDB, BackendBolt, BackendMemory} {
+		t.Run(backend, func(t *testing.T) {
+			suite.Run(t, &conformanceSuite{
+				backend: backend,
+			})
+		})
+	}
+}
+
+func (c *conformanceSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "plasma-conformance")
+	require.NoError(c.T(), err)
+	c.dir = dir
+	kv, storage, err := CreateStorage(c.backend, dir)
+	require.NoError(c.T(), err)
+	c.kv = kv
+	c.storage = storage
+}
+
+func (c *conformanceSuite) TearDownTest() {
+	require.NoError(c.T(), c.kv.Close())
+	require.NoError(c.T(), os.RemoveAll(c.dir))
+}
+
+func (c *conformanceSuite) TestGetPutDelete() {
+	t := c.T()
+	_, err := c.kv.Get([]byte("missing"))
+	require.Equal(t, ErrNotFound, err)
+	found, err := c.kv.Has([]byte("missing"))
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, c.kv.Put([]byte("key"), []byte("value")))
+	val, err := c.kv.Get([]byte("key"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), val)
+
+	require.NoError(t, c.kv.Put([]byte("empty"), nil))
+	found, err = c.kv.Has([]byte("empty"))
+	require.NoError(t, err)
+	require.True(t, found)
+	val, err = c.kv.Get([]byte("empty"))
+	require.NoError(t, err)
+	require.Len(t, val, 0)
+
+	require.NoError(t, c.kv.Delete([]byte("key")))
+	_, err = c.kv.Get([]byte("key"))
+	require.Equal(t, ErrNotFound, err)
+	require.NoError(t, c.kv.Delete([]byte("key")))
+}
+
+func (c *conformanceSuite) TestBatch() {
+	t := c.T()
+	require.NoError(t, c.kv.Put([]byte("a"), []byte("1")))
+
+	batch := new(leveldb.Batch)
+	batch.Put([]byte("b"), []byte("2"))
+	batch.Delete([]byte("a"))
+	batch.Put([]byte("c"), []byte{})
+	require.NoError(t, c.kv.Write(batch))
+
+	_, err := c.kv.Get([]byte("a"))
+	require.Equal(t, ErrNotFound, err)
+	val, err := c.kv.Get([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("2"), val)
+	found, err := c.kv.Has([]byte("c"))
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func (c *conformanceSuite) TestIteratorPrefixAndOrder() {
+	t := c.T()
+	for _, key := range []string{"tx::2", "tx::10", "tx::1", "txs::1", "t", "utxo::1"} {
+		require.NoError(t, c.kv.Put([]byte(key), []byte(key)))
+	}
+
+	iter := c.kv.NewIterator([]byte("tx::"))
+	var keys []string
+	for iter.Next() {
+		require.True(t, bytes.Equal(iter.Key(), iter.Value()))
+		keys = append(keys, string(iter.Key()))
+	}
+	iter.Release()
+	require.NoError(t, iter.Error())
+	require.Equal(t, []string{"tx::1", "tx::10", "tx::2"}, keys)
+
+	iter = c.kv.NewIterator([]byte("nothing"))
+	require.False(t, iter.Next())
+	iter.Release()
+}
+
+func (c *conformanceSuite) TestIteratorPages() {
+	t := c.T()
+	count := boltIteratorPageSize*2 + 1
+	batch := new(leveldb.Batch)
+	for i := 0; i < count; i++ {
+		batch.Put([]byte(fmt.Sprintf("page::%05d", i)), []byte{byte(i)})
+	}
+	batch.Put([]byte("pages"), nil)
+	require.NoError(t, c.kv.Write(batch))
+
+	// writing while iterating must not block
+	iter := c.kv.NewIterator([]byte("page::"))
+	var i int
+	for iter.Next() {
+		require.Equal(t, fmt.Sprintf("page::%05d", i), string(iter.Key()))
+		require.Equal(t, []byte{byte(i)}, iter.Value())
+		require.NoError(t, c.kv.Put([]byte(fmt.Sprintf("written::%05d", i)), nil))
+		i++
+	}
+	iter.Release()
+	require.NoError(t, iter.Error())
+	require.Equal(t, count, i)
+	require.False(t, iter.Next())
+	require.Nil(t, iter.Key())
+}
+
+func (c *conformanceSuite) TestSchemaVersion() {
+	version, err := SchemaVersion(c.kv)
+	require.NoError(c.T(), err)
+	require.Equal(c.T(), CurrentSchemaVersion(), version)
+}
+
+func (c *conformanceSuite) TestBlocksAndBalances() {
+	t := c.T()
+	alice := chain.RandomAddress()
+	bob := chain.RandomAddress()
+	populateChain(t, c.storage, alice, bob)
+
+	latest, err := c.storage.LatestBlock()
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), latest.Header.Number)
+
+	block, meta, txs, err := c.storage.FullBlockAtHeight(2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), block.Header.Number)
+	require.Equal(t, uint32(1), meta.TransactionCount)
+	require.Len(t, txs, 1)
+
+	aliceBal, err := c.storage.Balance(alice)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), aliceBal.Int64())
+	bobBal, err := c.storage.Balance(bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(110), bobBal.Int64())
+
+	utxos, err := c.storage.UTXOs(bob)
+	require.NoError(t, err)
+	require.Len(t, utxos, 2)
+	require.Equal(t, uint64(2), utxos[0].Transaction.Body.BlockNumber)
+	require.Equal(t, uint64(4), utxos[1].Transaction.Body.BlockNumber)
+
+	report, err := CheckIntegrity(c.kv)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+}
+
+func (c *conformanceSuite) TestDoubleSpends() {
+	t := c.T()
+	alice := chain.RandomAddress()
+	populateChain(t, c.storage, alice, chain.RandomAddress())
+
+	doubleSpend := spendTx(
+		[]*chain.Input{chain.NewInput(1, 0, 0, chain.Zero())},
+		[]*chain.Output{chain.NewOutput(alice, big.NewInt(100))},
+	)
+	spent, err := c.storage.IsDoubleSpent(&doubleSpend)
+	require.NoError(t, err)
+	require.True(t, spent)
+
+	spending, err := c.storage.FindDoubleSpendingTransaction(1, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), spending.Transaction.Body.BlockNumber)
+
+	deposit := depositTx(2, alice, 10)
+	spent, err = c.storage.IsDoubleSpent(&deposit)
+	require.NoError(t, err)
+	require.True(t, spent)
+	deposit = depositTx(3, alice, 10)
+	spent, err = c.storage.IsDoubleSpent(&deposit)
+	require.NoError(t, err)
+	require.False(t, spent)
+}
+
+func (c *conformanceSuite) TestExitsAndPolls() {
+	t := c.T()
+	bob := chain.RandomAddress()
+	populateChain(t, c.storage, chain.RandomAddress(), bob)
+
+	require.NoError(t, c.storage.MarkTransactionAsExited(2, 0, 0, 50, [32]byte{}))
+	exits, err := c.storage.Exits()
+	require.NoError(t, err)
+	require.Len(t, exits, 1)
+	require.Equal(t, uint64(2), exits[0].PlasmaBlockNumber)
+	bobBal, err := c.storage.Balance(bob)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), bobBal.Int64())
+
+	require.NoError(t, c.storage.SaveTxExitPoll(10))
+	require.NoError(t, c.storage.SaveDepositExitPoll(11))
+	require.NoError(t, c.storage.SaveDepositPoll(12))
+	require.NoError(t, c.storage.SaveLastSubmittedBlock(4))
+	cursors, err := readCursors(c.storage)
+	require.NoError(t, err)
+	require.Equal(t, &snapshotCursors{
+		LastDepositPoll:     12,
+		LastTxExitPoll:      10,
+		LastDepositExitPoll: 11,
+		LastSubmittedBlock:  4,
+	}, cursors)
+}
+
+func (c *conformanceSuite) TestFeeLedger() {
+	t := c.T()
+	populateFeeChain(t, c.storage)
+
+	ledger, err := c.storage.FeeLedger()
+	require.NoError(t, err)
+	require.Len(t, ledger, 2)
+	require.Equal(t, uint64(2), ledger[0].BlockNumber)
+	require.Equal(t, int64(3), ledger[0].Amount.Int64())
+	require.Equal(t, uint64(10), ledger[1].BlockNumber)
+	require.Equal(t, int64(7), ledger[1].Amount.Int64())
+	require.False(t, ledger[0].Exited())
+
+	hash := common.HexToHash("0x01")
+	require.NoError(t, c.storage.MarkFeesExited(2, hash))
+	ledger, err = c.storage.FeeLedger()
+	require.NoError(t, err)
+	require.True(t, ledger[0].Exited())
+	require.Equal(t, hash[:], ledger[0].EthereumTransactionHash)
+	require.False(t, ledger[1].Exited())
+
+	require.Equal(t, ErrNotFound, c.storage.MarkFeesExited(3, hash))
+}
+
+func (c *conformanceSuite) TestSnapshotToMemory() {
+	t := c.T()
+	populateChain(t, c.storage, chain.RandomAddress(), chain.RandomAddress())
+
+	var buf bytes.Buffer
+	_, err := ExportSnapshot(c.storage, &buf, 0)
+	require.NoError(t, err)
+
+	kv, _ := newMemoryLevelStorage(t)
+	defer kv.Close()
+	_, err = ImportSnapshot(kv, bytes.NewReader(buf.Bytes()), 0)
+	require.NoError(t, err)
+	report, err := CheckIntegrity(kv)
+	require.NoError(t, err)
+	require.Empty(t, report.Issues)
+}
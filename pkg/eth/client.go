@@ -10,13 +10,14 @@ import (
 	"github.com/kyokan/plasma/pkg/eth/contracts"
 	"github.com/kyokan/plasma/pkg/chain"
 	"crypto/ecdsa"
-	"github.com/ethereum/go-ethereum/crypto"
 	log2 "github.com/kyokan/plasma/pkg/log"
 	"github.com/sirupsen/logrus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/pkg/errors"
 	"fmt"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"strings"
 )
 
 var clientLogger = log2.ForSubsystem("EthClient")
@@ -38,6 +39,8 @@ type Exit struct {
 
 type Block struct {
 	Root      []byte
+	NumTxns   uint32
+	FeeAmount *big.Int
 	StartedAt *big.Int
 }
 
@@ -69,6 +72,20 @@ func (e *ErrDepositNotFound) Error() string {
 	return fmt.Sprintf("deposit with nonce %s not found", e.depositNonce)
 }
 
+type ErrBlockNotFound struct {
+	blockNumber uint64
+}
+
+func NewErrBlockNotFound(blockNumber uint64) error {
+	return &ErrBlockNotFound{
+		blockNumber: blockNumber,
+	}
+}
+
+func (e *ErrBlockNotFound) Error() string {
+	return fmt.Sprintf("block %d has not been submitted", e.blockNumber)
+}
+
 type Client interface {
 	UserAddress() common.Address
 	SubmitBlock(util.Hash, uint32, *big.Int, *big.Int) error
@@ -87,7 +104,14 @@ type Client interface {
 	StartedDepositExitFilter(uint64, uint64) ([]contracts.PlasmaStartedDepositExit, uint64, error)
 
 	EthereumBlockHeight() (uint64, error)
+	ChainID() (*big.Int, error)
 	LookupDeposit(depositNonce *big.Int) (*big.Int, common.Address, error)
+	LookupBlock(blkNum uint64) (*Block, error)
+
+	StartFeeExit(blkNum uint64) (*types.Receipt, error)
+	FinalizeTransactionExits() (*types.Receipt, error)
+	Withdraw() (*types.Receipt, error)
+	WithdrawableBalance(addr common.Address) (*big.Int, error)
 }
 
 type DepositEvent struct {
@@ -100,10 +124,10 @@ type clientState struct {
 	client     *ethclient.Client
 	rpc        *rpc.Client
 	contract   *contracts.Plasma
-	privateKey *ecdsa.PrivateKey
+	signer     Signer
 }
 
-func NewClient(nodeUrl string, contractAddr string, privateKey *ecdsa.PrivateKey) (Client, error) {
+func NewClient(nodeUrl string, contractAddr string, signer Signer) (Client, error) {
 	addr := common.HexToAddress(contractAddr)
 	c, err := rpc.Dial(nodeUrl)
 	if err != nil {
@@ -116,12 +140,12 @@ func NewClient(nodeUrl string, contractAddr string, privateKey *ecdsa.PrivateKey
 		client:     client,
 		rpc:        c,
 		contract:   contract,
-		privateKey: privateKey,
+		signer:     signer,
 	}, nil
 }
 
 func (c *clientState) UserAddress() common.Address {
-	return crypto.PubkeyToAddress(*(c.privateKey.Public()).(*ecdsa.PublicKey))
+	return c.signer.Address()
 }
 
 func (c *clientState) SubmitBlock(merkleHash util.Hash, txInBlock uint32, feesInBlock *big.Int, blkNum *big.Int) error {
@@ -129,7 +153,7 @@ func (c *clientState) SubmitBlock(merkleHash util.Hash, txInBlock uint32, feesIn
 }
 
 func (c *clientState) SubmitBlocks(merkleHashes []util.Hash, txInBlocks []uint32, feesInBlocks []*big.Int, firstBlkNum *big.Int) error {
-	opts := CreateKeyedTransactor(c.privateKey)
+	opts := CreateTransactor(c.signer)
 	hashes := make([][32]byte, len(merkleHashes))
 	for i := 0; i < len(merkleHashes); i++ {
 		copy(hashes[i][:], merkleHashes[i][:32])
@@ -147,16 +171,16 @@ func (c *clientState) SubmitBlocks(merkleHashes []util.Hash, txInBlocks []uint32
 }
 
 func (c *clientState) Deposit(amount *big.Int) (*types.Receipt, error) {
-	opts := CreateKeyedTransactor(c.privateKey)
+	opts := CreateTransactor(c.signer)
 	opts.Value = amount
 
 	clientLogger.WithFields(logrus.Fields{
 		"amount":  amount.Text(10),
-		"address": crypto.PubkeyToAddress(c.privateKey.PublicKey).Hex(),
+		"address": c.signer.Address().Hex(),
 	}).Info("depositing funds")
 
 	receipt, err := ContractCall(c.client, func() (*types.Transaction, error) {
-		return c.contract.Deposit(opts, crypto.PubkeyToAddress(c.privateKey.PublicKey))
+		return c.contract.Deposit(opts, c.signer.Address())
 	})
 	if err != nil {
 		return nil, err
@@ -172,7 +196,7 @@ func (c *clientState) Deposit(amount *big.Int) (*types.Receipt, error) {
 
 func (c *clientState) Exit(exitingTx *chain.ConfirmedTransaction, exitingOutput uint8, proof []byte) (*types.Receipt, error) {
 	bond := big.NewInt(500000)
-	opts := CreateKeyedTransactor(c.privateKey)
+	opts := CreateTransactor(c.signer)
 	opts.Value = bond
 
 	exitingTxPos := [3]*big.Int{
@@ -181,14 +205,10 @@ func (c *clientState) Exit(exitingTx *chain.ConfirmedTransaction, exitingOutput
 		util.Uint82Big(exitingOutput),
 	}
 
-	var sig []byte
-	if exitingOutput == 0 {
-		sig = exitingTx.ConfirmSigs[0][:]
-	} else if exitingOutput == 1 {
-		sig = exitingTx.ConfirmSigs[1][:]
-	} else {
+	if exitingOutput >= chain.MaxOutputs {
 		return nil, errors.New("invalid output idx")
 	}
+	sig := exitingTx.ConfirmSigBytes()
 
 	receipt, err := ContractCall(c.client, func() (*types.Transaction, error) {
 		return c.contract.StartTransactionExit(opts, exitingTxPos, exitingTx.Transaction.RLP(), proof, sig, bond)
@@ -205,7 +225,7 @@ func (c *clientState) Exit(exitingTx *chain.ConfirmedTransaction, exitingOutput
 }
 
 func (c *clientState) Challenge(exitingTx *chain.ConfirmedTransaction, exitingOutput uint8, exitingDepositNonce *big.Int, challengingTx *chain.ConfirmedTransaction, proof []byte) (*types.Receipt, error) {
-	opts := CreateKeyedTransactor(c.privateKey)
+	opts := CreateTransactor(c.signer)
 
 	exitingTxPos := [4]*big.Int{
 		util.Uint642Big(exitingTx.Transaction.Body.BlockNumber),
@@ -219,8 +239,9 @@ func (c *clientState) Challenge(exitingTx *chain.ConfirmedTransaction, exitingOu
 		util.Uint322Big(challengingTx.Transaction.Body.TransactionIndex),
 	}
 
+	confirmSig := challengingTx.ConfirmSigAt(0)
 	receipt, err := ContractCall(c.client, func() (*types.Transaction, error) {
-		return c.contract.ChallengeExit(opts, exitingTxPos, challengingTxPos, challengingTx.Transaction.RLP(), proof, challengingTx.ConfirmSigs[0][:])
+		return c.contract.ChallengeExit(opts, exitingTxPos, challengingTxPos, challengingTx.Transaction.RLP(), proof, confirmSig[:])
 	})
 	if err != nil {
 		return nil, err
@@ -243,6 +264,34 @@ func (c *clientState) EthereumBlockHeight() (uint64, error) {
 	return header.Number.Uint64(), nil
 }
 
+// ChainID returns the EIP-155 chain ID reported by eth_chainId, which is
+// what signatures are bound to. Nodes that predate eth_chainId report their
+// network ID instead, which is the chain ID on most networks but not all.
+func (c *clientState) ChainID() (*big.Int, error) {
+	var chainID hexutil.Big
+	err := c.rpc.CallContext(context.Background(), &chainID, "eth_chainId")
+	if err == nil {
+		return (*big.Int)(&chainID), nil
+	}
+	if !isMethodUnavailable(err) {
+		return nil, err
+	}
+
+	clientLogger.Warn("eth_chainId is unavailable, using the network ID as the chain ID")
+	return c.client.NetworkID(context.Background())
+}
+
+// methodNotFoundCode is the JSON-RPC error code for unknown methods.
+const methodNotFoundCode = -32601
+
+func isMethodUnavailable(err error) bool {
+	if rpcErr, ok := err.(rpc.Error); ok && rpcErr.ErrorCode() == methodNotFoundCode {
+		return true
+	}
+	// ganache reports unknown methods as "Method eth_chainId not supported."
+	return strings.Contains(err.Error(), "not supported")
+}
+
 func (c *clientState) LookupDeposit(depositNonce *big.Int) (*big.Int, common.Address, error) {
 	var addr common.Address
 	res, err := c.contract.Deposits(&bind.CallOpts{
@@ -256,3 +305,95 @@ func (c *clientState) LookupDeposit(depositNonce *big.Int) (*big.Int, common.Add
 	}
 	return res.Amount, res.Owner, nil
 }
+
+func (c *clientState) LookupBlock(blkNum uint64) (*Block, error) {
+	res, err := c.contract.PlasmaChain(&bind.CallOpts{
+		Pending: false,
+	}, util.Uint642Big(blkNum))
+	if err != nil {
+		return nil, err
+	}
+	if res.Header == [32]byte{} {
+		return nil, NewErrBlockNotFound(blkNum)
+	}
+	return &Block{
+		Root:      res.Header[:],
+		NumTxns:   uint32(res.NumTxns.Uint64()),
+		FeeAmount: res.FeeAmount,
+		StartedAt: res.CreatedAt,
+	}, nil
+}
+
+// StartFeeExit starts an exit for the fees collected in block blkNum,
+// posting the contract's minimum exit bond. Only the operator may exit
+// fees.
+func (c *clientState) StartFeeExit(blkNum uint64) (*types.Receipt, error) {
+	bond, err := c.contract.MinExitBond(&bind.CallOpts{
+		Pending: false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	opts := CreateTransactor(c.signer)
+	opts.Value = bond
+
+	receipt, err := ContractCall(c.client, func() (*types.Transaction, error) {
+		return c.contract.StartFeeExit(opts, util.Uint642Big(blkNum), big.NewInt(0))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientLogger.WithFields(logrus.Fields{
+		"blockNumber": blkNum,
+		"txHash":      receipt.TxHash.Hex(),
+	}).Info("successfully started fee exit")
+
+	return receipt, nil
+}
+
+// FinalizeTransactionExits finalizes every transaction and fee exit whose
+// challenge period has ended, crediting their amounts to their owners'
+// withdrawable balances.
+func (c *clientState) FinalizeTransactionExits() (*types.Receipt, error) {
+	opts := CreateTransactor(c.signer)
+	receipt, err := ContractCall(c.client, func() (*types.Transaction, error) {
+		return c.contract.FinalizeTransactionExits(opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientLogger.WithFields(logrus.Fields{
+		"txHash": receipt.TxHash.Hex(),
+	}).Info("successfully finalized transaction exits")
+
+	return receipt, nil
+}
+
+// Withdraw transfers the signer's withdrawable balance out of the
+// contract.
+func (c *clientState) Withdraw() (*types.Receipt, error) {
+	opts := CreateTransactor(c.signer)
+	receipt, err := ContractCall(c.client, func() (*types.Transaction, error) {
+		return c.contract.Withdraw(opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clientLogger.WithFields(logrus.Fields{
+		"address": c.signer.Address().Hex(),
+		"txHash":  receipt.TxHash.Hex(),
+	}).Info("successfully withdrew funds")
+
+	return receipt, nil
+}
+
+// WithdrawableBalance returns the amount of finalized exits that addr can
+// withdraw from the contract.
+func (c *clientState) WithdrawableBalance(addr common.Address) (*big.Int, error) {
+	return c.contract.Balances(&bind.CallOpts{
+		Pending: false,
+	}, addr)
+}
@@ -0,0 +1,55 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+type chainIDService struct{}
+
+func (s *chainIDService) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(61))
+}
+
+type netService struct{}
+
+func (s *netService) Version() string {
+	return "1"
+}
+
+func newRPCTestClient(t *testing.T, services map[string]interface{}) *clientState {
+	server := rpc.NewServer()
+	for name, service := range services {
+		require.NoError(t, server.RegisterName(name, service))
+	}
+	c := rpc.DialInProc(server)
+	return &clientState{
+		client: ethclient.NewClient(c),
+		rpc:    c,
+	}
+}
+
+func TestClient_ChainID(t *testing.T) {
+	// the chain ID and network ID differ on networks such as ETC
+	client := newRPCTestClient(t, map[string]interface{}{
+		"eth": &chainIDService{},
+		"net": &netService{},
+	})
+	chainID, err := client.ChainID()
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(61), chainID)
+}
+
+func TestClient_ChainID_NetworkIDFallback(t *testing.T) {
+	client := newRPCTestClient(t, map[string]interface{}{
+		"net": &netService{},
+	})
+	chainID, err := client.ChainID()
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1), chainID)
+}
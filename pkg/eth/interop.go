@@ -6,6 +6,8 @@ import (
 	"crypto/ecdsa"
 	"math/big"
 	"context"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
 )
 
 func CreateCallOpts(address common.Address) *bind.CallOpts {
@@ -16,8 +18,27 @@ func CreateCallOpts(address common.Address) *bind.CallOpts {
 }
 
 func CreateKeyedTransactor(privateKey *ecdsa.PrivateKey) *bind.TransactOpts {
-	auth := bind.NewKeyedTransactor(privateKey)
-	auth.GasPrice = new(big.Int).SetUint64(10 * 1000000000)
-	auth.GasLimit = uint64(4712388)
-	return auth
-}
\ No newline at end of file
+	return CreateTransactor(NewPrivateKeySigner(privateKey))
+}
+
+// CreateTransactor returns options for sending contract transactions signed
+// by signer.
+func CreateTransactor(signer Signer) *bind.TransactOpts {
+	from := signer.Address()
+	return &bind.TransactOpts{
+		From: from,
+		Signer: func(txSigner types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if address != from {
+				return nil, errors.New("not authorized to sign this account")
+			}
+			hash := txSigner.Hash(tx)
+			sig, err := signer.SignHash(hash[:])
+			if err != nil {
+				return nil, err
+			}
+			return tx.WithSignature(txSigner, sig)
+		},
+		GasPrice: new(big.Int).SetUint64(10 * 1000000000),
+		GasLimit: uint64(4712388),
+	}
+}
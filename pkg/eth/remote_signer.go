@@ -0,0 +1,233 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+	"time"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io/ioutil"
+)
+
+// The remote signer protocol is two JSON endpoints:
+//
+//	GET  /address -> {"address": "0x..."}
+//	POST /sign    {"hash": "0x..."} -> {"signature": "0x..."}
+//
+// Both require an "Authorization: Bearer <token>" header carrying a token
+// shared by the signer and the node. NewSignerHandler serves the protocol
+// for any Signer, so an operator can run the key on a separate machine and
+// point the node at it.
+const (
+	remoteSignerAddressPath = "/address"
+	remoteSignerSignPath    = "/sign"
+)
+
+const remoteSignerBearerPrefix = "Bearer "
+
+type remoteAddressResponse struct {
+	Address string `json:"address"`
+}
+
+type remoteSignRequest struct {
+	Hash string `json:"hash"`
+}
+
+type remoteSignResponse struct {
+	Signature string `json:"signature"`
+}
+
+type ErrRemoteSigner struct {
+	statusCode int
+	message    string
+}
+
+func NewErrRemoteSigner(statusCode int, message string) error {
+	return &ErrRemoteSigner{
+		statusCode: statusCode,
+		message:    message,
+	}
+}
+
+func (e *ErrRemoteSigner) Error() string {
+	return fmt.Sprintf("remote signer returned status %d: %s", e.statusCode, e.message)
+}
+
+// LoadSignerToken reads the token shared by a remote signer and the node
+// from file.
+func LoadSignerToken(file string) (string, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(contents))
+	if token == "" {
+		return "", errors.New("signer token file " + file + " is empty")
+	}
+	return token, nil
+}
+
+type RemoteSigner struct {
+	url     string
+	token   string
+	client  *http.Client
+	address common.Address
+}
+
+// NewRemoteSigner connects to the remote signer at url, authenticating
+// with token, and fetches the address it signs for.
+func NewRemoteSigner(url string, token string) (*RemoteSigner, error) {
+	if token == "" {
+		return nil, errors.New("remote signer token cannot be empty")
+	}
+
+	s := &RemoteSigner{
+		url:   strings.TrimRight(url, "/"),
+		token: token,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+
+	var res remoteAddressResponse
+	if err := s.do(http.MethodGet, remoteSignerAddressPath, nil, &res); err != nil {
+		return nil, errors.Wrap(err, "failed to fetch remote signer address")
+	}
+	if !common.IsHexAddress(res.Address) {
+		return nil, errors.New("remote signer returned an invalid address")
+	}
+	s.address = common.HexToAddress(res.Address)
+	return s, nil
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *RemoteSigner) SignHash(hash []byte) ([]byte, error) {
+	var res remoteSignResponse
+	req := &remoteSignRequest{
+		Hash: hexutil.Encode(hash),
+	}
+	if err := s.do(http.MethodPost, remoteSignerSignPath, req, &res); err != nil {
+		return nil, err
+	}
+	sig, err := hexutil.Decode(res.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "remote signer returned an invalid signature")
+	}
+
+	// never trust the remote end to have signed with the right key
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return nil, errors.Wrap(err, "remote signer returned an invalid signature")
+	}
+	if crypto.PubkeyToAddress(*pubKey) != s.address {
+		return nil, errors.New("remote signer signed with the wrong key")
+	}
+	// Ethereum rejects transactions with high-s signatures
+	canonicalize(sig)
+	return sig, nil
+}
+
+func (s *RemoteSigner) do(method string, path string, body interface{}, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, s.url+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", remoteSignerBearerPrefix+s.token)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var errRes struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&errRes)
+		return NewErrRemoteSigner(res.StatusCode, errRes.Error)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// NewSignerHandler serves the remote signer protocol for signer to clients
+// presenting token. It signs any hash it is given, so if token is empty
+// every request is refused.
+func NewSignerHandler(signer Signer, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(remoteSignerAddressPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeSignerError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		writeSignerJSON(w, &remoteAddressResponse{
+			Address: signer.Address().Hex(),
+		})
+	})
+	mux.HandleFunc(remoteSignerSignPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeSignerError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var req remoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeSignerError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		hash, err := hexutil.Decode(req.Hash)
+		if err != nil || len(hash) != 32 {
+			writeSignerError(w, http.StatusBadRequest, "hash must be 32 hex-encoded bytes")
+			return
+		}
+		sig, err := signer.SignHash(hash)
+		if err != nil {
+			writeSignerError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeSignerJSON(w, &remoteSignResponse{
+			Signature: hexutil.Encode(sig),
+		})
+	})
+
+	// tokens are hashed so that they can be compared in constant time
+	// regardless of their length
+	tokenHash := sha256.Sum256([]byte(token))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		given := sha256.Sum256([]byte(strings.TrimPrefix(header, remoteSignerBearerPrefix)))
+		if token == "" || !strings.HasPrefix(header, remoteSignerBearerPrefix) || !hmac.Equal(given[:], tokenHash[:]) {
+			writeSignerError(w, http.StatusUnauthorized, "invalid or missing signer token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeSignerJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeSignerError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
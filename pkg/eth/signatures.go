@@ -1,35 +1,134 @@
 package eth
 
 import (
-	"crypto/ecdsa"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	"errors"
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/kyokan/plasma/util"
+	"fmt"
+	"math/big"
 	)
 
-func Sign(privKey *ecdsa.PrivateKey, hash util.Hash) (chain.Signature, error) {
-	ethHash := util.GethHash(hash)
+var (
+	secp256k1N = crypto.S256().Params().N
+	// secp256k1HalfN bounds the s value of canonical signatures. For every
+	// valid signature, flipping s to N - s and the parity of v gives another
+	// one, so only the signature with the lower s is accepted.
+	secp256k1HalfN = new(big.Int).Rsh(secp256k1N, 1)
+)
+
+// ErrNonCanonicalSignature is returned when validating a signature that is
+// not in canonical low-s form.
+var ErrNonCanonicalSignature = errors.New("signature is not in canonical form")
+
+// Sign signs hash as an Ethereum signed message, which is the format the
+// Plasma contract expects for transaction and confirmation signatures.
+func Sign(signer Signer, hash util.Hash) (chain.Signature, error) {
+	return signDigest(signer, util.GethHash(hash))
+}
+
+// SignTypedData signs an EIP-712 digest as-is, as eth_signTypedData does.
+func SignTypedData(signer Signer, digest util.Hash) (chain.Signature, error) {
+	return signDigest(signer, digest)
+}
+
+// SignVersioned signs a hash returned by VersionedSignatureHash or
+// VersionedConfirmationHash the way its version requires.
+func SignVersioned(signer Signer, version chain.SignatureVersion, hash util.Hash) (chain.Signature, error) {
+	if version == chain.SignatureVersionTypedData {
+		return SignTypedData(signer, hash)
+	}
+	return Sign(signer, hash)
+}
+
+// SignConfirmation signs the confirmation of tx's inclusion in the block
+// with merkleRoot. Confirmations are always legacy signatures, since the
+// contract recovers them when exits are started and challenged.
+func SignConfirmation(signer Signer, tx *chain.Transaction, merkleRoot util.Hash) (chain.Signature, error) {
+	return Sign(signer, tx.ConfirmationHash(merkleRoot))
+}
+
+func signDigest(signer Signer, digest util.Hash) (chain.Signature, error) {
 	var sig chain.Signature
-	rawSig, err := crypto.Sign(ethHash, privKey)
+	rawSig, err := signer.SignHash(digest)
 	if err != nil {
 		return sig, err
 	}
+	if len(rawSig) != len(sig) {
+		return sig, errors.New("invalid signature length")
+	}
 	copy(sig[:], rawSig)
+	// remote signers are not guaranteed to return low-s signatures or
+	// recovery ids of 0 or 1
+	canonicalize(sig[:])
+	sig.NormalizeV()
 	return sig, nil
 }
 
+// IsCanonicalSignature returns true if sig is a 65-byte [R || S || V]
+// signature with V of 0 or 1, and R and S within range with S in the lower
+// half of the curve order. Signatures with V of 27 or 28 have to be
+// normalized with Signature.NormalizeV first.
+func IsCanonicalSignature(sig []byte) bool {
+	if len(sig) != 65 {
+		return false
+	}
+	if sig[64] > 1 {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if r.Sign() == 0 || r.Cmp(secp256k1N) >= 0 {
+		return false
+	}
+	return s.Sign() != 0 && s.Cmp(secp256k1HalfN) <= 0
+}
+
+// canonicalize replaces a high s in sig with N - s, flipping v so that it
+// recovers the same key.
+func canonicalize(sig []byte) {
+	s := new(big.Int).SetBytes(sig[32:64])
+	if s.Cmp(secp256k1HalfN) <= 0 {
+		return
+	}
+	s.Sub(secp256k1N, s)
+	copy(sig[32:64], common.LeftPadBytes(s.Bytes(), 32))
+	switch sig[64] {
+	case 0, 1:
+		sig[64] ^= 1
+	default:
+		sig[64] = 55 - sig[64]
+	}
+}
+
 func ValidateSignature(hash, signature []byte, address common.Address) error {
-	ethHash := util.GethHash(hash)
+	return validateDigest(util.GethHash(hash), signature, address)
+}
+
+// ValidateTypedDataSignature returns nil if address signed the EIP-712
+// digest.
+func ValidateTypedDataSignature(digest, signature []byte, address common.Address) error {
+	if len(digest) != 32 {
+		return fmt.Errorf("invalid digest length %d", len(digest))
+	}
+	return validateDigest(digest, signature, address)
+}
+
+// ValidateVersionedSignature is the counterpart of SignVersioned.
+func ValidateVersionedSignature(version chain.SignatureVersion, hash, signature []byte, address common.Address) error {
+	if version == chain.SignatureVersionTypedData {
+		return ValidateTypedDataSignature(hash, signature, address)
+	}
+	return ValidateSignature(hash, signature, address)
+}
 
-	sigCopy := make([]byte, len(signature))
-	copy(sigCopy, signature)
-	if len(sigCopy) == 65 && sigCopy[64] > 26 {
-		sigCopy[64] -= 27
+func validateDigest(digest, signature []byte, address common.Address) error {
+	if !IsCanonicalSignature(signature) {
+		return ErrNonCanonicalSignature
 	}
 
-	pubKey, err := crypto.SigToPub(ethHash, sigCopy)
+	pubKey, err := crypto.SigToPub(digest, signature)
 	if err != nil {
 		return err
 	}
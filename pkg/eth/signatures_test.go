@@ -0,0 +1,126 @@
+package eth
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+)
+
+// highSSigner returns the high-s twin of every signature it makes, as a
+// remote signer might.
+type highSSigner struct {
+	*PrivateKeySigner
+}
+
+func (s *highSSigner) SignHash(hash []byte) ([]byte, error) {
+	sig, err := s.PrivateKeySigner.SignHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return highS(sig), nil
+}
+
+func highS(sig []byte) []byte {
+	twin := make([]byte, len(sig))
+	copy(twin, sig)
+	s := new(big.Int).Sub(secp256k1N, new(big.Int).SetBytes(sig[32:64]))
+	copy(twin[32:64], common.LeftPadBytes(s.Bytes(), 32))
+	twin[64] ^= 1
+	return twin
+}
+
+func TestIsCanonicalSignature(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	hash := util.GethHash(util.Sha256([]byte("hello")))
+	sig, err := crypto.Sign(hash, key)
+	require.NoError(t, err)
+	require.True(t, IsCanonicalSignature(sig))
+
+	withOffset := make([]byte, len(sig))
+	copy(withOffset, sig)
+	withOffset[64] += 27
+	require.False(t, IsCanonicalSignature(withOffset))
+	var normalized chain.Signature
+	copy(normalized[:], withOffset)
+	normalized.NormalizeV()
+	require.Equal(t, sig, normalized[:])
+	require.True(t, IsCanonicalSignature(normalized[:]))
+
+	twin := highS(sig)
+	require.False(t, IsCanonicalSignature(twin))
+	pub, err := crypto.SigToPub(hash, twin)
+	require.NoError(t, err)
+	require.Equal(t, crypto.PubkeyToAddress(key.PublicKey), crypto.PubkeyToAddress(*pub))
+
+	badV := make([]byte, len(sig))
+	copy(badV, sig)
+	badV[64] = 2
+	require.False(t, IsCanonicalSignature(badV))
+
+	zeroR := make([]byte, len(sig))
+	copy(zeroR[32:], sig[32:])
+	require.False(t, IsCanonicalSignature(zeroR))
+
+	require.False(t, IsCanonicalSignature(sig[:64]))
+}
+
+func TestValidateSignature_Malleated(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := NewPrivateKeySigner(key)
+	hash := util.Sha256([]byte("hello"))
+
+	sig, err := Sign(signer, hash)
+	require.NoError(t, err)
+	require.NoError(t, ValidateSignature(hash, sig[:], signer.Address()))
+	require.Equal(t, ErrNonCanonicalSignature, ValidateSignature(hash, highS(sig[:]), signer.Address()))
+
+	typedSig, err := SignTypedData(signer, hash)
+	require.NoError(t, err)
+	require.NoError(t, ValidateTypedDataSignature(hash, typedSig[:], signer.Address()))
+	require.Equal(t, ErrNonCanonicalSignature, ValidateTypedDataSignature(hash, highS(typedSig[:]), signer.Address()))
+}
+
+func TestSign_Canonicalizes(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := &highSSigner{
+		PrivateKeySigner: NewPrivateKeySigner(key),
+	}
+	hash := util.Sha256([]byte("hello"))
+
+	sig, err := Sign(signer, hash)
+	require.NoError(t, err)
+	require.True(t, IsCanonicalSignature(sig[:]))
+	require.NoError(t, ValidateSignature(hash, sig[:], signer.Address()))
+}
+
+func TestSignConfirmation_Legacy(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := NewPrivateKeySigner(key)
+	body := chain.ZeroBody()
+	body.Input0 = chain.NewInput(1, 0, 0, chain.Zero())
+	body.Output0 = chain.NewOutput(chain.RandomAddress(), big.NewInt(10))
+	tx := &chain.Transaction{
+		Body: body,
+		Sigs: [2]chain.Signature{chain.RandomConfirmationSig()},
+	}
+	merkleRoot := util.Sha256([]byte("root"))
+
+	sig, err := SignConfirmation(signer, tx, merkleRoot)
+	require.NoError(t, err)
+
+	// the contract checks sha256(sha256(rlp(tx)) || merkleRoot)
+	legacyHash := util.Sha256(append(util.Sha256(tx.RLP()), merkleRoot...))
+	require.NoError(t, ValidateSignature(legacyHash, sig[:], signer.Address()))
+	domainHash, err := tx.VersionedConfirmationHash(chain.SignatureVersionDomain, chain.NewDomain(big.NewInt(1), chain.RandomAddress()), merkleRoot)
+	require.NoError(t, err)
+	require.Error(t, ValidateSignature(domainHash, sig[:], signer.Address()))
+}
@@ -0,0 +1,35 @@
+package eth
+
+import (
+	"crypto/ecdsa"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer signs on behalf of a single Ethereum account. It is used to sign
+// both Plasma transactions and the Ethereum transactions that submit blocks,
+// so the key itself never has to be loaded into the node.
+type Signer interface {
+	Address() common.Address
+	// SignHash signs a 32-byte digest as-is and returns a 65-byte
+	// [R || S || V] signature, where V is 0 or 1.
+	SignHash(hash []byte) ([]byte, error)
+}
+
+type PrivateKeySigner struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+func NewPrivateKeySigner(privateKey *ecdsa.PrivateKey) *PrivateKeySigner {
+	return &PrivateKeySigner{
+		privateKey: privateKey,
+	}
+}
+
+func (s *PrivateKeySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(s.privateKey.PublicKey)
+}
+
+func (s *PrivateKeySigner) SignHash(hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, s.privateKey)
+}
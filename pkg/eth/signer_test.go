@@ -0,0 +1,113 @@
+package eth
+
+import (
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/util"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const testSignerToken = "signer-token"
+
+type wrongKeySigner struct {
+	*PrivateKeySigner
+	address common.Address
+}
+
+func (s *wrongKeySigner) Address() common.Address {
+	return s.address
+}
+
+func TestRemoteSigner(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	local := NewPrivateKeySigner(key)
+	server := httptest.NewServer(NewSignerHandler(local, testSignerToken))
+	defer server.Close()
+
+	remote, err := NewRemoteSigner(server.URL, testSignerToken)
+	require.NoError(t, err)
+	require.Equal(t, local.Address(), remote.Address())
+
+	hash := util.Sha256([]byte("hello"))
+	sig, err := Sign(remote, hash)
+	require.NoError(t, err)
+	require.NoError(t, ValidateSignature(hash, sig[:], local.Address()))
+
+	_, err = remote.SignHash([]byte("short"))
+	require.Error(t, err)
+	require.IsType(t, &ErrRemoteSigner{}, err)
+}
+
+func TestRemoteSigner_Unauthenticated(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	server := httptest.NewServer(NewSignerHandler(NewPrivateKeySigner(key), testSignerToken))
+	defer server.Close()
+
+	_, err = NewRemoteSigner(server.URL, "wrong-token")
+	require.Error(t, err)
+	require.IsType(t, &ErrRemoteSigner{}, errors.Cause(err))
+	require.Equal(t, http.StatusUnauthorized, errors.Cause(err).(*ErrRemoteSigner).statusCode)
+
+	// requests without a token are refused, as is every request to a
+	// handler without one
+	res, err := http.Post(server.URL+remoteSignerSignPath, "application/json", strings.NewReader(`{"hash": "0x00"}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	open := httptest.NewServer(NewSignerHandler(NewPrivateKeySigner(key), ""))
+	defer open.Close()
+	req, err := http.NewRequest(http.MethodGet, open.URL+remoteSignerAddressPath, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", remoteSignerBearerPrefix)
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestRemoteSigner_WrongKey(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	server := httptest.NewServer(NewSignerHandler(&wrongKeySigner{
+		PrivateKeySigner: NewPrivateKeySigner(key),
+		address:          common.HexToAddress("0x627306090abab3a6e1400e9345bc60c78a8bef57"),
+	}, testSignerToken))
+	defer server.Close()
+
+	remote, err := NewRemoteSigner(server.URL, testSignerToken)
+	require.NoError(t, err)
+	_, err = remote.SignHash(util.Sha256([]byte("hello")))
+	require.Error(t, err)
+}
+
+func TestCreateTransactor(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	server := httptest.NewServer(NewSignerHandler(NewPrivateKeySigner(key), testSignerToken))
+	defer server.Close()
+	remote, err := NewRemoteSigner(server.URL, testSignerToken)
+	require.NoError(t, err)
+
+	opts := CreateTransactor(remote)
+	require.Equal(t, remote.Address(), opts.From)
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := opts.Signer(types.HomesteadSigner{}, opts.From, tx)
+	require.NoError(t, err)
+	sender, err := types.Sender(types.HomesteadSigner{}, signed)
+	require.NoError(t, err)
+	require.Equal(t, remote.Address(), sender)
+
+	_, err = opts.Signer(types.HomesteadSigner{}, common.Address{}, tx)
+	require.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// PassphraseEnvVar is read for the keystore passphrase before prompting,
+// so that keystores can be used non-interactively.
+const PassphraseEnvVar = "PLASMA_PASSPHRASE"
+
+// DefaultDir is where plasmacli keeps its keystore.
+const DefaultDir = "~/.plasma/keystore"
+
+type ErrNoPassphrase struct{}
+
+func NewErrNoPassphrase() error {
+	return &ErrNoPassphrase{}
+}
+
+func (e *ErrNoPassphrase) Error() string {
+	return fmt.Sprintf("no passphrase provided. set %s or run interactively", PassphraseEnvVar)
+}
+
+// PassphraseFunc returns the passphrase used to unlock or encrypt a key.
+type PassphraseFunc func() (string, error)
+
+// Open returns the go-ethereum keystore in dir, which is created if it does
+// not exist.
+func Open(dir string) *ethkeystore.KeyStore {
+	return ethkeystore.NewKeyStore(dir, ethkeystore.StandardScryptN, ethkeystore.StandardScryptP)
+}
+
+// FindAccount returns the path of the key file for addr in dir.
+func FindAccount(dir string, addr common.Address) (string, error) {
+	account, err := Open(dir).Find(accounts.Account{Address: addr})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find account %s", addr.Hex())
+	}
+	return account.URL.Path, nil
+}
+
+// IsEncrypted returns true if data is an encrypted JSON key file rather than
+// a raw hex private key.
+func IsEncrypted(data []byte) bool {
+	var keyJSON map[string]interface{}
+	if err := json.Unmarshal(data, &keyJSON); err != nil {
+		return false
+	}
+	_, hasCrypto := keyJSON["crypto"]
+	_, hasLegacyCrypto := keyJSON["Crypto"]
+	return hasCrypto || hasLegacyCrypto
+}
+
+// LoadKey reads a private key from path, which holds either an encrypted
+// JSON key file or a raw hex private key. passphrase is only called for
+// encrypted keys.
+func LoadKey(path string, passphrase PassphraseFunc) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read private key")
+	}
+
+	if !IsEncrypted(data) {
+		return crypto.HexToECDSA(strings.TrimSpace(string(data)))
+	}
+
+	auth, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	key, err := ethkeystore.DecryptKey(data, auth)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt private key")
+	}
+	return key.PrivateKey, nil
+}
+
+// NewSigner decrypts the key file at path and returns a signer for it.
+func NewSigner(path string, passphrase PassphraseFunc) (*eth.PrivateKeySigner, error) {
+	key, err := LoadKey(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return eth.NewPrivateKeySigner(key), nil
+}
+
+// PromptPassphrase returns a PassphraseFunc that reads the passphrase from
+// PassphraseEnvVar, or prompts for it on the terminal. If confirm is true,
+// a prompted passphrase must be entered twice.
+func PromptPassphrase(prompt string, confirm bool) PassphraseFunc {
+	return func() (string, error) {
+		if passphrase, ok := os.LookupEnv(PassphraseEnvVar); ok {
+			return passphrase, nil
+		}
+
+		fd := int(os.Stdin.Fd())
+		if !terminal.IsTerminal(fd) {
+			return "", NewErrNoPassphrase()
+		}
+
+		passphrase, err := readPassword(fd, prompt)
+		if err != nil {
+			return "", err
+		}
+		if !confirm {
+			return passphrase, nil
+		}
+
+		repeated, err := readPassword(fd, "Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if passphrase != repeated {
+			return "", errors.New("passphrases do not match")
+		}
+		return passphrase, nil
+	}
+}
+
+// FilePassphrase returns a PassphraseFunc that reads the passphrase from the
+// first line of the file at path.
+func FilePassphrase(path string) PassphraseFunc {
+	return func() (string, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to read passphrase file")
+		}
+		return strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r"), nil
+	}
+}
+
+func readPassword(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read passphrase")
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,76 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func staticPassphrase(passphrase string) PassphraseFunc {
+	return func() (string, error) {
+		return passphrase, nil
+	}
+}
+
+func TestLoadKey_Encrypted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plasma-keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	ks := ethkeystore.NewKeyStore(dir, ethkeystore.LightScryptN, ethkeystore.LightScryptP)
+	account, err := ks.ImportECDSA(key, "hunter2")
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(account.URL.Path)
+	require.NoError(t, err)
+	require.True(t, IsEncrypted(data))
+
+	loaded, err := LoadKey(account.URL.Path, staticPassphrase("hunter2"))
+	require.NoError(t, err)
+	require.Equal(t, crypto.FromECDSA(key), crypto.FromECDSA(loaded))
+
+	_, err = LoadKey(account.URL.Path, staticPassphrase("wrong"))
+	require.Error(t, err)
+
+	path, err := FindAccount(dir, account.Address)
+	require.NoError(t, err)
+	require.Equal(t, account.URL.Path, path)
+}
+
+func TestLoadKey_Hex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plasma-keystore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	path := filepath.Join(dir, "key")
+	hexKey := hexutil.Encode(crypto.FromECDSA(key))[2:]
+	require.NoError(t, ioutil.WriteFile(path, []byte(hexKey+"\n"), 0600))
+	require.False(t, IsEncrypted([]byte(hexKey)))
+
+	// raw keys never ask for a passphrase
+	loaded, err := LoadKey(path, func() (string, error) {
+		return "", errors.New("should not be called")
+	})
+	require.NoError(t, err)
+	require.Equal(t, crypto.FromECDSA(key), crypto.FromECDSA(loaded))
+}
+
+func TestPromptPassphrase_Env(t *testing.T) {
+	require.NoError(t, os.Setenv(PassphraseEnvVar, "from-env"))
+	defer os.Unsetenv(PassphraseEnvVar)
+
+	passphrase, err := PromptPassphrase("Passphrase: ", true)()
+	require.NoError(t, err)
+	require.Equal(t, "from-env", passphrase)
+}
@@ -0,0 +1,272 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// authorizationKey is the metadata key of bearer tokens. The gateway
+// forwards the HTTP Authorization header under the same key.
+const authorizationKey = "authorization"
+
+const bearerPrefix = "Bearer "
+
+// verifiedCertKey is the metadata key a Gateway sets when the HTTP client
+// it forwards presented a verified certificate. It is only trusted on
+// calls made by the gateway, which strips it from the client's headers.
+const verifiedCertKey = "x-plasma-verified-cert"
+
+// WriteMethods are the RPCs that change a node's state. They are subject
+// to the spam guard and, when it is configured, authentication.
+var WriteMethods = map[string]bool{
+	"/pb.Root/Send":    true,
+	"/pb.Root/Confirm": true,
+}
+
+type ErrUnauthenticated struct {
+	Reason string
+}
+
+func NewErrUnauthenticated(reason string) error {
+	return &ErrUnauthenticated{
+		Reason: reason,
+	}
+}
+
+func (e *ErrUnauthenticated) Error() string {
+	return "unauthenticated: " + e.Reason
+}
+
+// GRPCStatus reports the error as codes.Unauthenticated to gRPC clients.
+func (e *ErrUnauthenticated) GRPCStatus() *status.Status {
+	return status.New(codes.Unauthenticated, e.Error())
+}
+
+// Authenticator checks the credentials of clients calling write
+// endpoints. Clients authenticate with a bearer token that is one of the
+// API keys or an HS256 JWT signed with the JWT secret, or with a TLS
+// client certificate if the server verifies them. A nil Authenticator
+// admits every client.
+type Authenticator struct {
+	// apiKeys are hashed so that they can be compared in constant time
+	// regardless of their length.
+	apiKeys     [][]byte
+	jwtSecret   []byte
+	clientCerts bool
+	now         func() time.Time
+}
+
+// NewAuthenticator returns nil if no credentials are configured.
+func NewAuthenticator(apiKeys []string, jwtSecret []byte, clientCerts bool) *Authenticator {
+	if len(apiKeys) == 0 && len(jwtSecret) == 0 && !clientCerts {
+		return nil
+	}
+
+	auth := &Authenticator{
+		jwtSecret:   jwtSecret,
+		clientCerts: clientCerts,
+		now:         time.Now,
+	}
+	for _, key := range apiKeys {
+		hash := sha256.Sum256([]byte(key))
+		auth.apiKeys = append(auth.apiKeys, hash[:])
+	}
+	return auth
+}
+
+// LoadAuthenticator reads API keys, one per line, from apiKeysFile and
+// the JWT secret from jwtSecretFile. Either path may be empty. Lines in
+// apiKeysFile starting with # are ignored.
+func LoadAuthenticator(apiKeysFile string, jwtSecretFile string, clientCerts bool) (*Authenticator, error) {
+	var apiKeys []string
+	if apiKeysFile != "" {
+		contents, err := ioutil.ReadFile(apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			apiKeys = append(apiKeys, line)
+		}
+		if len(apiKeys) == 0 {
+			return nil, errors.New("no API keys found in " + apiKeysFile)
+		}
+	}
+
+	var jwtSecret []byte
+	if jwtSecretFile != "" {
+		contents, err := ioutil.ReadFile(jwtSecretFile)
+		if err != nil {
+			return nil, err
+		}
+		jwtSecret = bytes.TrimSpace(contents)
+		if len(jwtSecret) == 0 {
+			return nil, errors.New("JWT secret file " + jwtSecretFile + " is empty")
+		}
+	}
+
+	return NewAuthenticator(apiKeys, jwtSecret, clientCerts), nil
+}
+
+// Authenticate returns an ErrUnauthenticated unless token is valid or the
+// client presented a verified certificate.
+func (a *Authenticator) Authenticate(token string, verifiedCert bool) error {
+	if a == nil {
+		return nil
+	}
+	if verifiedCert && a.clientCerts {
+		return nil
+	}
+	if token == "" {
+		return NewErrUnauthenticated("missing credentials")
+	}
+	if a.isAPIKey(token) {
+		return nil
+	}
+	if len(a.jwtSecret) == 0 {
+		return NewErrUnauthenticated("invalid API key")
+	}
+	if err := a.verifyJWT(token); err != nil {
+		return NewErrUnauthenticated(err.Error())
+	}
+	return nil
+}
+
+func (a *Authenticator) isAPIKey(token string) bool {
+	hash := sha256.Sum256([]byte(token))
+	found := false
+	for _, key := range a.apiKeys {
+		if hmac.Equal(hash[:], key) {
+			found = true
+		}
+	}
+	return found
+}
+
+// verifyJWT checks the signature of an HS256 JWT and its exp and nbf
+// claims. Other claims are not checked.
+func (a *Authenticator) verifyJWT(token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return err
+	}
+	if header.Alg != "HS256" {
+		return errors.New("unsupported token algorithm " + header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errors.New("malformed token signature")
+	}
+	mac := hmac.New(sha256.New, a.jwtSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errors.New("invalid token signature")
+	}
+
+	var claims struct {
+		Exp *float64 `json:"exp"`
+		Nbf *float64 `json:"nbf"`
+	}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return err
+	}
+	now := float64(a.now().Unix())
+	if claims.Exp != nil && now >= *claims.Exp {
+		return errors.New("token expired")
+	}
+	if claims.Nbf != nil && now < *claims.Nbf {
+		return errors.New("token not yet valid")
+	}
+	return nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(decoded, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// AuthInterceptor authenticates clients before write RPCs run.
+func AuthInterceptor(auth *Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if WriteMethods[info.FullMethod] {
+			if err := auth.Authenticate(bearerToken(ctx), hasVerifiedCert(ctx)); err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AuthenticateRequest is AuthInterceptor for REST requests.
+func (a *Authenticator) AuthenticateRequest(r *http.Request) error {
+	return a.Authenticate(parseBearer(r.Header.Get("Authorization")), requestHasVerifiedCert(r))
+}
+
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationKey)
+	if len(values) == 0 {
+		return ""
+	}
+	return parseBearer(values[0])
+}
+
+func parseBearer(header string) string {
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(bearerPrefix):])
+}
+
+// hasVerifiedCert returns true if the client that made the call in ctx
+// presented a verified certificate. For calls made by a Gateway, this is
+// the HTTP client it forwarded.
+func hasVerifiedCert(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	if p.Addr != nil && p.Addr.Network() == gatewayNetwork {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(verifiedCertKey)
+		return len(values) == 1 && values[0] == "true"
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(info.State.VerifiedChains) > 0
+}
+
+func requestHasVerifiedCert(r *http.Request) bool {
+	return r.TLS != nil && len(r.TLS.VerifiedChains) > 0
+}
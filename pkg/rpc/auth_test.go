@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var jwtNow = time.Unix(1500000000, 0)
+
+func signJWT(secret string, header string, claims string) string {
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTestAuthenticator(apiKeys []string, jwtSecret string, clientCerts bool) *Authenticator {
+	auth := NewAuthenticator(apiKeys, []byte(jwtSecret), clientCerts)
+	auth.now = func() time.Time {
+		return jwtNow
+	}
+	return auth
+}
+
+func TestAuthenticator_Disabled(t *testing.T) {
+	auth := NewAuthenticator(nil, nil, false)
+	require.Nil(t, auth)
+	require.NoError(t, auth.Authenticate("", false))
+}
+
+func TestAuthenticator_APIKeys(t *testing.T) {
+	auth := newTestAuthenticator([]string{"key-one", "key-two"}, "", false)
+	require.NoError(t, auth.Authenticate("key-one", false))
+	require.NoError(t, auth.Authenticate("key-two", false))
+	require.IsType(t, &ErrUnauthenticated{}, auth.Authenticate("key-three", false))
+	require.IsType(t, &ErrUnauthenticated{}, auth.Authenticate("", false))
+	// certificates are only trusted if the server verifies them
+	require.IsType(t, &ErrUnauthenticated{}, auth.Authenticate("", true))
+}
+
+func TestAuthenticator_JWT(t *testing.T) {
+	auth := newTestAuthenticator(nil, "secret", false)
+	header := `{"alg":"HS256","typ":"JWT"}`
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"no claims", signJWT("secret", header, `{}`), true},
+		{"unexpired", signJWT("secret", header, `{"exp":1500000001,"nbf":1500000000}`), true},
+		{"expired", signJWT("secret", header, `{"exp":1500000000}`), false},
+		{"not yet valid", signJWT("secret", header, `{"nbf":1500000001}`), false},
+		{"wrong secret", signJWT("other", header, `{}`), false},
+		{"none algorithm", signJWT("secret", `{"alg":"none"}`, `{}`), false},
+		{"malformed", "not.a.jwt", false},
+		{"too few parts", "abc.def", false},
+	}
+
+	for _, tt := range tests {
+		err := auth.Authenticate(tt.token, false)
+		if tt.valid {
+			require.NoError(t, err, tt.name)
+		} else {
+			require.IsType(t, &ErrUnauthenticated{}, err, tt.name)
+		}
+	}
+}
+
+func TestAuthenticator_ClientCerts(t *testing.T) {
+	auth := newTestAuthenticator(nil, "", true)
+	require.NoError(t, auth.Authenticate("", true))
+	require.IsType(t, &ErrUnauthenticated{}, auth.Authenticate("", false))
+}
+
+func TestAuthenticator_AuthenticateRequest(t *testing.T) {
+	auth := newTestAuthenticator([]string{"key"}, "", true)
+
+	req, err := http.NewRequest(http.MethodPost, "/send", nil)
+	require.NoError(t, err)
+	require.Error(t, auth.AuthenticateRequest(req))
+	req.Header.Set("Authorization", "bearer key")
+	require.NoError(t, auth.AuthenticateRequest(req))
+
+	req.Header.Del("Authorization")
+	req.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}},
+	}
+	require.NoError(t, auth.AuthenticateRequest(req))
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	interceptor := AuthInterceptor(newTestAuthenticator([]string{"key"}, "", false))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+
+	// reads are never authenticated
+	res, err := interceptor(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/pb.Root/GetBalance"}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "req", res)
+
+	_, err = interceptor(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/pb.Root/Send"}, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer key"))
+	res, err = interceptor(ctx, "req", &grpc.UnaryServerInfo{FullMethod: "/pb.Root/Send"}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "req", res)
+}
+
+func TestAuthInterceptor_GatewayClientCert(t *testing.T) {
+	interceptor := AuthInterceptor(newTestAuthenticator(nil, "", true))
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.Root/Send"}
+	certMD := metadata.Pairs(verifiedCertKey, "true")
+
+	gatewayCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: gatewayAddr{},
+	})
+	_, err := interceptor(gatewayCtx, "req", info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+	res, err := interceptor(metadata.NewIncomingContext(gatewayCtx, certMD), "req", info, handler)
+	require.NoError(t, err)
+	require.Equal(t, "req", res)
+
+	// gRPC clients cannot vouch for themselves
+	tcpCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234},
+	})
+	_, err = interceptor(metadata.NewIncomingContext(tcpCtx, certMD), "req", info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,108 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// GatewayPrefix is the path prefix of the routes in root.proto's
+// google.api.http annotations.
+const GatewayPrefix = "/v1"
+
+// SwaggerPath is where a Gateway serves the Swagger spec of its routes.
+const SwaggerPath = GatewayPrefix + "/swagger.json"
+
+// gatewayNetwork is the network of a Gateway's connection to its gRPC
+// server.
+const gatewayNetwork = "bufconn"
+
+const gatewayBufferSize = 1024 * 1024
+
+// Gateway serves the Root service over HTTP/JSON, as mapped by the
+// google.api.http annotations in root.proto. It calls the node's gRPC
+// server over an in-memory connection, so gateway requests pass through
+// the same interceptors as gRPC calls.
+type Gateway struct {
+	lis  *bufconn.Listener
+	mux  *runtime.ServeMux
+	conn *grpc.ClientConn
+	// tls is true if the gRPC server serves TLS, on the gateway's
+	// listener too.
+	tls bool
+}
+
+func NewGateway(tls bool) *Gateway {
+	return &Gateway{
+		tls: tls,
+		lis: bufconn.Listen(gatewayBufferSize),
+		mux: runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			OrigName:     true,
+			EmitDefaults: true,
+		}), runtime.WithMetadata(gatewayMetadata)),
+	}
+}
+
+// Listener returns the listener the gRPC server must serve on, in addition
+// to its TCP listener, for the gateway to reach it.
+func (g *Gateway) Listener() net.Listener {
+	return g.lis
+}
+
+func (g *Gateway) Start() error {
+	creds := grpc.WithInsecure()
+	if g.tls {
+		// the connection never leaves the process, so there is nothing
+		// to verify the server against
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			InsecureSkipVerify: true,
+		}))
+	}
+	conn, err := grpc.Dial(gatewayNetwork, creds, grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+		return g.lis.Dial()
+	}))
+	if err != nil {
+		return err
+	}
+	if err := pb.RegisterRootHandler(context.Background(), g.mux, conn); err != nil {
+		conn.Close()
+		return err
+	}
+	g.conn = conn
+	return nil
+}
+
+func (g *Gateway) Stop() error {
+	return g.conn.Close()
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == SwaggerPath && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, pb.SwaggerJSON)
+		return
+	}
+	// the gateway forwards Grpc-Metadata-* headers as metadata, so clients
+	// could otherwise claim a certificate they did not present
+	r.Header.Del(runtime.MetadataHeaderPrefix + verifiedCertKey)
+	g.mux.ServeHTTP(w, r)
+}
+
+// gatewayMetadata passes on to the gRPC server whether the HTTP client
+// presented a verified certificate, since the server cannot see the
+// client's TLS connection.
+func gatewayMetadata(ctx context.Context, r *http.Request) metadata.MD {
+	if !requestHasVerifiedCert(r) {
+		return nil
+	}
+	return metadata.Pairs(verifiedCertKey, "true")
+}
@@ -0,0 +1,43 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway_ServesSwagger(t *testing.T) {
+	gateway := NewGateway(false)
+
+	res := httptest.NewRecorder()
+	gateway.ServeHTTP(res, httptest.NewRequest(http.MethodGet, SwaggerPath, nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	require.Equal(t, pb.SwaggerJSON, res.Body.String())
+}
+
+func TestGateway_ForwardsVerifiedCert(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, GatewayPrefix+"/transactions", nil)
+	require.Nil(t, gatewayMetadata(context.Background(), req))
+
+	req.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}},
+	}
+	require.Equal(t, []string{"true"}, gatewayMetadata(context.Background(), req).Get(verifiedCertKey))
+}
+
+func TestGateway_StripsForgedVerifiedCert(t *testing.T) {
+	gateway := NewGateway(false)
+
+	req := httptest.NewRequest(http.MethodPost, GatewayPrefix+"/transactions", nil)
+	req.Header.Set("grpc-metadata-x-plasma-verified-cert", "true")
+	gateway.ServeHTTP(httptest.NewRecorder(), req)
+	require.Empty(t, req.Header.Get(runtime.MetadataHeaderPrefix+verifiedCertKey))
+}
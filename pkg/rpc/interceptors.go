@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strings"
+)
+
+// ChainUnaryServer combines interceptors into one, since a gRPC server
+// accepts only one. The first interceptor is outermost.
+func ChainUnaryServer(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor := interceptors[i]
+			wrapped := next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, wrapped)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// PeerHost returns the IP address of the client that made the call in ctx,
+// or an empty string if it is unknown. Calls made by a Gateway are
+// attributed to the HTTP client it forwarded.
+func PeerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if p.Addr.Network() == gatewayNetwork {
+		return forwardedFor(ctx)
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// forwardedFor returns the last address in the X-Forwarded-For metadata of
+// ctx, which the gateway sets to the address of the HTTP client.
+func forwardedFor(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("x-forwarded-for")
+	if len(values) == 0 {
+		return ""
+	}
+	hosts := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(hosts[len(hosts)-1])
+}
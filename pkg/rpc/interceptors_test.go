@@ -0,0 +1,61 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type gatewayAddr struct{}
+
+func (gatewayAddr) Network() string {
+	return gatewayNetwork
+}
+
+func (gatewayAddr) String() string {
+	return gatewayNetwork
+}
+
+func TestChainUnaryServer(t *testing.T) {
+	var calls []string
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			return handler(ctx, req)
+		}
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return req, nil
+	}
+
+	chained := ChainUnaryServer(interceptor("outer"), interceptor("inner"))
+	res, err := chained(context.Background(), "req", &grpc.UnaryServerInfo{}, handler)
+	require.NoError(t, err)
+	require.Equal(t, "req", res)
+	require.Equal(t, []string{"outer", "inner", "handler"}, calls)
+}
+
+func TestPeerHost(t *testing.T) {
+	require.Equal(t, "", PeerHost(context.Background()))
+
+	tcpCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234},
+	})
+	require.Equal(t, "10.0.0.1", PeerHost(tcpCtx))
+
+	// clients can set X-Forwarded-For, so it is only trusted from the gateway
+	spoofed := metadata.NewIncomingContext(tcpCtx, metadata.Pairs("x-forwarded-for", "10.0.0.2"))
+	require.Equal(t, "10.0.0.1", PeerHost(spoofed))
+
+	gatewayCtx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: gatewayAddr{},
+	})
+	gatewayCtx = metadata.NewIncomingContext(gatewayCtx, metadata.Pairs("x-forwarded-for", "10.0.0.2, 10.0.0.3"))
+	require.Equal(t, "10.0.0.3", PeerHost(gatewayCtx))
+}
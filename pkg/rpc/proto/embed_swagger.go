@@ -0,0 +1,43 @@
+// +build ignore
+
+// embed_swagger writes the Swagger spec generated by protoc-gen-swagger to
+// a Go file in package pb, so that the gateway can serve it without
+// reading it from disk. It is run by make protogen:
+//
+//	go run pkg/rpc/proto/embed_swagger.go <in.swagger.json> <out.go>
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+const template = `// Code generated by embed_swagger.go. DO NOT EDIT.
+
+package pb
+
+// SwaggerJSON is the Swagger spec of the Root service's HTTP/JSON
+// gateway, generated from root.proto.
+const SwaggerJSON = %s
+`
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: embed_swagger <in.swagger.json> <out.go>")
+		os.Exit(1)
+	}
+
+	spec, err := ioutil.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	out := fmt.Sprintf(template, strconv.Quote(string(bytes.TrimSpace(spec))))
+	if err := ioutil.WriteFile(os.Args[2], []byte(out), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
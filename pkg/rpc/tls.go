@@ -0,0 +1,138 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"net"
+	"strconv"
+)
+
+// ServerConfig is where and how one of a node's servers listens.
+type ServerConfig struct {
+	Host string
+	Port int
+	// TLS is nil to listen in plaintext.
+	TLS *tls.Config
+}
+
+func (c ServerConfig) Addr() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// ServerTLSConfig loads the certificate a node's servers present, or
+// returns nil if certFile is empty. If clientCAFile is set, clients may
+// present certificates signed by it, which authenticate them for write
+// endpoints.
+func ServerTLSConfig(certFile string, keyFile string, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" {
+		if keyFile != "" || clientCAFile != "" {
+			return nil, errors.New("a TLS key or client CA requires a TLS certificate")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// ClientOptions configures a client's connection to a node's gRPC server.
+type ClientOptions struct {
+	// TLS connects over TLS. It is implied by the other TLS options.
+	TLS bool
+	// CACertFile verifies the server's certificate instead of the system's
+	// root certificates.
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are presented to servers that
+	// authenticate clients by certificate.
+	ClientCertFile string
+	ClientKeyFile  string
+	// Token is an API key or JWT sent with every call. It is only sent
+	// over TLS.
+	Token string
+}
+
+func (o ClientOptions) tlsEnabled() bool {
+	return o.TLS || o.CACertFile != "" || o.ClientCertFile != ""
+}
+
+// Dial connects to the gRPC server at url.
+func Dial(url string, opts ClientOptions) (*grpc.ClientConn, error) {
+	var dialOpts []grpc.DialOption
+	if opts.tlsEnabled() {
+		config := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}
+		if opts.CACertFile != "" {
+			pool, err := loadCertPool(opts.CACertFile)
+			if err != nil {
+				return nil, err
+			}
+			config.RootCAs = pool
+		}
+		if opts.ClientCertFile != "" {
+			cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	}
+	if opts.Token != "" {
+		if !opts.tlsEnabled() {
+			return nil, errors.New("auth tokens are only sent over TLS")
+		}
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(bearerCredentials(opts.Token)))
+	}
+
+	return grpc.Dial(url, dialOpts...)
+}
+
+// bearerCredentials sends a token in the authorization metadata, which
+// the gateway also fills from the Authorization header.
+type bearerCredentials string
+
+func (b bearerCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		authorizationKey: bearerPrefix + string(b),
+	}, nil
+}
+
+func (b bearerCredentials) RequireTransportSecurity() bool {
+	return true
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
@@ -8,12 +8,14 @@ import (
 	"github.com/kyokan/plasma/pkg/log"
 	"github.com/sirupsen/logrus"
 	"github.com/kyokan/plasma/util"
-	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/kyokan/plasma/pkg/chain"
+	"time"
 )
 
 var bsLogger = log.ForSubsystem("BlockSubmitter")
 
+const submitterDrainInterval = time.Second
+
 type BlockSubmitter struct {
 	submissions  []chain.BlockResult
 	awakeDequeue chan bool
@@ -43,7 +45,7 @@ func NewBlockSubmitter(client eth.Client, ps db.Storage) *BlockSubmitter {
 
 func (s *BlockSubmitter) Start() error {
 	lastSubmitted, err := s.ps.LastSubmittedBlock()
-	if err == leveldb.ErrNotFound {
+	if err == db.ErrNotFound {
 		return nil
 	}
 	latest, err := s.ps.LatestBlock()
@@ -80,8 +82,33 @@ func (s *BlockSubmitter) Start() error {
 	return nil
 }
 
+// Stop blocks until every enqueued block has been submitted. Failed
+// submissions are retried while draining; the caller is expected to bound
+// how long it is willing to wait.
 func (s *BlockSubmitter) Stop() error {
-	return nil
+	ticker := time.NewTicker(submitterDrainInterval)
+	defer ticker.Stop()
+
+	for {
+		pending := s.PendingSubmissions()
+		if pending == 0 && atomic.LoadUint32(&s.isBusy) == 0 {
+			return nil
+		}
+
+		bsLogger.WithFields(logrus.Fields{
+			"pending": pending,
+		}).Info("waiting for pending block submissions")
+		if atomic.LoadUint32(&s.isBusy) == 0 {
+			s.awakeDequeue <- true
+		}
+		<-ticker.C
+	}
+}
+
+func (s *BlockSubmitter) PendingSubmissions() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.submissions)
 }
 
 func (s *BlockSubmitter) Enqueue(res chain.BlockResult) {
@@ -5,7 +5,6 @@ import (
 	"github.com/kyokan/plasma/pkg/eth"
 	"github.com/kyokan/plasma/pkg/log"
 	"github.com/kyokan/plasma/pkg/merkle"
-	"github.com/kyokan/plasma/util"
 	"github.com/sirupsen/logrus"
 	"sync/atomic"
 	"time"
@@ -112,16 +111,13 @@ func (e *ExitStrategizer) doExit() {
 	}
 
 	for _, utxo := range utxos {
-		txs, err := e.storage.FindTransactionsByBlockNum(utxo.Transaction.Body.BlockNumber)
+		// hashes remain available for pruned transactions
+		leaves, err := e.storage.TransactionHashesByBlockNum(utxo.Transaction.Body.BlockNumber)
 		if err != nil {
 			log.WithError(exitStratLogger, err).Error("couldn't find transactions inside block while exiting transaction")
 			continue
 		}
 
-		leaves := make([]util.Hash, len(txs), len(txs))
-		for i, tx := range txs {
-			leaves[i] = tx.Transaction.RLPHash(util.Sha256)
-		}
 		_, proof := merkle.RootAndProof(leaves, int64(utxo.Transaction.Body.TransactionIndex))
 
 		indices := utxo.Transaction.Body.OutputIndicesFor(&addr)
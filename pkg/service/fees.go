@@ -0,0 +1,209 @@
+package service
+
+import (
+	"fmt"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"math/big"
+	"sort"
+)
+
+// FeeHistoryBlocks is the number of recent blocks whose fees are sampled
+// when estimating a fee.
+const FeeHistoryBlocks = 20
+
+// FeeReport summarizes the fees the operator has collected. Accumulated
+// fees include blocks that have not been submitted yet; committed fees only
+// include blocks that have. Exited fees have had a fee exit started, and
+// withdrawable fees have finalized and can be withdrawn from the contract.
+type FeeReport struct {
+	Accumulated        *big.Int
+	Committed          *big.Int
+	Exited             *big.Int
+	Withdrawable       *big.Int
+	LastSubmittedBlock uint64
+}
+
+func (f *FeeReport) Proto() *pb.GetFeeReportResponse {
+	return &pb.GetFeeReportResponse{
+		Accumulated:        rpc.SerializeBig(f.Accumulated),
+		Committed:          rpc.SerializeBig(f.Committed),
+		Exited:             rpc.SerializeBig(f.Exited),
+		Withdrawable:       rpc.SerializeBig(f.Withdrawable),
+		LastSubmittedBlock: f.LastSubmittedBlock,
+	}
+}
+
+type ErrFeeMismatch struct {
+	BlockNumber uint64
+	Ledger      *big.Int
+	Contract    *big.Int
+}
+
+func NewErrFeeMismatch(blockNumber uint64, ledger *big.Int, contract *big.Int) error {
+	return &ErrFeeMismatch{
+		BlockNumber: blockNumber,
+		Ledger:      ledger,
+		Contract:    contract,
+	}
+}
+
+func (e *ErrFeeMismatch) Error() string {
+	return fmt.Sprintf("block %d collected %s in fees, but the contract committed %s", e.BlockNumber, e.Ledger.Text(10), e.Contract.Text(10))
+}
+
+// NewFeeReport totals the fee ledger and looks up the operator's
+// withdrawable balance in the contract.
+func NewFeeReport(storage db.Storage, client eth.Client) (*FeeReport, error) {
+	ledger, err := storage.FeeLedger()
+	if err != nil {
+		return nil, err
+	}
+	lastSubmitted, err := storage.LastSubmittedBlock()
+	if err != nil {
+		return nil, err
+	}
+	withdrawable, err := client.WithdrawableBalance(client.UserAddress())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FeeReport{
+		Accumulated:        big.NewInt(0),
+		Committed:          big.NewInt(0),
+		Exited:             big.NewInt(0),
+		Withdrawable:       withdrawable,
+		LastSubmittedBlock: lastSubmitted,
+	}
+	for _, entry := range ledger {
+		report.Accumulated.Add(report.Accumulated, entry.Amount)
+		if entry.BlockNumber <= lastSubmitted {
+			report.Committed.Add(report.Committed, entry.Amount)
+		}
+		if entry.Exited() {
+			report.Exited.Add(report.Exited, entry.Amount)
+		}
+	}
+	return report, nil
+}
+
+// ExitableFees returns the ledger entries for submitted blocks that have
+// not been exited yet, after checking that the contract committed the same
+// fees for each of them. Nothing is returned if any block does not match.
+func ExitableFees(storage db.Storage, client eth.Client) ([]db.FeeEntry, error) {
+	ledger, err := storage.FeeLedger()
+	if err != nil {
+		return nil, err
+	}
+	lastSubmitted, err := storage.LastSubmittedBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []db.FeeEntry
+	for _, entry := range ledger {
+		if entry.BlockNumber > lastSubmitted || entry.Exited() {
+			continue
+		}
+
+		block, err := client.LookupBlock(entry.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if block.FeeAmount.Cmp(entry.Amount) != 0 {
+			return nil, NewErrFeeMismatch(entry.BlockNumber, entry.Amount, block.FeeAmount)
+		}
+		ret = append(ret, entry)
+	}
+	return ret, nil
+}
+
+// FeeEstimate is the fee recommended for a new transaction. BaseFee is the
+// median fee per transaction paid in recent blocks that collected fees; Fee
+// raises it in proportion to how full the mempool is, up to twice BaseFee
+// when the mempool is full, and is never below the mempool's minimum fee.
+type FeeEstimate struct {
+	Fee             *big.Int
+	BaseFee         *big.Int
+	MempoolSize     int
+	MempoolCapacity int
+}
+
+func (f *FeeEstimate) Proto() *pb.EstimateFeeResponse {
+	return &pb.EstimateFeeResponse{
+		Fee:             rpc.SerializeBig(f.Fee),
+		BaseFee:         rpc.SerializeBig(f.BaseFee),
+		MempoolSize:     uint32(f.MempoolSize),
+		MempoolCapacity: uint32(f.MempoolCapacity),
+	}
+}
+
+// EstimateFee recommends a fee from the fees paid in the last
+// FeeHistoryBlocks blocks and the current size of mpool.
+func EstimateFee(storage db.Storage, mpool *Mempool) (*FeeEstimate, error) {
+	latest, err := storage.LatestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*chain.BlockMetadata
+	if latest != nil {
+		for num := latest.Header.Number; num > 0 && latest.Header.Number-num < FeeHistoryBlocks; num-- {
+			meta, err := storage.BlockMetaAtHeight(num)
+			if err != nil {
+				return nil, err
+			}
+			history = append(history, meta)
+		}
+	}
+
+	size := mpool.Size()
+	baseFee := medianFeePerTransaction(history)
+	fee := scaleFee(baseFee, size, MaxMempoolSize)
+	if minFee := mpool.MinFee(); minFee != nil && fee.Cmp(minFee) < 0 {
+		fee = new(big.Int).Set(minFee)
+	}
+	return &FeeEstimate{
+		Fee:             fee,
+		BaseFee:         baseFee,
+		MempoolSize:     size,
+		MempoolCapacity: MaxMempoolSize,
+	}, nil
+}
+
+// medianFeePerTransaction returns the median of the average fee per
+// transaction in each block that collected fees, or zero if none did.
+// Blocks without fees, such as deposit blocks, are skipped.
+func medianFeePerTransaction(history []*chain.BlockMetadata) *big.Int {
+	var fees []*big.Int
+	for _, meta := range history {
+		if meta.Fees == nil || meta.Fees.Sign() == 0 || meta.TransactionCount == 0 {
+			continue
+		}
+		fees = append(fees, new(big.Int).Div(meta.Fees, big.NewInt(int64(meta.TransactionCount))))
+	}
+	if len(fees) == 0 {
+		return big.NewInt(0)
+	}
+
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].Cmp(fees[j]) < 0
+	})
+	mid := len(fees) / 2
+	if len(fees)%2 == 1 {
+		return fees[mid]
+	}
+	median := new(big.Int).Add(fees[mid-1], fees[mid])
+	return median.Div(median, big.NewInt(2))
+}
+
+// scaleFee raises baseFee by the fraction of the mempool's capacity that
+// is in use.
+func scaleFee(baseFee *big.Int, size int, capacity int) *big.Int {
+	surcharge := new(big.Int).Mul(baseFee, big.NewInt(int64(size)))
+	surcharge.Div(surcharge, big.NewInt(int64(capacity)))
+	return surcharge.Add(surcharge, baseFee)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/stretchr/testify/require"
+)
+
+func feeMeta(txCount uint32, fees int64) *chain.BlockMetadata {
+	return &chain.BlockMetadata{
+		TransactionCount: txCount,
+		Fees:             big.NewInt(fees),
+	}
+}
+
+func TestMedianFeePerTransaction(t *testing.T) {
+	require.Equal(t, int64(0), medianFeePerTransaction(nil).Int64())
+	// deposit blocks do not pull the median down
+	require.Equal(t, int64(0), medianFeePerTransaction([]*chain.BlockMetadata{
+		feeMeta(1, 0),
+		feeMeta(1, 0),
+	}).Int64())
+
+	require.Equal(t, int64(10), medianFeePerTransaction([]*chain.BlockMetadata{
+		feeMeta(1, 0),
+		feeMeta(2, 20),
+		feeMeta(4, 100),
+		feeMeta(3, 15),
+	}).Int64())
+	require.Equal(t, int64(17), medianFeePerTransaction([]*chain.BlockMetadata{
+		feeMeta(4, 100),
+		feeMeta(1, 0),
+		feeMeta(2, 20),
+	}).Int64())
+}
+
+func TestScaleFee(t *testing.T) {
+	require.Equal(t, int64(100), scaleFee(big.NewInt(100), 0, MaxMempoolSize).Int64())
+	require.Equal(t, int64(150), scaleFee(big.NewInt(100), MaxMempoolSize/2, MaxMempoolSize).Int64())
+	require.Equal(t, int64(200), scaleFee(big.NewInt(100), MaxMempoolSize, MaxMempoolSize).Int64())
+	require.Equal(t, int64(0), scaleFee(big.NewInt(0), MaxMempoolSize, MaxMempoolSize).Int64())
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const healthCheckInterval = 5 * time.Second
+
+const (
+	// MaxEthereumLag is the number of Ethereum blocks the chainsaw's exit
+	// poller may fall behind the Ethereum head before the node is not ready.
+	MaxEthereumLag = 50
+	// MaxSubmitterBacklog is the number of packaged blocks that may be
+	// waiting for submission to the Plasma contract.
+	MaxSubmitterBacklog = 10
+	// MaxSyncLag is the number of Plasma blocks a validator may trail the
+	// root node by.
+	MaxSyncLag = 10
+)
+
+var healthLogger = log.ForSubsystem("HealthChecker")
+
+type HealthCheckFunc func() (map[string]interface{}, error)
+
+type HealthCheck struct {
+	Name string
+	// Liveness checks are run on every liveness probe. Every check,
+	// liveness or not, counts towards readiness.
+	Liveness bool
+	Check    HealthCheckFunc
+}
+
+type HealthCheckResult struct {
+	Name    string                 `json:"name"`
+	Healthy bool                   `json:"healthy"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type HealthReport struct {
+	Healthy   bool                `json:"healthy"`
+	CheckedAt time.Time           `json:"checkedAt"`
+	Checks    []HealthCheckResult `json:"checks"`
+}
+
+// HealthChecker periodically runs a set of readiness checks and caches the
+// result, so that probes do not hit the Ethereum node or root node on every
+// request.
+type HealthChecker struct {
+	checks    []HealthCheck
+	listeners []func(HealthReport)
+	report    HealthReport
+	mtx       sync.RWMutex
+	quit      chan bool
+}
+
+func NewHealthChecker() *HealthChecker {
+	return &HealthChecker{
+		quit: make(chan bool),
+	}
+}
+
+func (h *HealthChecker) Register(check HealthCheck) {
+	h.checks = append(h.checks, check)
+}
+
+// OnUpdate registers a listener that is called after every readiness
+// evaluation.
+func (h *HealthChecker) OnUpdate(listener func(HealthReport)) {
+	h.listeners = append(h.listeners, listener)
+}
+
+func (h *HealthChecker) Start() error {
+	h.evaluate()
+
+	go func() {
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				h.evaluate()
+			case <-h.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (h *HealthChecker) Stop() error {
+	h.quit <- true
+	h.setReport(HealthReport{
+		Healthy:   false,
+		CheckedAt: time.Now(),
+	})
+	return nil
+}
+
+// Liveness runs all liveness checks synchronously.
+func (h *HealthChecker) Liveness() HealthReport {
+	var checks []HealthCheck
+	for _, check := range h.checks {
+		if check.Liveness {
+			checks = append(checks, check)
+		}
+	}
+	return runChecks(checks)
+}
+
+// Readiness returns the result of the most recent evaluation of all checks.
+func (h *HealthChecker) Readiness() HealthReport {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.report
+}
+
+func (h *HealthChecker) evaluate() {
+	report := runChecks(h.checks)
+	if !report.Healthy {
+		for _, res := range report.Checks {
+			if res.Healthy {
+				continue
+			}
+			healthLogger.WithFields(logrus.Fields{
+				"check": res.Name,
+				"err":   res.Error,
+			}).Warn("health check failed")
+		}
+	}
+	h.setReport(report)
+}
+
+func (h *HealthChecker) setReport(report HealthReport) {
+	h.mtx.Lock()
+	h.report = report
+	h.mtx.Unlock()
+
+	for _, listener := range h.listeners {
+		listener(report)
+	}
+}
+
+func runChecks(checks []HealthCheck) HealthReport {
+	report := HealthReport{
+		Healthy:   true,
+		CheckedAt: time.Now(),
+		Checks:    make([]HealthCheckResult, len(checks)),
+	}
+
+	for i, check := range checks {
+		details, err := check.Check()
+		res := HealthCheckResult{
+			Name:    check.Name,
+			Healthy: err == nil,
+			Details: details,
+		}
+		if err != nil {
+			res.Error = err.Error()
+			report.Healthy = false
+		}
+		report.Checks[i] = res
+	}
+
+	return report
+}
+
+func NewStorageHealthCheck(storage db.Storage) HealthCheck {
+	return HealthCheck{
+		Name:     "storage",
+		Liveness: true,
+		Check: func() (map[string]interface{}, error) {
+			latest, err := storage.LatestBlock()
+			if err != nil {
+				return nil, err
+			}
+			var height uint64
+			if latest != nil {
+				height = latest.Header.Number
+			}
+			return map[string]interface{}{
+				"blockHeight": height,
+			}, nil
+		},
+	}
+}
+
+// NewEthereumHealthCheck checks that the Ethereum node is reachable and
+// that the exit poller persisted in storage is keeping up with it.
+func NewEthereumHealthCheck(client eth.Client, storage db.Storage) HealthCheck {
+	return HealthCheck{
+		Name: "ethereum",
+		Check: func() (map[string]interface{}, error) {
+			head, err := client.EthereumBlockHeight()
+			if err != nil {
+				return nil, err
+			}
+			lastPoll, err := storage.LastTxExitPoll()
+			if err != nil {
+				return nil, err
+			}
+			var lag uint64
+			if head > lastPoll {
+				lag = head - lastPoll
+			}
+			details := map[string]interface{}{
+				"blockHeight": head,
+				"lastPoll":    lastPoll,
+				"lag":         lag,
+			}
+			if lag > MaxEthereumLag {
+				return details, fmt.Errorf("exit poller is %d blocks behind Ethereum", lag)
+			}
+			return details, nil
+		},
+	}
+}
+
+// NewEthereumConnectivityCheck checks that the Ethereum node is reachable.
+// It is used by nodes that do not poll Ethereum for exits.
+func NewEthereumConnectivityCheck(client eth.Client) HealthCheck {
+	return HealthCheck{
+		Name: "ethereum",
+		Check: func() (map[string]interface{}, error) {
+			head, err := client.EthereumBlockHeight()
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{
+				"blockHeight": head,
+			}, nil
+		},
+	}
+}
+
+func NewBlockSubmitterHealthCheck(submitter *BlockSubmitter) HealthCheck {
+	return HealthCheck{
+		Name: "blockSubmitter",
+		Check: func() (map[string]interface{}, error) {
+			pending := submitter.PendingSubmissions()
+			details := map[string]interface{}{
+				"pending": pending,
+			}
+			if pending > MaxSubmitterBacklog {
+				return details, fmt.Errorf("%d blocks are waiting to be submitted", pending)
+			}
+			return details, nil
+		},
+	}
+}
+
+func NewSyncHealthCheck(storage db.Storage, rootClient pb.RootClient) HealthCheck {
+	return HealthCheck{
+		Name: "sync",
+		Check: func() (map[string]interface{}, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			res, err := rootClient.BlockHeight(ctx, &pb.EmptyRequest{})
+			if err != nil {
+				return nil, err
+			}
+			latest, err := storage.LatestBlock()
+			if err != nil {
+				return nil, err
+			}
+			var height uint64
+			if latest != nil {
+				height = latest.Header.Number
+			}
+			var lag uint64
+			if res.Height > height {
+				lag = res.Height - height
+			}
+			details := map[string]interface{}{
+				"rootHeight": res.Height,
+				"height":     height,
+				"lag":        lag,
+			}
+			if lag > MaxSyncLag {
+				return details, fmt.Errorf("validator is %d blocks behind root", lag)
+			}
+			return details, nil
+		},
+	}
+}
+
+func NewCircuitBreakerHealthCheck(name string, breaker CircuitBreaker) HealthCheck {
+	return HealthCheck{
+		Name: name,
+		Check: func() (map[string]interface{}, error) {
+			tripped := breaker.Tripped()
+			details := map[string]interface{}{
+				"tripped": tripped,
+			}
+			if tripped {
+				return details, fmt.Errorf("%s is tripped", name)
+			}
+			return details, nil
+		},
+	}
+}
+
+// LivenessHandler serves the liveness report, responding with 503 if any
+// liveness check fails.
+func (h *HealthChecker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.Liveness())
+	})
+}
+
+// ReadinessHandler serves the cached readiness report, responding with 503
+// if the node should not receive traffic.
+func (h *HealthChecker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, h.Readiness())
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.WithError(healthLogger, err).Error("failed to write health report")
+	}
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthChecker_Readiness(t *testing.T) {
+	breaker := NewCircuitBreaker("TestBreaker")
+	checker := NewHealthChecker()
+	checker.Register(HealthCheck{
+		Name:     "live",
+		Liveness: true,
+		Check: func() (map[string]interface{}, error) {
+			return nil, nil
+		},
+	})
+	checker.Register(NewCircuitBreakerHealthCheck("breaker", breaker))
+
+	var reports []HealthReport
+	checker.OnUpdate(func(report HealthReport) {
+		reports = append(reports, report)
+	})
+
+	checker.evaluate()
+	require.True(t, checker.Readiness().Healthy)
+
+	breaker.Trip()
+	checker.evaluate()
+	report := checker.Readiness()
+	require.False(t, report.Healthy)
+	require.True(t, report.Checks[0].Healthy)
+	require.False(t, report.Checks[1].Healthy)
+	require.Equal(t, true, report.Checks[1].Details["tripped"])
+	require.Len(t, reports, 2)
+
+	// liveness only considers liveness checks
+	require.True(t, checker.Liveness().Healthy)
+}
+
+func TestHealthChecker_Handlers(t *testing.T) {
+	checker := NewHealthChecker()
+	checker.Register(HealthCheck{
+		Name: "failing",
+		Check: func() (map[string]interface{}, error) {
+			return nil, errors.New("unavailable")
+		},
+	})
+	checker.evaluate()
+
+	rec := httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var report HealthReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.False(t, report.Healthy)
+	require.Equal(t, "unavailable", report.Checks[0].Error)
+
+	rec = httptest.NewRecorder()
+	checker.LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"fmt"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const DefaultShutdownTimeout = 30 * time.Second
+
+var lifecycleLogger = log.ForSubsystem("Lifecycle")
+
+type ErrShutdownTimeout struct {
+	Service string
+}
+
+func NewErrShutdownTimeout(service string) error {
+	return &ErrShutdownTimeout{
+		Service: service,
+	}
+}
+
+func (e *ErrShutdownTimeout) Error() string {
+	return fmt.Sprintf("timed out waiting for %s to stop", e.Service)
+}
+
+type namedService struct {
+	name    string
+	service Service
+}
+
+// Lifecycle starts services in the order they were registered and stops
+// them in reverse order, so that services are only stopped once everything
+// depending on them has stopped.
+type Lifecycle struct {
+	services []namedService
+	started  []namedService
+	timeout  time.Duration
+}
+
+func NewLifecycle(timeout time.Duration) *Lifecycle {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+
+	return &Lifecycle{
+		timeout: timeout,
+	}
+}
+
+func (l *Lifecycle) Register(name string, service Service) {
+	l.services = append(l.services, namedService{
+		name:    name,
+		service: service,
+	})
+}
+
+func (l *Lifecycle) Start() error {
+	for _, s := range l.services {
+		lgr := lifecycleLogger.WithFields(logrus.Fields{
+			"service": s.name,
+		})
+		if err := s.service.Start(); err != nil {
+			log.WithError(lgr, err).Error("failed to start service, stopping started services")
+			if stopErr := l.Stop(); stopErr != nil {
+				log.WithError(lgr, stopErr).Error("failed to stop services cleanly")
+			}
+			return errors.Wrapf(err, "failed to start %s", s.name)
+		}
+		l.started = append(l.started, s)
+		lgr.Info("started service")
+	}
+
+	return nil
+}
+
+// Stop stops every started service in reverse order. All services share a
+// single shutdown deadline. A service that does not stop in time may still
+// be using the services registered before it, so those (like storage) are
+// left open rather than closed underneath it.
+func (l *Lifecycle) Stop() error {
+	deadline := time.Now().Add(l.timeout)
+	var firstErr error
+	for i := len(l.started) - 1; i >= 0; i-- {
+		s := l.started[i]
+		lgr := lifecycleLogger.WithFields(logrus.Fields{
+			"service": s.name,
+		})
+		lgr.Info("stopping service")
+		err := stopBefore(s, deadline)
+		if err == nil {
+			lgr.Info("stopped service")
+			continue
+		}
+		log.WithError(lgr, err).Error("failed to stop service")
+		if firstErr == nil {
+			firstErr = err
+		}
+		if _, ok := err.(*ErrShutdownTimeout); ok {
+			for _, skipped := range l.started[:i] {
+				lifecycleLogger.WithFields(logrus.Fields{
+					"service": skipped.name,
+				}).Warn("not stopping service, since a service that depends on it is still running")
+			}
+			break
+		}
+	}
+	l.started = nil
+	return firstErr
+}
+
+// Run starts all services, blocks until SIGINT or SIGTERM is received, and
+// then stops all services. A second signal aborts the process immediately.
+func (l *Lifecycle) Run() error {
+	if err := l.Start(); err != nil {
+		return err
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	sig := <-sigs
+	lifecycleLogger.WithFields(logrus.Fields{
+		"signal":  sig.String(),
+		"timeout": l.timeout.String(),
+	}).Info("received signal, stopping services")
+
+	go func() {
+		<-sigs
+		lifecycleLogger.Warn("received second signal, exiting immediately")
+		os.Exit(1)
+	}()
+
+	return l.Stop()
+}
+
+func stopBefore(s namedService, deadline time.Time) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.service.Stop()
+	}()
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return NewErrShutdownTimeout(s.name)
+	}
+}
+
+type closerService struct {
+	closer io.Closer
+}
+
+// NewCloserService wraps an io.Closer (like a database handle) so that it
+// can be closed as part of a Lifecycle.
+func NewCloserService(closer io.Closer) Service {
+	return &closerService{
+		closer: closer,
+	}
+}
+
+func (c *closerService) Start() error {
+	return nil
+}
+
+func (c *closerService) Stop() error {
+	return c.closer.Close()
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingService struct {
+	name     string
+	events   *[]string
+	startErr error
+	stopWait time.Duration
+}
+
+func (r *recordingService) Start() error {
+	if r.startErr != nil {
+		return r.startErr
+	}
+	*r.events = append(*r.events, "start:"+r.name)
+	return nil
+}
+
+func (r *recordingService) Stop() error {
+	time.Sleep(r.stopWait)
+	*r.events = append(*r.events, "stop:"+r.name)
+	return nil
+}
+
+func TestLifecycle_StartsInOrderStopsInReverse(t *testing.T) {
+	var events []string
+	l := NewLifecycle(time.Second)
+	l.Register("a", &recordingService{name: "a", events: &events})
+	l.Register("b", &recordingService{name: "b", events: &events})
+	l.Register("c", &recordingService{name: "c", events: &events})
+
+	require.NoError(t, l.Start())
+	require.NoError(t, l.Stop())
+	require.Equal(t, []string{
+		"start:a",
+		"start:b",
+		"start:c",
+		"stop:c",
+		"stop:b",
+		"stop:a",
+	}, events)
+}
+
+func TestLifecycle_StartFailureStopsStartedServices(t *testing.T) {
+	var events []string
+	l := NewLifecycle(time.Second)
+	l.Register("a", &recordingService{name: "a", events: &events})
+	l.Register("b", &recordingService{name: "b", events: &events, startErr: errors.New("boom")})
+	l.Register("c", &recordingService{name: "c", events: &events})
+
+	err := l.Start()
+	require.Error(t, err)
+	require.Equal(t, []string{
+		"start:a",
+		"stop:a",
+	}, events)
+}
+
+func TestLifecycle_StopTimeout(t *testing.T) {
+	var storageEvents []string
+	var serverEvents []string
+	hang := make(chan struct{})
+	defer close(hang)
+	l := NewLifecycle(50 * time.Millisecond)
+	l.Register("storage", &recordingService{name: "storage", events: &storageEvents})
+	l.Register("hanging", &hangingService{release: hang})
+	l.Register("server", &recordingService{name: "server", events: &serverEvents})
+
+	require.NoError(t, l.Start())
+	err := l.Stop()
+	require.Error(t, err)
+	require.IsType(t, &ErrShutdownTimeout{}, err)
+	require.Equal(t, "hanging", err.(*ErrShutdownTimeout).Service)
+	require.Equal(t, []string{"start:server", "stop:server"}, serverEvents)
+	// storage may still be in use by the hanging service
+	require.Equal(t, []string{"start:storage"}, storageEvents)
+}
+
+type hangingService struct {
+	release chan struct{}
+}
+
+func (h *hangingService) Start() error {
+	return nil
+}
+
+func (h *hangingService) Stop() error {
+	<-h.release
+	return nil
+}
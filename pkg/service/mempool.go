@@ -10,12 +10,18 @@ import (
 	"github.com/kyokan/plasma/util"
 	"github.com/kyokan/plasma/pkg/db"
 	"github.com/kyokan/plasma/pkg/validation"
+	"math/big"
 )
 
 const MaxMempoolSize = 65534
 
 var mPoolLogger = log.ForSubsystem("Mempool")
 
+var (
+	ErrMempoolFull     = errors.New("mempool is full")
+	ErrMempoolStopping = errors.New("mempool is shutting down")
+)
+
 type MempoolTx struct {
 	Tx       chain.Transaction
 	Response chan TxInclusionResponse
@@ -32,10 +38,13 @@ type Mempool struct {
 	txReqs     chan *txRequest
 	quit       chan bool
 	flushReq   chan flushSpendReq
+	sizeReq    chan chan int
 	txPool     []MempoolTx
 	poolSpends map[string]bool
 	storage    db.Storage
 	client     eth.Client
+	policy     *validation.SignaturePolicy
+	minFee     *big.Int
 }
 
 type txRequest struct {
@@ -48,15 +57,21 @@ type flushSpendReq struct {
 	done chan bool
 }
 
-func NewMempool(storage db.Storage, client eth.Client) *Mempool {
+// NewMempool returns a mempool that validates transactions against storage
+// and the contract. Transactions paying less than minFee are rejected; a
+// nil minFee accepts any fee.
+func NewMempool(storage db.Storage, client eth.Client, policy *validation.SignaturePolicy, minFee *big.Int) *Mempool {
 	return &Mempool{
 		txReqs:     make(chan *txRequest),
 		quit:       make(chan bool),
 		flushReq:   make(chan flushSpendReq),
+		sizeReq:    make(chan chan int),
 		txPool:     make([]MempoolTx, 0),
 		poolSpends: make(map[string]bool),
 		storage:    storage,
 		client:     client,
+		policy:     policy,
+		minFee:     minFee,
 	}
 }
 
@@ -67,7 +82,7 @@ func (m *Mempool) Start() error {
 			case req := <-m.txReqs:
 				if len(m.txPool) == MaxMempoolSize {
 					req.res <- TxInclusionResponse{
-						Error: errors.New("mempool is full"),
+						Error: ErrMempoolFull,
 					}
 					continue
 				}
@@ -101,7 +116,16 @@ func (m *Mempool) Start() error {
 				m.poolSpends = make(map[string]bool)
 				req.res <- res
 				<-req.done
+			case res := <-m.sizeReq:
+				res <- len(m.txPool)
 			case <-m.quit:
+				for _, mtx := range m.txPool {
+					mtx.Response <- TxInclusionResponse{
+						Error: ErrMempoolStopping,
+					}
+				}
+				m.txPool = make([]MempoolTx, 0)
+				m.poolSpends = make(map[string]bool)
 				return
 			}
 		}
@@ -123,7 +147,23 @@ func (m *Mempool) Flush(done chan bool) []MempoolTx {
 	return <-res
 }
 
+// MinFee returns the smallest fee the mempool accepts, or nil if it accepts
+// any fee.
+func (m *Mempool) MinFee() *big.Int {
+	return m.minFee
+}
+
+// Size returns the number of transactions waiting to be packaged into a
+// block.
+func (m *Mempool) Size() int {
+	res := make(chan int)
+	m.sizeReq <- res
+	return <-res
+}
+
 func (m *Mempool) Append(tx chain.Transaction) TxInclusionResponse {
+	// signatures are normalized before the transaction is hashed or stored
+	tx.NormalizeSigs()
 	res := make(chan TxInclusionResponse)
 	req := &txRequest{
 		tx:  tx,
@@ -134,33 +174,30 @@ func (m *Mempool) Append(tx chain.Transaction) TxInclusionResponse {
 }
 
 func (m *Mempool) VerifySpendTransaction(tx *chain.Transaction) (error) {
+	if err := validation.ValidateFee(m.minFee, tx); err != nil {
+		return err
+	}
 	if err := m.ensureNoPoolSpend(tx); err != nil {
 		return err
 	}
 
-	return validation.ValidateSpendTransaction(m.storage, tx)
+	return validation.ValidateSpendTransaction(m.storage, m.policy, tx)
 }
 
 func (m *Mempool) VerifyDepositTransaction(tx *chain.Transaction) error {
+	if err := validation.ValidateFee(m.minFee, tx); err != nil {
+		return err
+	}
 	if err := m.ensureNoPoolSpend(tx); err != nil {
 		return err
 	}
 
-	return validation.ValidateDepositTransaction(m.storage, m.client, tx)
+	return validation.ValidateDepositTransaction(m.storage, m.client, m.policy, tx)
 }
 
 func (m *Mempool) ensureNoPoolSpend(confirmed *chain.Transaction) error {
-	tx := confirmed.Body
-	key0 := fmt.Sprintf("%d:%d:%d:%d", tx.Input0.BlockNumber, tx.Input0.TransactionIndex, tx.Input0.OutputIndex, tx.Input0.DepositNonce)
-	_, spent := m.poolSpends[key0]
-	if spent {
-		return validation.NewErrDoubleSpent()
-	}
-
-	if !tx.Input1.IsZero() {
-		key1 := fmt.Sprintf("%d:%d:%d:%d", tx.Input1.BlockNumber, tx.Input1.TransactionIndex, tx.Input1.OutputIndex, tx.Input1.DepositNonce)
-		_, spent = m.poolSpends[key1]
-		if spent {
+	for _, key := range poolSpendKeys(confirmed) {
+		if _, spent := m.poolSpends[key]; spent {
 			return validation.NewErrDoubleSpent()
 		}
 	}
@@ -169,11 +206,20 @@ func (m *Mempool) ensureNoPoolSpend(confirmed *chain.Transaction) error {
 }
 
 func (m *Mempool) updatePoolSpends(confirmed *chain.Transaction) {
+	for _, key := range poolSpendKeys(confirmed) {
+		m.poolSpends[key] = true
+	}
+}
+
+func poolSpendKeys(confirmed *chain.Transaction) []string {
 	tx := confirmed.Body
-	key0 := fmt.Sprintf("%d:%d:%d:%d", tx.Input0.BlockNumber, tx.Input0.TransactionIndex, tx.Input0.OutputIndex, tx.Input0.DepositNonce)
-	m.poolSpends[key0] = true
-	if !tx.Input1.IsZero() {
-		key1 := fmt.Sprintf("%d:%d:%d:%d", tx.Input1.BlockNumber, tx.Input1.TransactionIndex, tx.Input1.OutputIndex, tx.Input1.DepositNonce)
-		m.poolSpends[key1] = true
+	var keys []string
+	for i := 0; i < tx.InputCount(); i++ {
+		input := tx.InputAt(uint8(i))
+		if i > 0 && input.IsZero() {
+			continue
+		}
+		keys = append(keys, fmt.Sprintf("%d:%d:%d:%d", input.BlockNumber, input.TransactionIndex, input.OutputIndex, input.DepositNonce))
 	}
+	return keys
 }
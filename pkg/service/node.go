@@ -14,6 +14,8 @@ type PlasmaNode struct {
 	mPool     *Mempool
 	client    eth.Client
 	submitter *BlockSubmitter
+	quit      chan bool
+	done      chan bool
 }
 
 func NewPlasmaNode(storage db.Storage, mPool *Mempool, client eth.Client, submitter *BlockSubmitter) *PlasmaNode {
@@ -22,29 +24,49 @@ func NewPlasmaNode(storage db.Storage, mPool *Mempool, client eth.Client, submit
 		mPool:     mPool,
 		client:    client,
 		submitter: submitter,
+		quit:      make(chan bool),
+		done:      make(chan bool),
 	}
 }
 
-func (node *PlasmaNode) Start() {
+func (node *PlasmaNode) Start() error {
 	go node.awaitTxs(100 * time.Millisecond)
+	return nil
+}
+
+// Stop packages any transactions still in the mempool into a final block,
+// then stops the block packaging loop.
+func (node *PlasmaNode) Stop() error {
+	node.quit <- true
+	<-node.done
+	return nil
 }
 
 func (node *PlasmaNode) awaitTxs(interval time.Duration) {
 	tick := time.NewTicker(interval)
+	defer tick.Stop()
 
 	for {
 		select {
 		case <-tick.C:
-			done := make(chan bool)
-			spends := node.mPool.Flush(done)
-			if len(spends) > 0 {
-				node.packageBlock(spends)
-			}
-			done <- true
+			node.flushMempool()
+		case <-node.quit:
+			node.flushMempool()
+			node.done <- true
+			return
 		}
 	}
 }
 
+func (node *PlasmaNode) flushMempool() {
+	done := make(chan bool)
+	spends := node.mPool.Flush(done)
+	if len(spends) > 0 {
+		node.packageBlock(spends)
+	}
+	done <- true
+}
+
 func (node *PlasmaNode) packageBlock(mtxs []MempoolTx) {
 	txs := make([]chain.Transaction, len(mtxs), len(mtxs))
 	chans := make([]chan TxInclusionResponse, len(mtxs), len(mtxs))
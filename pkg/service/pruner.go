@@ -0,0 +1,81 @@
+package service
+
+import (
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const pruneInterval = 10 * time.Minute
+
+var prunerLogger = log.ForSubsystem("Pruner")
+
+// Pruner periodically discards the bodies of old, fully spent transactions.
+// See db.Prune for what is kept. Each run continues from the height the
+// last one reached, which is persisted in the database.
+type Pruner struct {
+	kv    db.KV
+	depth uint64
+	quit  chan bool
+	done  chan bool
+}
+
+func NewPruner(kv db.KV, depth uint64) *Pruner {
+	return &Pruner{
+		kv:    kv,
+		depth: depth,
+		quit:  make(chan bool),
+		done:  make(chan bool),
+	}
+}
+
+// Start refuses to prune a root node's database.
+func (p *Pruner) Start() error {
+	isRoot, err := db.IsRootNode(p.kv)
+	if err != nil {
+		return err
+	}
+	if isRoot {
+		return db.ErrPruneRootNode
+	}
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+
+		p.prune()
+		for {
+			select {
+			case <-ticker.C:
+				p.prune()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop waits for a running prune to finish, so that storage is not closed
+// underneath it.
+func (p *Pruner) Stop() error {
+	p.quit <- true
+	<-p.done
+	return nil
+}
+
+func (p *Pruner) prune() {
+	report, err := db.Prune(p.kv, p.depth)
+	if err != nil {
+		log.WithError(prunerLogger, err).Error("failed to prune transactions")
+		return
+	}
+	prunerLogger.WithFields(logrus.Fields{
+		"height":       report.Height,
+		"prunedHeight": report.PrunedHeight,
+		"pruned":       report.Pruned,
+	}).Info("pruned transactions")
+}
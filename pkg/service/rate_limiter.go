@@ -0,0 +1,153 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// maxTrackedKeys is the number of keys a RateLimiter or Banlist tracks
+// before it forgets the ones that no longer matter.
+const maxTrackedKeys = 10000
+
+// RateLimiter is a token bucket per key. Each bucket holds up to one
+// second's worth of requests, and at least one.
+type RateLimiter struct {
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+	mtx     sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewRateLimiter returns a limiter allowing rate requests per second for
+// each key.
+func NewRateLimiter(rate float64) *RateLimiter {
+	burst := rate
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from key's bucket, and returns false if it is empty.
+func (r *RateLimiter) Allow(key string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	now := r.now()
+	bucket, ok := r.buckets[key]
+	if !ok {
+		if len(r.buckets) >= maxTrackedKeys {
+			r.forgetFullBuckets(now)
+		}
+		bucket = &tokenBucket{
+			tokens:  r.burst,
+			updated: now,
+		}
+		r.buckets[key] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * r.rate
+	if bucket.tokens > r.burst {
+		bucket.tokens = r.burst
+	}
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// forgetFullBuckets removes buckets that have refilled, since a new bucket
+// would start out the same.
+func (r *RateLimiter) forgetFullBuckets(now time.Time) {
+	for key, bucket := range r.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*r.rate >= r.burst {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// Banlist bans peers that commit threshold strikes within duration of
+// their first, for duration.
+type Banlist struct {
+	threshold int
+	duration  time.Duration
+	peers     map[string]*banRecord
+	now       func() time.Time
+	mtx       sync.Mutex
+}
+
+type banRecord struct {
+	strikes     int
+	firstStrike time.Time
+	bannedUntil time.Time
+}
+
+func NewBanlist(threshold int, duration time.Duration) *Banlist {
+	return &Banlist{
+		threshold: threshold,
+		duration:  duration,
+		peers:     make(map[string]*banRecord),
+		now:       time.Now,
+	}
+}
+
+// Strike records a strike against peer, and returns true if it got peer
+// banned.
+func (b *Banlist) Strike(peer string) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := b.now()
+	record, ok := b.peers[peer]
+	if !ok || now.Sub(record.firstStrike) > b.duration {
+		if !ok && len(b.peers) >= maxTrackedKeys {
+			b.forgetExpired(now)
+		}
+		record = &banRecord{
+			firstStrike: now,
+		}
+		b.peers[peer] = record
+	}
+
+	record.strikes++
+	if record.strikes < b.threshold {
+		return false
+	}
+	record.bannedUntil = now.Add(b.duration)
+	return true
+}
+
+// BannedUntil returns when peer's ban ends, and false if peer is not
+// banned.
+func (b *Banlist) BannedUntil(peer string) (time.Time, bool) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	record, ok := b.peers[peer]
+	if !ok || !b.now().Before(record.bannedUntil) {
+		return time.Time{}, false
+	}
+	return record.bannedUntil, true
+}
+
+func (b *Banlist) forgetExpired(now time.Time) {
+	for peer, record := range b.peers {
+		if now.Sub(record.firstStrike) > b.duration && !now.Before(record.bannedUntil) {
+			delete(b.peers, peer)
+		}
+	}
+}
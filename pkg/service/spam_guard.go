@@ -0,0 +1,113 @@
+package service
+
+import (
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/log"
+	"github.com/kyokan/plasma/pkg/validation"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+var spamLogger = log.ForSubsystem("SpamGuard")
+
+// SpamPolicy configures the limits a SpamGuard enforces on write requests.
+// Zero values disable the corresponding limit.
+type SpamPolicy struct {
+	// PeerRateLimit is the number of write requests per second each peer may
+	// make.
+	PeerRateLimit float64
+	// AddressRateLimit is the number of transactions per second that may
+	// spend each address's outputs.
+	AddressRateLimit float64
+	// BanThreshold is the number of invalid transactions after which a peer
+	// is banned for BanDuration.
+	BanThreshold int
+	BanDuration  time.Duration
+	// ExemptPeers are not rate limited or banned. Validators that forward
+	// their users' transactions should be listed here.
+	ExemptPeers []string
+}
+
+// SpamGuard rate limits write requests by peer and by sending address, and
+// bans peers that repeatedly submit invalid transactions. Rejections are
+// reported as validation.ErrRateLimited and validation.ErrBanned.
+type SpamGuard struct {
+	storage   db.Storage
+	peers     *RateLimiter
+	addresses *RateLimiter
+	banlist   *Banlist
+	exempt    map[string]bool
+}
+
+func NewSpamGuard(storage db.Storage, policy SpamPolicy) *SpamGuard {
+	guard := &SpamGuard{
+		storage: storage,
+		exempt:  make(map[string]bool),
+	}
+	if policy.PeerRateLimit > 0 {
+		guard.peers = NewRateLimiter(policy.PeerRateLimit)
+	}
+	if policy.AddressRateLimit > 0 {
+		guard.addresses = NewRateLimiter(policy.AddressRateLimit)
+	}
+	if policy.BanThreshold > 0 && policy.BanDuration > 0 {
+		guard.banlist = NewBanlist(policy.BanThreshold, policy.BanDuration)
+	}
+	for _, peer := range policy.ExemptPeers {
+		guard.exempt[peer] = true
+	}
+	return guard
+}
+
+// AdmitPeer returns an error if peer is banned or has exceeded its rate
+// limit.
+func (g *SpamGuard) AdmitPeer(peer string) error {
+	if g.exempt[peer] {
+		return nil
+	}
+	if g.banlist != nil {
+		if until, banned := g.banlist.BannedUntil(peer); banned {
+			return validation.NewErrBanned(peer, until)
+		}
+	}
+	if g.peers != nil && !g.peers.Allow(peer) {
+		return validation.NewErrRateLimited("peer", peer)
+	}
+	return nil
+}
+
+// AdmitTransaction returns an error if the owner of tx's first input has
+// exceeded its rate limit. Deposits, and transactions whose first input
+// cannot be found, are left to validation.
+func (g *SpamGuard) AdmitTransaction(tx *chain.Transaction) error {
+	if g.addresses == nil || tx.Body.IsDeposit() {
+		return nil
+	}
+
+	input := tx.Body.InputAt(0)
+	prevTx, err := g.storage.FindTransactionByBlockNumTxIdx(input.BlockNumber, input.TransactionIndex)
+	if err != nil {
+		return nil
+	}
+	owner := prevTx.Transaction.Body.OutputAt(input.OutputIndex).Owner.Hex()
+	if !g.addresses.Allow(owner) {
+		return validation.NewErrRateLimited("address", owner)
+	}
+	return nil
+}
+
+// Record counts a strike against peer if err means it sent an invalid
+// transaction.
+func (g *SpamGuard) Record(peer string, err error) {
+	if err == nil || g.banlist == nil || g.exempt[peer] || !validation.IsInvalidTransaction(err) {
+		return
+	}
+
+	if g.banlist.Strike(peer) {
+		spamLogger.WithFields(logrus.Fields{
+			"peer":   peer,
+			"reason": err,
+		}).Warn("banned peer for submitting invalid transactions")
+	}
+}
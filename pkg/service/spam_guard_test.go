@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kyokan/plasma/pkg/validation"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	limiter := NewRateLimiter(2)
+	limiter.now = clock.Now
+
+	require.True(t, limiter.Allow("a"))
+	require.True(t, limiter.Allow("a"))
+	require.False(t, limiter.Allow("a"))
+	// keys have separate buckets
+	require.True(t, limiter.Allow("b"))
+
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	require.True(t, limiter.Allow("a"))
+	require.False(t, limiter.Allow("a"))
+
+	// buckets never hold more than one second's worth
+	clock.now = clock.now.Add(time.Hour)
+	require.True(t, limiter.Allow("a"))
+	require.True(t, limiter.Allow("a"))
+	require.False(t, limiter.Allow("a"))
+}
+
+func TestRateLimiter_SlowRate(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	limiter := NewRateLimiter(0.1)
+	limiter.now = clock.Now
+
+	require.True(t, limiter.Allow("a"))
+	require.False(t, limiter.Allow("a"))
+	clock.now = clock.now.Add(10 * time.Second)
+	require.True(t, limiter.Allow("a"))
+}
+
+func TestBanlist(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	banlist := NewBanlist(3, time.Minute)
+	banlist.now = clock.Now
+
+	require.False(t, banlist.Strike("a"))
+	require.False(t, banlist.Strike("a"))
+	_, banned := banlist.BannedUntil("a")
+	require.False(t, banned)
+
+	// strikes outside the window start over
+	clock.now = clock.now.Add(2 * time.Minute)
+	require.False(t, banlist.Strike("a"))
+	require.False(t, banlist.Strike("a"))
+	require.True(t, banlist.Strike("a"))
+	until, banned := banlist.BannedUntil("a")
+	require.True(t, banned)
+	require.Equal(t, clock.now.Add(time.Minute), until)
+	_, banned = banlist.BannedUntil("b")
+	require.False(t, banned)
+
+	clock.now = until
+	_, banned = banlist.BannedUntil("a")
+	require.False(t, banned)
+}
+
+func TestSpamGuard_Peers(t *testing.T) {
+	guard := NewSpamGuard(nil, SpamPolicy{
+		PeerRateLimit: 1,
+		BanThreshold:  2,
+		BanDuration:   time.Minute,
+		ExemptPeers:   []string{"10.0.0.1"},
+	})
+
+	require.NoError(t, guard.AdmitPeer("10.0.0.2"))
+	err := guard.AdmitPeer("10.0.0.2")
+	require.IsType(t, &validation.ErrRateLimited{}, err)
+	require.Equal(t, "peer", err.(*validation.ErrRateLimited).Scope)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, guard.AdmitPeer("10.0.0.1"))
+		guard.Record("10.0.0.1", validation.NewErrInvalidSignature(0))
+	}
+
+	// double spends and other errors are not strikes
+	guard.Record("10.0.0.3", validation.NewErrDoubleSpent())
+	guard.Record("10.0.0.3", errors.New("mempool is full"))
+	guard.Record("10.0.0.3", validation.NewErrInvalidSignature(0))
+	require.NoError(t, guard.AdmitPeer("10.0.0.3"))
+	guard.Record("10.0.0.3", validation.NewErrNegativeOutput(0))
+	require.IsType(t, &validation.ErrBanned{}, guard.AdmitPeer("10.0.0.3"))
+}
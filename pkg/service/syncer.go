@@ -17,6 +17,7 @@ type Syncer struct {
 	storage         db.Storage
 	rootClient      pb.RootClient
 	ethClient       eth.Client
+	policy          *validation.SignaturePolicy
 	exitStrategizer *ExitStrategizer
 	mainBreaker     CircuitBreaker
 	quit            chan bool
@@ -26,11 +27,12 @@ const syncerPollInterval = time.Second
 
 var syncerLogger = log.ForSubsystem("Syncer")
 
-func NewSyncer(storage db.Storage, rootClient pb.RootClient, ethClient eth.Client, exitStrategizer *ExitStrategizer, mainBreaker CircuitBreaker) *Syncer {
+func NewSyncer(storage db.Storage, rootClient pb.RootClient, ethClient eth.Client, policy *validation.SignaturePolicy, exitStrategizer *ExitStrategizer, mainBreaker CircuitBreaker) *Syncer {
 	return &Syncer{
 		storage:         storage,
 		rootClient:      rootClient,
 		ethClient:       ethClient,
+		policy:          policy,
 		exitStrategizer: exitStrategizer,
 		mainBreaker:     mainBreaker,
 		quit:            make(chan bool),
@@ -113,7 +115,7 @@ func (s *Syncer) doSync() error {
 		}
 		block := chain.BlockFromProto(res.Block)
 		meta := chain.BlockMetadataFromProto(res.Metadata)
-		if err := validation.ValidateBlock(s.storage, s.ethClient, block, confirmedTxs); err != nil {
+		if err := validation.ValidateBlock(s.storage, s.ethClient, s.policy, block, confirmedTxs); err != nil {
 			s.exitStrategizer.BlockCorrupted()
 			return err
 		}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/merkle"
+	"github.com/kyokan/plasma/pkg/rpc/pb"
+	"github.com/kyokan/plasma/pkg/validation"
+	"github.com/kyokan/plasma/test_util"
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type syncRootClient struct {
+	pb.RootClient
+	blocks []*pb.GetBlockResponse
+}
+
+func (c *syncRootClient) Sync(ctx context.Context, req *pb.SyncRequest, opts ...grpc.CallOption) (pb.Root_SyncClient, error) {
+	return &syncStream{blocks: c.blocks}, nil
+}
+
+type syncStream struct {
+	grpc.ClientStream
+	blocks []*pb.GetBlockResponse
+}
+
+func (s *syncStream) Recv() (*pb.GetBlockResponse, error) {
+	if len(s.blocks) == 0 {
+		return nil, io.EOF
+	}
+	res := s.blocks[0]
+	s.blocks = s.blocks[1:]
+	return res, nil
+}
+
+// historicalDepositBlock returns a deposit block as roots created them
+// before signatures had to be canonical: the recovery ids are 27 or 28, and
+// the zero input has a signature.
+func historicalDepositBlock(t *testing.T, ethClient *test_util.EthClientMock) *pb.GetBlockResponse {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	signer := eth.NewPrivateKeySigner(key)
+
+	nonce := big.NewInt(1)
+	amount := big.NewInt(100)
+	ethClient.On("LookupDeposit", mock.Anything).Return(amount, signer.Address(), nil)
+
+	body := chain.ZeroBody()
+	body.Input0 = chain.NewInput(0, 0, 0, nonce)
+	body.Output0 = chain.NewOutput(signer.Address(), amount)
+	body.BlockNumber = 1
+	sig, err := eth.Sign(signer, body.SignatureHash())
+	require.NoError(t, err)
+	sig[64] += 27
+	tx := &chain.Transaction{
+		Body: body,
+		Sigs: [2]chain.Signature{sig, sig},
+	}
+
+	merkleRoot := merkle.Root([]util.RLPHashable{tx})
+	confirmSig, err := eth.SignConfirmation(signer, tx, merkleRoot)
+	require.NoError(t, err)
+	confirmSig[64] += 27
+	confirmed := &chain.ConfirmedTransaction{
+		Transaction: tx,
+		ConfirmSigs: [2]chain.Signature{confirmSig},
+	}
+
+	block := &chain.Block{
+		Header: &chain.BlockHeader{
+			MerkleRoot: merkleRoot,
+			Number:     1,
+		},
+	}
+	meta := &chain.BlockMetadata{
+		TransactionCount: 1,
+		Fees:             big.NewInt(0),
+	}
+	return &pb.GetBlockResponse{
+		Block:                 block.Proto(),
+		ConfirmedTransactions: []*pb.ConfirmedTransaction{confirmed.Proto()},
+		Metadata:              meta.Proto(),
+	}
+}
+
+func newTestSyncer(t *testing.T, strictFrom uint64) (*Syncer, *ExitStrategizer, db.Storage) {
+	_, storage, err := db.CreateStorage(db.BackendMemory, "")
+	require.NoError(t, err)
+	ethClient := &test_util.EthClientMock{}
+	rootClient := &syncRootClient{
+		blocks: []*pb.GetBlockResponse{historicalDepositBlock(t, ethClient)},
+	}
+	// the strategizer isn't started, so its channels are buffered to
+	// record what the syncer reports
+	exitStrategizer := &ExitStrategizer{
+		blockCorrupted: make(chan bool, 1),
+		rootResponsive: make(chan bool, 1),
+	}
+	policy := validation.NewSignaturePolicy(chain.NewDomain(big.NewInt(1), common.Address{}))
+	policy.StrictFrom = strictFrom
+	syncer := NewSyncer(storage, rootClient, ethClient, policy, exitStrategizer, NewCircuitBreaker("test"))
+	return syncer, exitStrategizer, storage
+}
+
+func TestSyncer_HistoricalSignatures(t *testing.T) {
+	syncer, exitStrategizer, storage := newTestSyncer(t, 2)
+	require.NoError(t, syncer.doSync())
+	require.Len(t, exitStrategizer.blockCorrupted, 0)
+
+	head, err := storage.LatestBlock()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), head.Header.Number)
+}
+
+func TestSyncer_StrictSignatures(t *testing.T) {
+	syncer, exitStrategizer, storage := newTestSyncer(t, 0)
+	require.Error(t, syncer.doSync())
+	require.Len(t, exitStrategizer.blockCorrupted, 1)
+
+	head, err := storage.LatestBlock()
+	require.NoError(t, err)
+	require.Nil(t, head)
+}
@@ -7,11 +7,11 @@ import (
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/pkg/errors"
 	"github.com/kyokan/plasma/pkg/eth"
-	"bytes"
-	"github.com/kyokan/plasma/util"
 	"github.com/kyokan/plasma/pkg/log"
 	"github.com/sirupsen/logrus"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/pkg/validation"
+	"github.com/kyokan/plasma/util"
 )
 
 type TransactionConfirmer struct {
@@ -28,13 +28,16 @@ func NewTransactionConfirmer(storage db.Storage, client eth.Client) *Transaction
 	}
 }
 
-func (t *TransactionConfirmer) Confirm(blockNumber uint64, transactionIndex uint32, signatures [2]chain.Signature) (*chain.ConfirmedTransaction, error) {
+func (t *TransactionConfirmer) Confirm(blockNumber uint64, transactionIndex uint32, signatures []chain.Signature) (*chain.ConfirmedTransaction, error) {
 	lgr := tcfLogger.WithFields(logrus.Fields{
 		"blockNumber":      blockNumber,
 		"transactionIndex": transactionIndex,
 	})
 
 	var emptySig chain.Signature
+	for i := range signatures {
+		signatures[i].NormalizeV()
+	}
 	confirmed, err := t.storage.FindTransactionByBlockNumTxIdx(blockNumber, transactionIndex)
 	if err != nil {
 		return nil, err
@@ -45,12 +48,20 @@ func (t *TransactionConfirmer) Confirm(blockNumber uint64, transactionIndex uint
 	}
 	tx := confirmed.Transaction
 
-	merkleRoot := blk.Header.MerkleRoot
-	txHash := tx.RLPHash(util.Sha256)
-	var sigBuf bytes.Buffer
-	sigBuf.Write(txHash[:])
-	sigBuf.Write(merkleRoot[:])
-	sigHash := util.Sha256(sigBuf.Bytes())
+	// the contract checks confirm sigs when exits are started and
+	// challenged, and it only understands legacy signatures
+	sigHashes, err := validation.LegacySignatures.Hashes(func(version chain.SignatureVersion, domain *chain.Domain) (util.Hash, error) {
+		return tx.VersionedConfirmationHash(version, domain, blk.Header.MerkleRoot)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(signatures) < tx.Body.InputCount() {
+		return nil, errors.New("missing confirmation signatures")
+	}
+	if len(signatures) > chain.MaxInputs {
+		return nil, errors.New("too many confirmation signatures")
+	}
 	for i, sig := range signatures {
 		input := tx.Body.InputAt(uint8(i))
 		if i > 0 && input.IsZero() {
@@ -81,12 +92,14 @@ func (t *TransactionConfirmer) Confirm(blockNumber uint64, transactionIndex uint
 			owner = prevTx.Transaction.Body.OutputAt(input.OutputIndex).Owner
 		}
 
-		if err := eth.ValidateSignature(sigHash, sig[:], owner); err != nil {
+		if err := validation.CheckSignature(sigHashes, sig, owner); err != nil {
 			lgr.Warn("rejected confirmation due to invalid signatures")
 			return nil, err
 		}
 	}
 
 	lgr.Info("confirmation is valid, persisting")
-	return t.storage.ConfirmTransaction(blockNumber, transactionIndex, signatures)
+	var confirmSigs [2]chain.Signature
+	copy(confirmSigs[:], signatures)
+	return t.storage.ConfirmTransaction(blockNumber, transactionIndex, confirmSigs)
 }
@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"strconv"
+	"time"
+	"github.com/kyokan/plasma/pkg/rpc"
+)
+
+// Code is a stable, machine-readable name for an error, reported by both
+// the gRPC and REST APIs. Clients should match on codes rather than
+// messages, which may change.
+type Code string
+
+const (
+	CodeUnknown                   Code = "UNKNOWN"
+	CodeNotFound                  Code = "NOT_FOUND"
+	CodeNegativeOutput            Code = "NEGATIVE_OUTPUT"
+	CodeTxNotFound                Code = "TX_NOT_FOUND"
+	CodeConfirmSigMismatch        Code = "CONFIRM_SIG_MISMATCH"
+	CodeInvalidSignature          Code = "INVALID_SIGNATURE"
+	CodeNonCanonicalSignature     Code = "NON_CANONICAL_SIGNATURE"
+	CodeIdenticalInputs           Code = "IDENTICAL_INPUTS"
+	CodeInputOutputValueMismatch  Code = "INPUT_OUTPUT_VALUE_MISMATCH"
+	CodeDoubleSpent               Code = "DOUBLE_SPENT"
+	CodeDepositDefinedInput1      Code = "DEPOSIT_DEFINED_INPUT1"
+	CodeDepositNonEmptyConfirmSig Code = "DEPOSIT_NON_EMPTY_CONFIRM_SIG"
+	CodeFeeTooLow                 Code = "FEE_TOO_LOW"
+	CodeRateLimited               Code = "RATE_LIMITED"
+	CodeBanned                    Code = "BANNED"
+	CodeMempoolFull               Code = "MEMPOOL_FULL"
+	CodeUnavailable               Code = "UNAVAILABLE"
+	CodeUnauthenticated           Code = "UNAUTHENTICATED"
+)
+
+// ErrorDetails describes an error for API clients. Metadata holds the
+// error's fields as strings, keyed by their JSON names: input and output
+// indices in decimal, amounts in wei, addresses in hex and times in
+// RFC 3339.
+type ErrorDetails struct {
+	Code     Code              `json:"code"`
+	Message  string            `json:"message"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Describe returns the details of err. Errors that are not defined by
+// this package are reported with CodeUnknown and no metadata.
+func Describe(err error) *ErrorDetails {
+	details := &ErrorDetails{
+		Code:    CodeUnknown,
+		Message: err.Error(),
+	}
+
+	switch e := err.(type) {
+	case *ErrNegativeOutput:
+		details.Code = CodeNegativeOutput
+		details.Metadata = map[string]string{
+			"outputIndex": formatUint(uint64(e.Index)),
+		}
+	case *ErrTxNotFound:
+		details.Code = CodeTxNotFound
+		details.Metadata = map[string]string{
+			"inputIndex":       formatUint(uint64(e.InputIndex)),
+			"blockNumber":      formatUint(e.BlockNumber),
+			"transactionIndex": formatUint(uint64(e.TransactionIndex)),
+		}
+	case *ErrConfirmSigMismatch:
+		details.Code = CodeConfirmSigMismatch
+		details.Metadata = map[string]string{
+			"inputIndex":      formatUint(uint64(e.InputIndex)),
+			"confirmSigIndex": formatUint(uint64(e.ConfirmSigIndex)),
+		}
+	case *ErrInvalidSignature:
+		details.Code = CodeInvalidSignature
+		details.Metadata = map[string]string{
+			"inputIndex": formatUint(uint64(e.InputIndex)),
+		}
+	case *ErrIdenticalInputs:
+		details.Code = CodeIdenticalInputs
+	case *ErrInputOutputValueMismatch:
+		details.Code = CodeInputOutputValueMismatch
+		details.Metadata = map[string]string{
+			"totalInputs":      e.TotalInputs.Text(10),
+			"totalOutputsFees": e.TotalOutputsFees.Text(10),
+		}
+	case *ErrDoubleSpent:
+		details.Code = CodeDoubleSpent
+	case *ErrDepositDefinedInput1:
+		details.Code = CodeDepositDefinedInput1
+	case *ErrDepositNonEmptyConfirmSig:
+		details.Code = CodeDepositNonEmptyConfirmSig
+	case *ErrFeeTooLow:
+		details.Code = CodeFeeTooLow
+		details.Metadata = map[string]string{
+			"fee":    e.Fee.Text(10),
+			"minFee": e.MinFee.Text(10),
+		}
+	case *ErrRateLimited:
+		details.Code = CodeRateLimited
+		details.Metadata = map[string]string{
+			"scope": e.Scope,
+			"key":   e.Key,
+		}
+	case *ErrBanned:
+		details.Code = CodeBanned
+		details.Metadata = map[string]string{
+			"peer":  e.Peer,
+			"until": e.Until.UTC().Format(time.RFC3339),
+		}
+	case *rpc.ErrUnauthenticated:
+		details.Code = CodeUnauthenticated
+	default:
+		switch err {
+		case eth.ErrNonCanonicalSignature:
+			details.Code = CodeNonCanonicalSignature
+		case db.ErrNotFound:
+			details.Code = CodeNotFound
+		}
+	}
+
+	return details
+}
+
+func formatUint(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
@@ -0,0 +1,74 @@
+package validation
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/pkg/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		err      error
+		code     Code
+		metadata map[string]string
+	}{
+		{
+			NewErrTxNotFound(1, 20, 3),
+			CodeTxNotFound,
+			map[string]string{
+				"inputIndex":       "1",
+				"blockNumber":      "20",
+				"transactionIndex": "3",
+			},
+		},
+		{
+			NewErrInvalidSignature(2),
+			CodeInvalidSignature,
+			map[string]string{
+				"inputIndex": "2",
+			},
+		},
+		{
+			NewErrInputOutputValueMismatch(big.NewInt(100), big.NewInt(90)),
+			CodeInputOutputValueMismatch,
+			map[string]string{
+				"totalInputs":      "100",
+				"totalOutputsFees": "90",
+			},
+		},
+		{
+			NewErrFeeTooLow(big.NewInt(1), big.NewInt(1000000000)),
+			CodeFeeTooLow,
+			map[string]string{
+				"fee":    "1",
+				"minFee": "1000000000",
+			},
+		},
+		{
+			NewErrBanned("10.0.0.1", time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)),
+			CodeBanned,
+			map[string]string{
+				"peer":  "10.0.0.1",
+				"until": "2019-01-02T03:04:05Z",
+			},
+		},
+		{NewErrDoubleSpent(), CodeDoubleSpent, nil},
+		{rpc.NewErrUnauthenticated("missing credentials"), CodeUnauthenticated, nil},
+		{eth.ErrNonCanonicalSignature, CodeNonCanonicalSignature, nil},
+		{db.ErrNotFound, CodeNotFound, nil},
+		{errors.New("something else"), CodeUnknown, nil},
+	}
+
+	for _, tt := range tests {
+		details := Describe(tt.err)
+		require.Equal(t, tt.code, details.Code)
+		require.Equal(t, tt.err.Error(), details.Message)
+		require.Equal(t, tt.metadata, details.Metadata)
+	}
+}
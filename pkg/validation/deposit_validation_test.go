@@ -49,14 +49,14 @@ func (d *depositValidationSuite) TestNegativeOutputs() {
 	tx := d.bwm1.ConfirmedTransactions[0]
 	tx.Transaction.Body.Output0.Amount = big.NewInt(-1)
 
-	err := ValidateDepositTransaction(d.storage, d.mockClient, tx.Transaction)
+	err := ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx.Transaction)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &ErrNegativeOutput{}, err)
 	require.Equal(d.T(), uint8(0), err.(*ErrNegativeOutput).Index)
 
 	tx.Transaction.Body.Output0.Amount = big.NewInt(100)
 	tx.Transaction.Body.Output1.Amount = big.NewInt(-1)
-	err = ValidateSpendTransaction(d.storage, tx.Transaction)
+	err = ValidateSpendTransaction(d.storage, LegacySignatures, tx.Transaction)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &ErrNegativeOutput{}, err)
 	require.Equal(d.T(), uint8(1), err.(*ErrNegativeOutput).Index)
@@ -66,7 +66,7 @@ func (d *depositValidationSuite) TestDefinedInput1() {
 	tx := d.bwm1.ConfirmedTransactions[0]
 	tx.Transaction.Body.Input1.BlockNumber = 2
 
-	err := ValidateDepositTransaction(d.storage, d.mockClient, tx.Transaction)
+	err := ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx.Transaction)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &ErrDepositDefinedInput1{}, err)
 
@@ -75,7 +75,7 @@ func (d *depositValidationSuite) TestDefinedInput1() {
 	require.NoError(d.T(), err)
 	tx.Transaction.Body.Input1ConfirmSigs[0] = sig
 
-	err = ValidateDepositTransaction(d.storage, d.mockClient, tx.Transaction)
+	err = ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx.Transaction)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &ErrDepositDefinedInput1{}, err)
 }
@@ -86,7 +86,7 @@ func (d *depositValidationSuite) TestNonEmptyConfirmSig() {
 	tx := d.bwm1.ConfirmedTransactions[0]
 	tx.Transaction.Body.Input0ConfirmSigs[0] = sig
 
-	err = ValidateDepositTransaction(d.storage, d.mockClient, tx.Transaction)
+	err = ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx.Transaction)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &ErrDepositNonEmptyConfirmSig{}, err)
 }
@@ -98,7 +98,7 @@ func (d *depositValidationSuite) TestNonExistentDeposit() {
 	d.mockClient.On("LookupDeposit", nonce).Return(amount, addr, eth.NewErrDepositNotFound(nonce))
 
 	tx := d.bwm1.ConfirmedTransactions[0]
-	err := ValidateDepositTransaction(d.storage, d.mockClient, tx.Transaction)
+	err := ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx.Transaction)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &eth.ErrDepositNotFound{}, err)
 }
@@ -108,7 +108,7 @@ func (d *depositValidationSuite) TestInputOutputValueMismatch() {
 	mockSuccessfulDeposit(d.mockClient, tx)
 
 	tx.Body.Output0.Amount = tx.Body.Output0.Amount.Mul(tx.Body.Output0.Amount, big.NewInt(10))
-	err := ValidateDepositTransaction(d.storage, d.mockClient, tx)
+	err := ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &ErrInputOutputValueMismatch{}, err)
 }
@@ -138,7 +138,7 @@ func (d *depositValidationSuite) Test_Z_DoubleSpend() {
 	require.NoError(d.T(), err)
 	tx := d.bwm1.ConfirmedTransactions[0].Transaction
 	mockSuccessfulDeposit(d.mockClient, tx)
-	err = ValidateDepositTransaction(d.storage, d.mockClient, tx)
+	err = ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx)
 	require.Error(d.T(), err)
 	require.IsType(d.T(), &ErrDoubleSpent{}, err)
 }
@@ -146,7 +146,7 @@ func (d *depositValidationSuite) Test_Z_DoubleSpend() {
 func (d *depositValidationSuite) TestValid() {
 	tx := d.bwm1.ConfirmedTransactions[0].Transaction
 	mockSuccessfulDeposit(d.mockClient, tx)
-	err := ValidateDepositTransaction(d.storage, d.mockClient, tx)
+	err := ValidateDepositTransaction(d.storage, d.mockClient, LegacySignatures, tx)
 	require.NoError(d.T(), err)
 }
 
@@ -155,7 +155,7 @@ func TestValidateDeposit(t *testing.T) {
 }
 
 func requireInvalidDepositSignature(t *testing.T, storage db.Storage, client eth.Client, tx *chain.Transaction, inputIndex uint8) {
-	err := ValidateDepositTransaction(storage, client, tx)
+	err := ValidateDepositTransaction(storage, client, LegacySignatures, tx)
 	require.Error(t, err)
 	require.IsType(t, &ErrInvalidSignature{}, err)
 	require.Equal(t, inputIndex, err.(*ErrInvalidSignature).InputIndex)
@@ -2,7 +2,9 @@ package validation
 
 import (
 	"fmt"
+	"github.com/kyokan/plasma/pkg/eth"
 	"math/big"
+	"time"
 )
 
 type ErrNegativeOutput struct {
@@ -115,7 +117,7 @@ func NewErrDepositDefinedInput1() error {
 }
 
 func (e *ErrDepositDefinedInput1) Error() string {
-	return "deposit defined input1, which is illegal"
+	return "deposit defined more than one input, which is illegal"
 }
 
 type ErrDepositNonEmptyConfirmSig struct {}
@@ -126,4 +128,71 @@ func NewErrDepositNonEmptyConfirmSig() error {
 
 func (e *ErrDepositNonEmptyConfirmSig) Error() string {
 	return "deposit defined non-empty input0 confirm sig, which is illegal"
-}
\ No newline at end of file
+}
+
+type ErrFeeTooLow struct {
+	Fee    *big.Int
+	MinFee *big.Int
+}
+
+func NewErrFeeTooLow(fee *big.Int, minFee *big.Int) error {
+	return &ErrFeeTooLow{
+		Fee:    fee,
+		MinFee: minFee,
+	}
+}
+
+func (e *ErrFeeTooLow) Error() string {
+	return fmt.Sprintf("fee of %s is below the minimum fee of %s", e.Fee.Text(10), e.MinFee.Text(10))
+}
+
+type ErrRateLimited struct {
+	// Scope is what the limit applies to, either "peer" or "address".
+	Scope string
+	Key   string
+}
+
+func NewErrRateLimited(scope string, key string) error {
+	return &ErrRateLimited{
+		Scope: scope,
+		Key:   key,
+	}
+}
+
+func (e *ErrRateLimited) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s %s", e.Scope, e.Key)
+}
+
+type ErrBanned struct {
+	Peer  string
+	Until time.Time
+}
+
+func NewErrBanned(peer string, until time.Time) error {
+	return &ErrBanned{
+		Peer:  peer,
+		Until: until,
+	}
+}
+
+func (e *ErrBanned) Error() string {
+	return fmt.Sprintf("peer %s is banned until %s for submitting invalid transactions", e.Peer, e.Until.UTC().Format(time.RFC3339))
+}
+
+// IsInvalidTransaction returns true if err means the transaction itself is
+// invalid, rather than that it lost a race with another spend or was
+// turned away by policy.
+func IsInvalidTransaction(err error) bool {
+	if err == eth.ErrNonCanonicalSignature {
+		return true
+	}
+
+	switch err.(type) {
+	case *ErrNegativeOutput, *ErrTxNotFound, *ErrConfirmSigMismatch,
+		*ErrInvalidSignature, *ErrIdenticalInputs, *ErrInputOutputValueMismatch,
+		*ErrDepositDefinedInput1, *ErrDepositNonEmptyConfirmSig:
+		return true
+	default:
+		return false
+	}
+}
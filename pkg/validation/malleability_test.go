@@ -0,0 +1,152 @@
+package validation
+
+import (
+	"io/ioutil"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/test_util"
+	"github.com/kyokan/plasma/util"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+type malleabilitySuite struct {
+	suite.Suite
+	storage db.Storage
+	ldb     *leveldb.DB
+	deposit *chain.BlockWithMeta
+	spend   *chain.BlockWithMeta
+}
+
+func (m *malleabilitySuite) SetupSuite() {
+	tmpDir, err := ioutil.TempDir("", "plasma-test")
+	require.NoError(m.T(), err)
+	ldb, storage, err := db.CreateLevelStorage(tmpDir)
+	require.NoError(m.T(), err)
+	m.ldb = ldb
+	m.storage = storage
+
+	_, err = insertFixture(m.storage, "block_1.json")
+	require.NoError(m.T(), err)
+	_, err = insertFixture(m.storage, "block_2.json")
+	require.NoError(m.T(), err)
+}
+
+func (m *malleabilitySuite) SetupTest() {
+	deposit, err := inflateFixture("block_1.json")
+	require.NoError(m.T(), err)
+	m.deposit = deposit
+	spend, err := inflateFixture("block_3.json")
+	require.NoError(m.T(), err)
+	m.spend = spend
+}
+
+func (m *malleabilitySuite) TeardownSuite() {
+	err := m.ldb.Close()
+	require.NoError(m.T(), err)
+}
+
+func (m *malleabilitySuite) TestTwinRecoversSameSigner() {
+	tx := m.spend.ConfirmedTransactions[0].Transaction
+	hash := util.GethHash(tx.Body.SignatureHash())
+	sig := tx.SigAt(0)
+	twin := malleate(sig)
+	require.NotEqual(m.T(), sig, twin)
+
+	pub, err := crypto.SigToPub(hash, sig[:])
+	require.NoError(m.T(), err)
+	twinPub, err := crypto.SigToPub(hash, twin[:])
+	require.NoError(m.T(), err)
+	require.Equal(m.T(), crypto.PubkeyToAddress(*pub), crypto.PubkeyToAddress(*twinPub))
+}
+
+func (m *malleabilitySuite) TestSpend() {
+	tx := m.spend.ConfirmedTransactions[0].Transaction
+	require.NoError(m.T(), ValidateSpendTransaction(m.storage, LegacySignatures, tx))
+
+	tx.Sigs[0] = malleate(tx.Sigs[0])
+	requireInvalidSpendSignature(m.T(), m.storage, tx, 0)
+}
+
+func (m *malleabilitySuite) TestSpend_ZeroInput() {
+	// signatures on zero inputs are not checked against an owner, so they
+	// must be empty
+	tx := m.spend.ConfirmedTransactions[0].Transaction
+	require.True(m.T(), tx.Body.InputAt(1).IsZero())
+	tx.Sigs[1] = tx.Sigs[0]
+	requireInvalidSpendSignature(m.T(), m.storage, tx, 1)
+}
+
+func (m *malleabilitySuite) TestDeposit() {
+	client := &test_util.EthClientMock{}
+	tx := m.deposit.ConfirmedTransactions[0].Transaction
+	mockSuccessfulDeposit(client, tx)
+	// the deposit is already stored, so valid signatures get as far as the
+	// double spend check
+	err := ValidateDepositTransaction(m.storage, client, LegacySignatures, tx)
+	require.IsType(m.T(), &ErrDoubleSpent{}, err)
+
+	tx.Sigs[0] = malleate(tx.Sigs[0])
+	requireInvalidDepositSignature(m.T(), m.storage, client, tx, 0)
+}
+
+func (m *malleabilitySuite) TestDeposit_ZeroInput() {
+	client := &test_util.EthClientMock{}
+	tx := m.deposit.ConfirmedTransactions[0].Transaction
+	mockSuccessfulDeposit(client, tx)
+	tx.Sigs[1] = tx.Sigs[0]
+	requireInvalidDepositSignature(m.T(), m.storage, client, tx, 1)
+}
+
+func (m *malleabilitySuite) TestConfirmSigs() {
+	confirmed := m.spend.ConfirmedTransactions[0]
+	confirmed.ConfirmSigs[0] = malleate(confirmed.ConfirmSigs[0])
+	err := ValidateConfirmSigs(m.storage, &test_util.EthClientMock{}, LegacySignatures, m.spend.Block, &confirmed)
+	require.Equal(m.T(), eth.ErrNonCanonicalSignature, err)
+}
+
+func (m *malleabilitySuite) TestCheckSignature() {
+	key, err := crypto.GenerateKey()
+	require.NoError(m.T(), err)
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	hashes, err := NewSignaturePolicy(chain.NewDomain(big.NewInt(1), owner)).Hashes(func(version chain.SignatureVersion, domain *chain.Domain) (util.Hash, error) {
+		return util.Sha256([]byte("confirmation")), nil
+	})
+	require.NoError(m.T(), err)
+
+	for _, hash := range hashes {
+		sig, err := eth.SignVersioned(eth.NewPrivateKeySigner(key), hash.Version, hash.Hash)
+		require.NoError(m.T(), err)
+		require.NoError(m.T(), CheckSignature(hashes, sig, owner))
+		require.Equal(m.T(), eth.ErrNonCanonicalSignature, CheckSignature(hashes, malleate(sig), owner))
+	}
+}
+
+func TestMalleability(t *testing.T) {
+	suite.Run(t, new(malleabilitySuite))
+}
+
+// malleate returns the high-s twin of a low-s signature, which recovers the
+// same signer.
+func malleate(sig chain.Signature) chain.Signature {
+	n := crypto.S256().Params().N
+	s := new(big.Int).SetBytes(sig[32:64])
+	s.Sub(n, s)
+
+	twin := sig
+	copy(twin[32:64], make([]byte, 32))
+	sBytes := s.Bytes()
+	copy(twin[64-len(sBytes):64], sBytes)
+	if twin[64] >= 27 {
+		twin[64] = 55 - twin[64]
+	} else {
+		twin[64] ^= 1
+	}
+	return twin
+}
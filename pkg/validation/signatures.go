@@ -0,0 +1,133 @@
+package validation
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/kyokan/plasma/pkg/chain"
+	"github.com/kyokan/plasma/pkg/eth"
+	"github.com/kyokan/plasma/util"
+)
+
+// SignaturePolicy lists the signature versions a node accepts, and the
+// domain signatures are checked against.
+type SignaturePolicy struct {
+	Domain   *chain.Domain
+	Versions []chain.SignatureVersion
+	// StrictFrom is the first block whose signatures must be canonical,
+	// with one per input and empty ones on zero inputs. Blocks before it
+	// were created by roots that accepted recovery ids of 27 or 28 and
+	// ignored the signatures of zero inputs, so ValidateBlock holds them
+	// to those rules instead.
+	StrictFrom uint64
+}
+
+// LegacySignatures accepts legacy signatures only.
+var LegacySignatures = &SignaturePolicy{
+	Versions: []chain.SignatureVersion{chain.SignatureVersionLegacy},
+}
+
+// NewSignaturePolicy accepts signatures bound to domain, either directly or
+// as EIP-712 typed data, and legacy signatures. Legacy signatures are
+// always accepted, since they are the only ones the contract recovers.
+func NewSignaturePolicy(domain *chain.Domain) *SignaturePolicy {
+	return &SignaturePolicy{
+		Domain:   domain,
+		Versions: []chain.SignatureVersion{chain.SignatureVersionLegacy, chain.SignatureVersionDomain, chain.SignatureVersionTypedData},
+	}
+}
+
+// VersionedHash is a hash along with the signature version it was computed
+// under, which determines how it is signed.
+type VersionedHash struct {
+	Version chain.SignatureVersion
+	Hash    util.Hash
+}
+
+// VersionedHasher returns the hash signed under a signature version.
+type VersionedHasher func(version chain.SignatureVersion, domain *chain.Domain) (util.Hash, error)
+
+// Accepts returns true if the policy accepts version.
+func (p *SignaturePolicy) Accepts(version chain.SignatureVersion) bool {
+	for _, v := range p.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Hashes returns the hash of every accepted version, so it only has to be
+// computed once per transaction.
+func (p *SignaturePolicy) Hashes(hasher VersionedHasher) ([]VersionedHash, error) {
+	hashes := make([]VersionedHash, len(p.Versions))
+	for i, version := range p.Versions {
+		hash, err := hasher(version, p.Domain)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = VersionedHash{
+			Version: version,
+			Hash:    hash,
+		}
+	}
+	return hashes, nil
+}
+
+// CheckSignature returns nil if owner made sig over any of hashes. sig must
+// be in canonical low-s form.
+func CheckSignature(hashes []VersionedHash, sig chain.Signature, owner common.Address) error {
+	if !eth.IsCanonicalSignature(sig[:]) {
+		return eth.ErrNonCanonicalSignature
+	}
+
+	var err error
+	for _, hash := range hashes {
+		if err = eth.ValidateVersionedSignature(hash.Version, hash.Hash, sig[:], owner); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// checkCanonicalSigs rejects transactions whose signatures could be
+// changed without invalidating them, which would change the transaction's
+// hash. The signature of a zero input 1 is otherwise not checked, so it
+// must be empty, and the rest must be in canonical low-s form, since each
+// has a twin with the same signer.
+func checkCanonicalSigs(tx *chain.Transaction) error {
+	var emptySig chain.Signature
+	for i, sig := range tx.Sigs {
+		if i > 0 && tx.Body.InputAt(uint8(i)).IsZero() {
+			if sig != emptySig {
+				return NewErrInvalidSignature(uint8(i))
+			}
+			continue
+		}
+		if !eth.IsCanonicalSignature(sig[:]) {
+			return NewErrInvalidSignature(uint8(i))
+		}
+	}
+	return nil
+}
+
+// historicalTransaction returns a copy of confirmed whose signatures pass
+// checkCanonicalSigs if confirmed was valid before StrictFrom: recovery ids
+// of 27 or 28 are normalized, and the signature of a zero input 1, which
+// was never checked, is cleared. The block's merkle root still commits to
+// confirmed itself.
+func historicalTransaction(confirmed chain.ConfirmedTransaction) chain.ConfirmedTransaction {
+	tx := confirmed.Transaction.Clone()
+	if tx.Body.Input1.IsZero() {
+		tx.Sigs[1] = chain.Signature{}
+	}
+	tx.NormalizeSigs()
+
+	confirmSigs := confirmed.ConfirmSigs
+	for i := range confirmSigs {
+		confirmSigs[i].NormalizeV()
+	}
+
+	return chain.ConfirmedTransaction{
+		Transaction: tx,
+		ConfirmSigs: confirmSigs,
+	}
+}
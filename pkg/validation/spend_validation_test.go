@@ -15,6 +15,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/kyokan/plasma/pkg/eth"
 	"crypto/rand"
+	"github.com/ethereum/go-ethereum/common"
 )
 
 type spendValidationSuite struct {
@@ -62,14 +63,14 @@ func (v *spendValidationSuite) TestNegativeOutputs() {
 	tx := v.bwm1.ConfirmedTransactions[0]
 	tx.Transaction.Body.Output0.Amount = big.NewInt(-1)
 
-	err := ValidateSpendTransaction(v.storage, tx.Transaction)
+	err := ValidateSpendTransaction(v.storage, LegacySignatures, tx.Transaction)
 	require.Error(v.T(), err)
 	require.IsType(v.T(), &ErrNegativeOutput{}, err)
 	require.Equal(v.T(), uint8(0), err.(*ErrNegativeOutput).Index)
 
 	tx.Transaction.Body.Output0.Amount = big.NewInt(100)
 	tx.Transaction.Body.Output1.Amount = big.NewInt(-1)
-	err = ValidateSpendTransaction(v.storage, tx.Transaction)
+	err = ValidateSpendTransaction(v.storage, LegacySignatures, tx.Transaction)
 	require.Error(v.T(), err)
 	require.IsType(v.T(), &ErrNegativeOutput{}, err)
 	require.Equal(v.T(), uint8(1), err.(*ErrNegativeOutput).Index)
@@ -136,16 +137,14 @@ func (v *spendValidationSuite) TestInputOutputValueMismatch() {
 	tx.Body.Output0.Amount = tx.Body.Output0.Amount.Mul(tx.Body.Output0.Amount, big.NewInt(10))
 	err := reSign(tx, v.key, 0)
 	require.NoError(v.T(), err)
-	err = reSign(tx, v.key, 1)
-	require.NoError(v.T(), err)
-	err = ValidateSpendTransaction(v.storage, tx)
+	err = ValidateSpendTransaction(v.storage, LegacySignatures, tx)
 	require.Error(v.T(), err)
 	require.IsType(v.T(), &ErrInputOutputValueMismatch{}, err)
 }
 
 func (v *spendValidationSuite) TestDoubleSpend() {
 	tx := v.bwm1.ConfirmedTransactions[0].Transaction
-	err := ValidateSpendTransaction(v.storage, tx)
+	err := ValidateSpendTransaction(v.storage, LegacySignatures, tx)
 	require.Error(v.T(), err)
 	require.IsType(v.T(), &ErrDoubleSpent{}, err)
 }
@@ -157,23 +156,75 @@ func (v *spendValidationSuite) TestIdenticalInputs() {
 	require.NoError(v.T(), err)
 	err = reSign(tx, v.key, 1)
 	require.NoError(v.T(), err)
-	err = ValidateSpendTransaction(v.storage, tx)
+	err = ValidateSpendTransaction(v.storage, LegacySignatures, tx)
 	require.Error(v.T(), err)
 	require.IsType(v.T(), &ErrIdenticalInputs{}, err)
 }
 
 func (v *spendValidationSuite) TestValid() {
 	tx := v.bwm2.ConfirmedTransactions[0].Transaction
-	err := ValidateSpendTransaction(v.storage, tx)
+	err := ValidateSpendTransaction(v.storage, LegacySignatures, tx)
 	require.NoError(v.T(), err)
 }
 
+func (v *spendValidationSuite) TestDomainSignatures() {
+	domain := chain.NewDomain(big.NewInt(1), common.HexToAddress("0xf25186b5081ff5ce73482ad761db0eb0d25abfbf"))
+	tx := v.bwm1.ConfirmedTransactions[0].Transaction
+	require.NoError(v.T(), reSignVersioned(tx, v.key, 0, chain.SignatureVersionDomain, domain))
+
+	// the signatures are valid, so validation gets as far as the double spend
+	err := ValidateSpendTransaction(v.storage, NewSignaturePolicy(domain), tx)
+	require.IsType(v.T(), &ErrDoubleSpent{}, err)
+	requireInvalidSpendSignature(v.T(), v.storage, tx, 0)
+
+	// replaying on another deployment fails
+	otherChain := chain.NewDomain(big.NewInt(3), domain.Contract)
+	err = ValidateSpendTransaction(v.storage, NewSignaturePolicy(otherChain), tx)
+	require.IsType(v.T(), &ErrInvalidSignature{}, err)
+	otherContract := chain.NewDomain(domain.ChainID, common.HexToAddress("0xf12b5dd4ead5f743c6baa640b0216200e89b60da"))
+	err = ValidateSpendTransaction(v.storage, NewSignaturePolicy(otherContract), tx)
+	require.IsType(v.T(), &ErrInvalidSignature{}, err)
+}
+
+func (v *spendValidationSuite) TestTypedDataSignatures() {
+	domain := chain.NewDomain(big.NewInt(1), common.HexToAddress("0xf25186b5081ff5ce73482ad761db0eb0d25abfbf"))
+	tx := v.bwm1.ConfirmedTransactions[0].Transaction
+	require.NoError(v.T(), reSignVersioned(tx, v.key, 0, chain.SignatureVersionTypedData, domain))
+
+	err := ValidateSpendTransaction(v.storage, NewSignaturePolicy(domain), tx)
+	require.IsType(v.T(), &ErrDoubleSpent{}, err)
+	requireInvalidSpendSignature(v.T(), v.storage, tx, 0)
+
+	otherChain := chain.NewDomain(big.NewInt(3), domain.Contract)
+	err = ValidateSpendTransaction(v.storage, NewSignaturePolicy(otherChain), tx)
+	require.IsType(v.T(), &ErrInvalidSignature{}, err)
+}
+
+func (v *spendValidationSuite) TestDomainSignatures_AcceptLegacy() {
+	domain := chain.NewDomain(big.NewInt(1), common.HexToAddress("0xf25186b5081ff5ce73482ad761db0eb0d25abfbf"))
+	tx := v.bwm1.ConfirmedTransactions[0].Transaction
+	err := ValidateSpendTransaction(v.storage, NewSignaturePolicy(domain), tx)
+	require.IsType(v.T(), &ErrDoubleSpent{}, err)
+}
+
 func TestValidateSpend(t *testing.T) {
 	suite.Run(t, new(spendValidationSuite))
 }
 
+func TestCheckInputConfirmSigs_NormalizesV(t *testing.T) {
+	stored, err := randSig()
+	require.NoError(t, err)
+	stored[64] = 28
+	given := stored
+	given[64] = 1
+	require.NoError(t, checkInputConfirmSigs(0, [2]chain.Signature{stored}, [2]chain.Signature{given}))
+
+	given[0]++
+	require.IsType(t, &ErrConfirmSigMismatch{}, checkInputConfirmSigs(0, [2]chain.Signature{stored}, [2]chain.Signature{given}))
+}
+
 func requireNotFound(t *testing.T, storage db.Storage, tx *chain.Transaction, inputIndex uint8) {
-	err := ValidateSpendTransaction(storage, tx)
+	err := ValidateSpendTransaction(storage, LegacySignatures, tx)
 	require.Error(t, err)
 	require.IsType(t, &ErrTxNotFound{}, err)
 	input := tx.Body.InputAt(inputIndex)
@@ -183,7 +234,7 @@ func requireNotFound(t *testing.T, storage db.Storage, tx *chain.Transaction, in
 }
 
 func requireMismatchedConfirmSigs(t *testing.T, storage db.Storage, tx *chain.Transaction, inputIndex uint8, sigIndex uint8) {
-	err := ValidateSpendTransaction(storage, tx)
+	err := ValidateSpendTransaction(storage, LegacySignatures, tx)
 	require.Error(t, err)
 	require.IsType(t, &ErrConfirmSigMismatch{}, err)
 	require.Equal(t, inputIndex, err.(*ErrConfirmSigMismatch).InputIndex)
@@ -191,7 +242,7 @@ func requireMismatchedConfirmSigs(t *testing.T, storage db.Storage, tx *chain.Tr
 }
 
 func requireInvalidSpendSignature(t *testing.T, storage db.Storage, tx *chain.Transaction, inputIndex uint8) {
-	err := ValidateSpendTransaction(storage, tx)
+	err := ValidateSpendTransaction(storage, LegacySignatures, tx)
 	require.Error(t, err)
 	require.IsType(t, &ErrInvalidSignature{}, err)
 	require.Equal(t, inputIndex, err.(*ErrInvalidSignature).InputIndex)
@@ -228,7 +279,20 @@ func unmarshalBlockWithMeta(fixture []byte) (*chain.BlockWithMeta, error) {
 
 func reSign(tx *chain.Transaction, key *ecdsa.PrivateKey, index int) error {
 	hash := tx.Body.SignatureHash()
-	sig, err := eth.Sign(key, hash)
+	sig, err := eth.Sign(eth.NewPrivateKeySigner(key), hash)
+	if err != nil {
+		return err
+	}
+	tx.Sigs[index] = sig
+	return nil
+}
+
+func reSignVersioned(tx *chain.Transaction, key *ecdsa.PrivateKey, index int, version chain.SignatureVersion, domain *chain.Domain) error {
+	hash, err := tx.Body.VersionedSignatureHash(version, domain)
+	if err != nil {
+		return err
+	}
+	sig, err := eth.SignVersioned(eth.NewPrivateKeySigner(key), version, hash)
 	if err != nil {
 		return err
 	}
@@ -5,7 +5,6 @@ import (
 	"math/big"
 	"github.com/kyokan/plasma/pkg/eth"
 	"github.com/kyokan/plasma/pkg/db"
-	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/kyokan/plasma/util"
 	"bytes"
 	"github.com/ethereum/go-ethereum/common"
@@ -13,70 +12,53 @@ import (
 	"github.com/kyokan/plasma/pkg/merkle"
 		)
 
-func ValidateSpendTransaction(storage db.Storage, tx *chain.Transaction) (error) {
-	if tx.Body.Output0.Amount.Cmp(big.NewInt(0)) == -1 {
-		return NewErrNegativeOutput(0)
+func ValidateSpendTransaction(storage db.Storage, policy *SignaturePolicy, tx *chain.Transaction) (error) {
+	if err := checkNegativeOutputs(tx); err != nil {
+		return err
 	}
-	if tx.Body.Output1.Amount.Cmp(big.NewInt(0)) == -1 {
-		return NewErrNegativeOutput(1)
+	if err := checkCanonicalSigs(tx); err != nil {
+		return err
 	}
 
-	prevTx0Conf, err := storage.FindTransactionByBlockNumTxIdx(tx.Body.Input0.BlockNumber, tx.Body.Input0.TransactionIndex)
-	if err == leveldb.ErrNotFound {
-		return NewErrTxNotFound(0, tx.Body.Input0.BlockNumber, tx.Body.Input0.TransactionIndex)
-	}
+	sigHashes, err := policy.Hashes(tx.Body.VersionedSignatureHash)
 	if err != nil {
 		return err
 	}
-	prevTx0 := prevTx0Conf.Transaction
-	if prevTx0Conf.ConfirmSigs[0] != tx.Body.Input0ConfirmSigs[0] {
-		return NewErrConfirmSigMismatch(0, 0)
-	}
-	if prevTx0Conf.ConfirmSigs[1] != tx.Body.Input0ConfirmSigs[1] {
-		return NewErrConfirmSigMismatch(0, 1)
-	}
-	sigHash0 := tx.Body.SignatureHash()
-	prevTx0Output := prevTx0.Body.OutputAt(tx.Body.Input0.OutputIndex)
-	err = eth.ValidateSignature(sigHash0, tx.Sigs[0][:], prevTx0Output.Owner)
-	if err != nil {
-		return NewErrInvalidSignature(0)
-	}
-
 	totalInput := big.NewInt(0)
-	totalInput = totalInput.Add(totalInput, prevTx0Output.Amount)
-
-	if !tx.Body.Input1.IsZero() {
-		if tx.Body.Input1.BlockNumber == tx.Body.Input0.BlockNumber && tx.Body.Input1.TransactionIndex == tx.Body.Input0.TransactionIndex && tx.Body.Input1.OutputIndex == tx.Body.Input0.OutputIndex {
-			return NewErrIdenticalInputs()
+	for i := 0; i < tx.Body.InputCount(); i++ {
+		idx := uint8(i)
+		input := tx.Body.InputAt(idx)
+		if i > 0 && input.IsZero() {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			other := tx.Body.InputAt(uint8(j))
+			if input.BlockNumber == other.BlockNumber && input.TransactionIndex == other.TransactionIndex && input.OutputIndex == other.OutputIndex {
+				return NewErrIdenticalInputs()
+			}
 		}
 
-		prevTx1Conf, err := storage.FindTransactionByBlockNumTxIdx(tx.Body.Input1.BlockNumber, tx.Body.Input1.TransactionIndex)
-		if err == leveldb.ErrNotFound {
-			return NewErrTxNotFound(1, tx.Body.Input1.BlockNumber, tx.Body.Input1.TransactionIndex)
+		prevTxConf, err := storage.FindTransactionByBlockNumTxIdx(input.BlockNumber, input.TransactionIndex)
+		if err == db.ErrNotFound {
+			return NewErrTxNotFound(idx, input.BlockNumber, input.TransactionIndex)
 		}
 		if err != nil {
 			return err
 		}
-		prevTx1 := prevTx1Conf.Transaction
-		if prevTx1Conf.ConfirmSigs[0] != tx.Body.Input1ConfirmSigs[0] {
-			return NewErrConfirmSigMismatch(1, 0)
-		}
-		if prevTx1Conf.ConfirmSigs[1] != tx.Body.Input1ConfirmSigs[1] {
-			return NewErrConfirmSigMismatch(1, 1)
+		if err := checkInputConfirmSigs(idx, prevTxConf.ConfirmSigs, tx.Body.InputConfirmSigsAt(idx)); err != nil {
+			return err
 		}
-		prevTx1Output := prevTx1.Body.OutputAt(tx.Body.Input1.OutputIndex)
-		sigHash1 := tx.Body.SignatureHash()
-		err = eth.ValidateSignature(sigHash1, tx.Sigs[1][:], prevTx1Output.Owner)
-		if err != nil {
-			return NewErrInvalidSignature(1)
+		prevTxOutput := prevTxConf.Transaction.Body.OutputAt(input.OutputIndex)
+		sig := tx.SigAt(idx)
+		if err := CheckSignature(sigHashes, sig, prevTxOutput.Owner); err != nil {
+			return NewErrInvalidSignature(idx)
 		}
 
-		totalInput = totalInput.Add(totalInput, prevTx1Output.Amount)
+		totalInput = totalInput.Add(totalInput, prevTxOutput.Amount)
 	}
 
-	totalOutput := big.NewInt(0)
+	totalOutput := new(big.Int).Set(tx.Body.Fee)
 	totalOutput = totalOutput.Add(totalOutput, tx.Body.Output0.Amount)
-	totalOutput = totalOutput.Add(totalOutput, tx.Body.Fee)
 	if !tx.Body.Output1.IsZeroOutput() {
 		totalOutput = totalOutput.Add(totalOutput, tx.Body.Output1.Amount)
 	}
@@ -96,46 +78,54 @@ func ValidateSpendTransaction(storage db.Storage, tx *chain.Transaction) (error)
 	return nil
 }
 
-func ValidateDepositTransaction(storage db.Storage, client eth.Client, tx *chain.Transaction) (error) {
-	if tx.Body.Output0.Amount.Cmp(big.NewInt(0)) == -1 {
-		return NewErrNegativeOutput(0)
+// ValidateFee checks that tx pays at least minFee. A nil minFee accepts
+// any fee.
+func ValidateFee(minFee *big.Int, tx *chain.Transaction) error {
+	if minFee == nil || tx.Body.Fee.Cmp(minFee) >= 0 {
+		return nil
 	}
-	if tx.Body.Output1.Amount.Cmp(big.NewInt(0)) == -1 {
-		return NewErrNegativeOutput(1)
+	return NewErrFeeTooLow(tx.Body.Fee, minFee)
+}
+
+func ValidateDepositTransaction(storage db.Storage, client eth.Client, policy *SignaturePolicy, tx *chain.Transaction) (error) {
+	if err := checkNegativeOutputs(tx); err != nil {
+		return err
+	}
+	if err := checkCanonicalSigs(tx); err != nil {
+		return err
 	}
 
-	var emptySig chain.Signature
-	if !tx.Body.Input1.IsZero() || tx.Body.Input1ConfirmSigs[0] != emptySig || tx.Body.Input1ConfirmSigs[1] != emptySig {
+	var emptySigs [2]chain.Signature
+	if !tx.Body.Input1.IsZero() || tx.Body.Input1ConfirmSigs != emptySigs {
 		return NewErrDepositDefinedInput1()
 	}
 
-	if tx.Body.Input0ConfirmSigs[0] != emptySig || tx.Body.Input0ConfirmSigs[1] != emptySig {
+	if tx.Body.Input0ConfirmSigs != emptySigs {
 		return NewErrDepositNonEmptyConfirmSig()
 	}
 
-	total, owner, err := client.LookupDeposit(tx.Body.Input0.DepositNonce)
-	if err != nil {
-		return err
-	}
-
 	totalOuts := big.NewInt(0)
 	totalOuts = totalOuts.Add(totalOuts, tx.Body.Output0.Amount)
 	totalOuts = totalOuts.Add(totalOuts, tx.Body.Output1.Amount)
 	totalOuts = totalOuts.Add(totalOuts, tx.Body.Fee)
+
+	total, owner, err := client.LookupDeposit(tx.Body.InputAt(0).DepositNonce)
+	if err != nil {
+		return err
+	}
 	if total.Cmp(totalOuts) != 0 {
 		return NewErrInputOutputValueMismatch(total, totalOuts)
 	}
 
-	sigHash := tx.Body.SignatureHash()
-	err = eth.ValidateSignature(sigHash, tx.Sigs[0][:], owner)
-	if err != nil {
-		return NewErrInvalidSignature(0)
-	}
-	err = eth.ValidateSignature(sigHash, tx.Sigs[1][:], owner)
+	// the depositor signs the deposit input. Input 1 is zero, so
+	// checkCanonicalSigs has already required its signature to be empty.
+	sigHashes, err := policy.Hashes(tx.Body.VersionedSignatureHash)
 	if err != nil {
-		return NewErrInvalidSignature(1)
 		return err
 	}
+	if err := CheckSignature(sigHashes, tx.SigAt(0), owner); err != nil {
+		return NewErrInvalidSignature(0)
+	}
 
 	isDoubleSpent, err := storage.IsDoubleSpent(tx)
 	if err != nil {
@@ -148,15 +138,15 @@ func ValidateDepositTransaction(storage db.Storage, client eth.Client, tx *chain
 	return nil
 }
 
-func ValidateConfirmSigs(storage db.Storage, client eth.Client, blk *chain.Block, confirmed *chain.ConfirmedTransaction) error {
+func ValidateConfirmSigs(storage db.Storage, client eth.Client, policy *SignaturePolicy, blk *chain.Block, confirmed *chain.ConfirmedTransaction) error {
 	var emptySig chain.Signature
 	tx := confirmed.Transaction
-	merkleRoot := blk.Header.MerkleRoot
-	txHash := tx.RLPHash(util.Sha256)
-	var sigBuf bytes.Buffer
-	sigBuf.Write(txHash[:])
-	sigBuf.Write(merkleRoot[:])
-	sigHash := util.Sha256(sigBuf.Bytes())
+	sigHashes, err := policy.Hashes(func(version chain.SignatureVersion, domain *chain.Domain) (util.Hash, error) {
+		return tx.VersionedConfirmationHash(version, domain, blk.Header.MerkleRoot)
+	})
+	if err != nil {
+		return err
+	}
 	for i, sig := range confirmed.ConfirmSigs {
 		input := tx.Body.InputAt(uint8(i))
 		if i > 0 && input.IsZero() {
@@ -182,7 +172,7 @@ func ValidateConfirmSigs(storage db.Storage, client eth.Client, blk *chain.Block
 			owner = prevTx.Transaction.Body.OutputAt(input.OutputIndex).Owner
 		}
 
-		if err := eth.ValidateSignature(sigHash, sig[:], owner); err != nil {
+		if err := CheckSignature(sigHashes, sig, owner); err != nil {
 			return err
 		}
 	}
@@ -190,28 +180,31 @@ func ValidateConfirmSigs(storage db.Storage, client eth.Client, blk *chain.Block
 	return nil
 }
 
-func ValidateConfirmedTransaction(storage db.Storage, client eth.Client, block *chain.Block, confirmed *chain.ConfirmedTransaction) error {
+func ValidateConfirmedTransaction(storage db.Storage, client eth.Client, policy *SignaturePolicy, block *chain.Block, confirmed *chain.ConfirmedTransaction) error {
 	var err error
 	if confirmed.Transaction.Body.IsDeposit() {
-		err = ValidateDepositTransaction(storage, client, confirmed.Transaction)
+		err = ValidateDepositTransaction(storage, client, policy, confirmed.Transaction)
 	} else {
-		err = ValidateSpendTransaction(storage, confirmed.Transaction)
+		err = ValidateSpendTransaction(storage, policy, confirmed.Transaction)
 	}
 	if err != nil {
 		return err
 	}
 
-	return ValidateConfirmSigs(storage, client, block, confirmed)
+	return ValidateConfirmSigs(storage, client, policy, block, confirmed)
 }
 
-func ValidateBlock(storage db.Storage, client eth.Client, block *chain.Block, confirmedTxs []chain.ConfirmedTransaction) error {
+func ValidateBlock(storage db.Storage, client eth.Client, policy *SignaturePolicy, block *chain.Block, confirmedTxs []chain.ConfirmedTransaction) error {
 	hashables := make([]util.RLPHashable, len(confirmedTxs), len(confirmedTxs))
 	for i, tx := range confirmedTxs {
-		err := ValidateConfirmedTransaction(storage, client, block, &tx)
+		hashables[i] = tx.Transaction
+		if block.Header.Number < policy.StrictFrom {
+			tx = historicalTransaction(tx)
+		}
+		err := ValidateConfirmedTransaction(storage, client, policy, block, &tx)
 		if err != nil {
 			return err
 		}
-		hashables[i] = tx.Transaction
 	}
 
 	merkleRoot := merkle.Root(hashables)
@@ -221,3 +214,29 @@ func ValidateBlock(storage db.Storage, client eth.Client, block *chain.Block, co
 
 	return nil
 }
+
+// checkNegativeOutputs rejects transactions with a negative output.
+func checkNegativeOutputs(tx *chain.Transaction) error {
+	if tx.Body.Output0.Amount.Cmp(big.NewInt(0)) == -1 {
+		return NewErrNegativeOutput(0)
+	}
+	if tx.Body.Output1.Amount.Cmp(big.NewInt(0)) == -1 {
+		return NewErrNegativeOutput(1)
+	}
+	return nil
+}
+
+// checkInputConfirmSigs verifies that the confirm sigs given for an input
+// match those of the transaction that created it. Recovery ids are
+// normalized before comparing, since confirm sigs stored before they were
+// normalized may use 27 or 28.
+func checkInputConfirmSigs(inputIdx uint8, expected [2]chain.Signature, actual [2]chain.Signature) error {
+	for i := range expected {
+		expected[i].NormalizeV()
+		actual[i].NormalizeV()
+		if expected[i] != actual[i] {
+			return NewErrConfirmSigMismatch(inputIdx, uint8(i))
+		}
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/kyokan/plasma/pkg/eth/contracts"
 	"github.com/kyokan/plasma/util"
+	"github.com/kyokan/plasma/pkg/eth"
 )
 
 type EthClientMock struct {
@@ -62,10 +63,31 @@ func (e *EthClientMock) EthereumBlockHeight() (uint64, error) {
 	panic("implement me")
 }
 
+func (e *EthClientMock) ChainID() (*big.Int, error) {
+	panic("implement me")
+}
+
 func (e *EthClientMock) LookupDeposit(depositNonce *big.Int) (*big.Int, common.Address, error) {
 	args := e.Called(depositNonce)
 	return args.Get(0).(*big.Int), args.Get(1).(common.Address), args.Error(2)
 }
 
+func (e *EthClientMock) LookupBlock(blkNum uint64) (*eth.Block, error) {
+	panic("implement me")
+}
 
+func (e *EthClientMock) StartFeeExit(blkNum uint64) (*types.Receipt, error) {
+	panic("implement me")
+}
 
+func (e *EthClientMock) FinalizeTransactionExits() (*types.Receipt, error) {
+	panic("implement me")
+}
+
+func (e *EthClientMock) Withdraw() (*types.Receipt, error) {
+	panic("implement me")
+}
+
+func (e *EthClientMock) WithdrawableBalance(addr common.Address) (*big.Int, error) {
+	panic("implement me")
+}
@@ -5,6 +5,8 @@ import (
 	"github.com/kyokan/plasma/pkg/chain"
 	"github.com/ethereum/go-ethereum/common"
 	"math/big"
+	"github.com/kyokan/plasma/pkg/db"
+	"github.com/kyokan/plasma/util"
 )
 
 type StorageMock struct {
@@ -23,6 +25,14 @@ func (s *StorageMock) FindTransactionByBlockNumTxIdx(blkNum uint64, txIdx uint32
 	panic("implement me")
 }
 
+func (s *StorageMock) TransactionHashesByBlockNum(blkNum uint64) ([]util.Hash, error) {
+	panic("implement me")
+}
+
+func (s *StorageMock) PrunedHeight() (uint64, error) {
+	panic("implement me")
+}
+
 func (s *StorageMock) Balance(addr common.Address) (*big.Int, error) {
 	panic("implement me")
 }
@@ -95,6 +105,18 @@ func (s *StorageMock) MarkTransactionAsExited(plasmaBlockNum uint64, plasmaTxIdx
 	panic("implement me")
 }
 
+func (s *StorageMock) Exits() ([]db.ExitLocator, error) {
+	panic("implement me")
+}
+
+func (s *StorageMock) FeeLedger() ([]db.FeeEntry, error) {
+	panic("implement me")
+}
+
+func (s *StorageMock) MarkFeesExited(blockNum uint64, ethTransactionHash common.Hash) error {
+	panic("implement me")
+}
+
 func (s *StorageMock) IsDoubleSpent(tx *chain.Transaction) (bool, error) {
 	panic("implement me")
 }
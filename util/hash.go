@@ -68,3 +68,17 @@ func GethHash(b []byte) Hash {
 	hasher.Write(b)
 	return hasher.Sum(nil)
 }
+
+// TypedDataHash returns the EIP-712 digest of a struct hash under a domain
+// separator.
+func TypedDataHash(domainSeparator []byte, structHash []byte) Hash {
+	if len(domainSeparator) != 32 || len(structHash) != 32 {
+		panic("hash must be 32 bytes")
+	}
+
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write([]byte("\x19\x01"))
+	hasher.Write(domainSeparator)
+	hasher.Write(structHash)
+	return hasher.Sum(nil)
+}
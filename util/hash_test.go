@@ -80,4 +80,16 @@ func TestGethHash(t *testing.T) {
 	require.NoError(t, err)
 	hash := GethHash(input)
 	require.True(t, bytes.Equal(expected, hash))
+}
+
+func TestTypedDataHash(t *testing.T) {
+	// the Mail example from EIP-712
+	expected, err := hexutil.Decode("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2")
+	require.NoError(t, err)
+	domainSeparator, err := hexutil.Decode("0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f")
+	require.NoError(t, err)
+	structHash, err := hexutil.Decode("0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e")
+	require.NoError(t, err)
+	hash := TypedDataHash(domainSeparator, structHash)
+	require.True(t, bytes.Equal(expected, hash))
 }
\ No newline at end of file